@@ -32,23 +32,23 @@ type collectREST struct {
 }
 
 func (rest *collectREST) Route(r *ship.RouteGroupBuilder) {
-	r.Route("/broker/collect/agent/sysinfo").POST(rest.Sysinfo)
-	r.Route("/broker/collect/agent/process").POST(rest.ProcessDiff)
-	r.Route("/broker/collect/agent/process/diff").POST(rest.ProcessDiff)
-	r.Route("/broker/collect/agent/process/full").POST(rest.ProcessFull)
-	r.Route("/broker/collect/agent/process/sync").POST(rest.ProcessSync)
-	r.Route("/broker/collect/agent/logon").POST(rest.Logon)
-	r.Route("/broker/collect/agent/listen").POST(rest.ListenDiff)
-	r.Route("/broker/collect/agent/listen/diff").POST(rest.ListenDiff)
-	r.Route("/broker/collect/agent/listen/full").POST(rest.ListenFull)
-	r.Route("/broker/collect/agent/account").POST(rest.AccountDiff)
-	r.Route("/broker/collect/agent/account/diff").POST(rest.AccountDiff)
-	r.Route("/broker/collect/agent/account/full").POST(rest.AccountFull)
-	r.Route("/broker/collect/agent/group").POST(rest.GroupDiff)
-	r.Route("/broker/collect/agent/group/diff").POST(rest.GroupDiff)
-	r.Route("/broker/collect/agent/group/full").POST(rest.GroupFull)
-	r.Route("/broker/collect/agent/sbom").POST(rest.Sbom)
-	r.Route("/broker/collect/agent/cpu").POST(rest.CPU)
+	r.Route("/broker/collect/agent/sysinfo").Data(route.Named("上报系统信息")).POST(rest.Sysinfo)
+	r.Route("/broker/collect/agent/process").Data(route.Named("上报进程变化")).POST(rest.ProcessDiff)
+	r.Route("/broker/collect/agent/process/diff").Data(route.Named("上报进程变化")).POST(rest.ProcessDiff)
+	r.Route("/broker/collect/agent/process/full").Data(route.Named("上报全量进程")).POST(rest.ProcessFull)
+	r.Route("/broker/collect/agent/process/sync").Data(route.Named("同步进程")).POST(rest.ProcessSync)
+	r.Route("/broker/collect/agent/logon").Data(route.Named("上报登录事件")).POST(rest.Logon)
+	r.Route("/broker/collect/agent/listen").Data(route.Named("上报监听端口变化")).POST(rest.ListenDiff)
+	r.Route("/broker/collect/agent/listen/diff").Data(route.Named("上报监听端口变化")).POST(rest.ListenDiff)
+	r.Route("/broker/collect/agent/listen/full").Data(route.Named("上报全量监听端口")).POST(rest.ListenFull)
+	r.Route("/broker/collect/agent/account").Data(route.Named("上报账户变化")).POST(rest.AccountDiff)
+	r.Route("/broker/collect/agent/account/diff").Data(route.Named("上报账户变化")).POST(rest.AccountDiff)
+	r.Route("/broker/collect/agent/account/full").Data(route.Named("上报全量账户")).POST(rest.AccountFull)
+	r.Route("/broker/collect/agent/group").Data(route.Named("上报用户组变化")).POST(rest.GroupDiff)
+	r.Route("/broker/collect/agent/group/diff").Data(route.Named("上报用户组变化")).POST(rest.GroupDiff)
+	r.Route("/broker/collect/agent/group/full").Data(route.Named("上报全量用户组")).POST(rest.GroupFull)
+	r.Route("/broker/collect/agent/sbom").Data(route.Named("上报 SBOM")).POST(rest.Sbom)
+	r.Route("/broker/collect/agent/cpu").Data(route.Named("上报 CPU 信息")).POST(rest.CPU)
 }
 
 func (rest *collectREST) Sysinfo(c *ship.Context) error {
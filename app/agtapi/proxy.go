@@ -1,8 +1,6 @@
 package agtapi
 
 import (
-	"context"
-	"net"
 	"net/http"
 	"net/http/httputil"
 	"net/url"
@@ -11,15 +9,14 @@ import (
 	"github.com/xgfone/ship/v5"
 )
 
-func Proxy(dc func(context.Context, string, string) (net.Conn, error)) route.Router {
+// Proxy 代理 agent 发往 SIEM 的请求，trip 为发往中心端的 RoundTripper，agent 需要真实的响应，不能经过 outbox。
+func Proxy(trip http.RoundTripper) route.Router {
 	rawURL, _ := url.Parse("http://vtun/proxy/siem")
 	siem := &httputil.ReverseProxy{
 		Rewrite: func(pr *httputil.ProxyRequest) {
 			pr.SetURL(rawURL)
 		},
-		Transport: &http.Transport{
-			DialContext: dc,
-		},
+		Transport: trip,
 	}
 
 	return &proxyAPI{
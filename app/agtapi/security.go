@@ -45,12 +45,12 @@ func (rest *securityREST) RiskIP(c *ship.Context) error {
 	ctx := c.Request().Context()
 	tbl := rest.qry.RiskIP
 	dao := tbl.WithContext(ctx).
-		Where(tbl.IP.In(body.Data...), tbl.BeforeAt.Gte(now))
+		Where(tbl.IP.In(body.Candidates()...), tbl.BeforeAt.Gte(now))
 	if len(qry.Kind) != 0 {
 		dao.Where(tbl.Kind.In(qry.Kind...))
 	}
 	dats, _ := dao.Limit(rest.limit).Find()
-	kinds := model.RiskIPs(dats).IPKinds()
+	kinds := body.Remap(model.RiskIPs(dats).IPKinds())
 	res := &param.SecurityResult{
 		Count: len(kinds),
 		Data:  kinds,
@@ -73,12 +73,12 @@ func (rest *securityREST) PassIP(c *ship.Context) error {
 	ctx := c.Request().Context()
 	tbl := rest.qry.PassIP
 	dao := tbl.WithContext(ctx).
-		Where(tbl.IP.In(body.Data...), tbl.BeforeAt.Gte(now))
+		Where(tbl.IP.In(body.Candidates()...), tbl.BeforeAt.Gte(now))
 	if len(qry.Kind) != 0 {
 		dao.Where(tbl.Kind.In(qry.Kind...))
 	}
 	dats, _ := dao.Limit(rest.limit).Find()
-	kinds := model.PassIPs(dats).IPKinds()
+	kinds := body.Remap(model.PassIPs(dats).IPKinds())
 	res := &param.SecurityResult{
 		Count: len(kinds),
 		Data:  kinds,
@@ -11,6 +11,7 @@ import (
 	"github.com/vela-ssoc/ssoc-broker/app/middle"
 	"github.com/vela-ssoc/ssoc-broker/app/route"
 	"github.com/vela-ssoc/ssoc-broker/bridge/mlink"
+	"github.com/vela-ssoc/ssoc-broker/library/metrics"
 	"github.com/vela-ssoc/ssoc-common-mb/integration/elastic"
 	"github.com/vela-ssoc/ssoc-common-mb/problem"
 	"github.com/vela-ssoc/vela-common-mba/netutil"
@@ -76,6 +77,7 @@ func (rest *streamREST) Tunnel(c *ship.Context) error {
 		c.Warnf("proto dial 错误：%s", err)
 		return err
 	}
+	conn = metrics.CountConn(conn, scheme)
 
 	ws, err := rest.upgrade.Upgrade(w, r, nil)
 	if err != nil {
@@ -10,6 +10,7 @@ import (
 	"github.com/vela-ssoc/ssoc-broker/app/internal/param"
 	"github.com/vela-ssoc/ssoc-broker/app/route"
 	"github.com/vela-ssoc/ssoc-broker/bridge/mlink"
+	"github.com/vela-ssoc/ssoc-broker/library/metrics"
 	"github.com/vela-ssoc/ssoc-common-mb/dal/gridfs"
 	"github.com/vela-ssoc/ssoc-common-mb/dal/model"
 	"github.com/vela-ssoc/ssoc-common-mb/dal/query"
@@ -134,7 +135,7 @@ func (rest *upgradeREST) Download(c *ship.Context) error {
 	c.Header().Set(ship.HeaderContentLength, stm.ContentLength())
 	c.Header().Set(ship.HeaderContentDisposition, stm.Disposition())
 
-	return c.Stream(http.StatusOK, stm.ContentType(), stm)
+	return c.Stream(http.StatusOK, stm.ContentType(), metrics.CountReader(stm, "upgrade", "tx"))
 }
 
 func (rest *upgradeREST) tryLock() bool {
@@ -4,6 +4,7 @@ import (
 	"context"
 	"time"
 
+	"github.com/vela-ssoc/ssoc-broker/library/metrics"
 	"github.com/vela-ssoc/ssoc-common-mb/dal/model"
 	"github.com/vela-ssoc/ssoc-common-mb/dal/query"
 	"github.com/vela-ssoc/ssoc-common-mb/gopool"
@@ -18,7 +19,7 @@ type CollectService interface {
 func NewCollect(qry *query.Query) CollectService {
 	return &collectService{
 		qry:  qry,
-		pool: gopool.New(1024),
+		pool: metrics.WrapPool("collect", 1024, gopool.New(1024)),
 	}
 }
 
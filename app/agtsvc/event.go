@@ -0,0 +1,151 @@
+package agtsvc
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"time"
+
+	"github.com/vela-ssoc/ssoc-common-mb/dal/model"
+	"github.com/vela-ssoc/ssoc-common-mb/integration/alarm"
+)
+
+// NodeEventRecorder 记录节点上下线事件并告警，新旧通道共用。
+//
+// 节点频繁上下线时抑制单条告警，改为发送一条汇总事件，节点停止抖动后再发送一条恢复事件；
+// 如果节点停在了离线状态，则补发一条下线告警。
+type NodeEventRecorder interface {
+	// Online 节点上线。
+	Online(mid int64, inet, semver string)
+
+	// Offline 节点下线，du 为本次在线时长，未知时为 0。
+	Offline(mid int64, inet, semver string, du time.Duration)
+}
+
+func NodeEvent(alert alarm.Alerter, opt FlapOption, log *slog.Logger) NodeEventRecorder {
+	ner := &nodeEventRecorder{alert: alert, log: log}
+	ner.flap = newFlapDetector(opt, ner.flapRecovered)
+
+	return ner
+}
+
+type nodeEventRecorder struct {
+	alert alarm.Alerter
+	flap  *flapDetector
+	log   *slog.Logger
+}
+
+func (ner *nodeEventRecorder) Online(mid int64, inet, semver string) {
+	now := time.Now()
+	dec := ner.flap.observe(mid, inet, true, now)
+	evt := &model.Event{
+		MinionID:  mid,
+		Inet:      inet,
+		Subject:   "节点上线",
+		FromCode:  "minion.online",
+		Msg:       fmt.Sprintf("当前 agent 版本：%s", semver),
+		Level:     model.ELvlNote,
+		SendAlert: dec.Alert,
+		OccurAt:   now,
+		CreatedAt: now,
+	}
+	ner.save(evt)
+	ner.flapping(mid, inet, dec)
+}
+
+func (ner *nodeEventRecorder) Offline(mid int64, inet, semver string, du time.Duration) {
+	now := time.Now()
+	msg := fmt.Sprintf("当前 agent 版本：%s", semver)
+	if du > 0 {
+		msg += fmt.Sprintf("，在线时长：%s", du.Round(time.Second))
+	}
+	dec := ner.flap.observe(mid, inet, false, now)
+	evt := &model.Event{
+		MinionID:  mid,
+		Inet:      inet,
+		Subject:   "节点下线",
+		FromCode:  "minion.offline",
+		Msg:       msg,
+		Level:     model.ELvlMajor,
+		SendAlert: dec.Alert,
+		OccurAt:   now,
+		CreatedAt: now,
+	}
+	ner.save(evt)
+	ner.flapping(mid, inet, dec)
+}
+
+// flapping 节点进入抖动状态时发送一条汇总告警，之后的上下线告警会被抑制。
+func (ner *nodeEventRecorder) flapping(mid int64, inet string, dec flapDecision) {
+	if !dec.Enter {
+		return
+	}
+
+	opt := ner.flap.opt
+	ner.log.Warn("节点频繁上下线，抑制上下线告警", slog.Int64("minion_id", mid), slog.String("inet", inet),
+		slog.Int("online", dec.Online), slog.Int("offline", dec.Offline))
+
+	now := time.Now()
+	msg := fmt.Sprintf("%s 内上线 %d 次，下线 %d 次，在节点稳定 %s 之前不再发送上下线告警",
+		opt.Window, dec.Online, dec.Offline, opt.Stable)
+	evt := &model.Event{
+		MinionID:  mid,
+		Inet:      inet,
+		Subject:   "节点频繁上下线",
+		FromCode:  "minion.flapping",
+		Msg:       msg,
+		Level:     model.ELvlMajor,
+		SendAlert: true,
+		OccurAt:   now,
+		CreatedAt: now,
+	}
+	ner.save(evt)
+}
+
+// flapRecovered 节点停止抖动，停在离线状态的补发下线告警，否则发送恢复稳定事件。
+func (ner *nodeEventRecorder) flapRecovered(mid int64, rec flapRecovery) {
+	now := time.Now()
+	if rec.Down {
+		ner.log.Warn("节点停止抖动，但仍处于离线状态", slog.Int64("minion_id", mid), slog.String("inet", rec.Inet))
+		msg := fmt.Sprintf("节点自 %s 起频繁上下线，期间抑制了 %d 次上线告警、%d 次下线告警，最后一次于 %s 下线后至今未恢复",
+			rec.Since.Format(time.DateTime), rec.Online, rec.Offline, rec.DownAt.Format(time.DateTime))
+		evt := &model.Event{
+			MinionID:  mid,
+			Inet:      rec.Inet,
+			Subject:   "节点下线",
+			FromCode:  "minion.offline",
+			Msg:       msg,
+			Level:     model.ELvlMajor,
+			SendAlert: true,
+			OccurAt:   rec.DownAt,
+			CreatedAt: now,
+		}
+		ner.save(evt)
+		return
+	}
+
+	ner.log.Info("节点上下线恢复稳定", slog.Int64("minion_id", mid), slog.String("inet", rec.Inet))
+	msg := fmt.Sprintf("节点自 %s 起频繁上下线，期间抑制了 %d 次上线告警、%d 次下线告警，现已恢复稳定",
+		rec.Since.Format(time.DateTime), rec.Online, rec.Offline)
+	evt := &model.Event{
+		MinionID:  mid,
+		Inet:      rec.Inet,
+		Subject:   "节点上下线恢复稳定",
+		FromCode:  "minion.flapping.recovered",
+		Msg:       msg,
+		Level:     model.ELvlNote,
+		SendAlert: true,
+		OccurAt:   now,
+		CreatedAt: now,
+	}
+	ner.save(evt)
+}
+
+func (ner *nodeEventRecorder) save(evt *model.Event) {
+	ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+	defer cancel()
+
+	if err := ner.alert.EventSaveAndAlert(ctx, evt); err != nil {
+		ner.log.Warn("保存节点事件出错", slog.Int64("minion_id", evt.MinionID), slog.String("subject", evt.Subject), slog.Any("error", err))
+	}
+}
@@ -0,0 +1,166 @@
+package agtsvc
+
+import (
+	"sync"
+	"time"
+)
+
+// FlapOption 抖动检测参数，为零的字段使用默认值。
+type FlapOption struct {
+	Window    time.Duration `json:"window"    yaml:"window"`    // 统计上下线次数的滑动窗口，默认 10m
+	Threshold int           `json:"threshold" yaml:"threshold"` // 窗口内上下线次数达到该值即认为节点在抖动，默认 6
+	Stable    time.Duration `json:"stable"    yaml:"stable"`    // 抖动期间持续该时长未发生上下线即认为恢复稳定，默认 15m
+}
+
+func (opt FlapOption) format() FlapOption {
+	if opt.Window <= 0 {
+		opt.Window = 10 * time.Minute
+	}
+	if opt.Threshold <= 0 {
+		opt.Threshold = 6
+	}
+	if opt.Stable <= 0 {
+		opt.Stable = 15 * time.Minute
+	}
+
+	return opt
+}
+
+// flapDecision 一次上下线的处理结果。
+type flapDecision struct {
+	Alert   bool // 是否发送单条上下线告警
+	Enter   bool // 本次上下线导致节点进入抖动状态
+	Online  int  // 窗口内上线次数
+	Offline int  // 窗口内下线次数
+}
+
+// flapRecovery 节点停止抖动时的统计信息。
+type flapRecovery struct {
+	Inet    string
+	Since   time.Time // 进入抖动状态的时间
+	Online  int       // 抖动期间被抑制的上线告警次数
+	Offline int       // 抖动期间被抑制的下线告警次数
+	Down    bool      // 最后一次记录的是下线，即节点停在了离线状态
+	DownAt  time.Time // 最后一次下线的时间
+}
+
+type flapState struct {
+	inet     string
+	changes  []flapChange
+	flapping bool
+	since    time.Time
+	online   int
+	offline  int
+	recovery *time.Timer
+}
+
+type flapChange struct {
+	at     time.Time
+	online bool
+}
+
+func newFlapDetector(opt FlapOption, recovered func(id int64, rec flapRecovery)) *flapDetector {
+	return &flapDetector{
+		opt:       opt.format(),
+		recovered: recovered,
+		states:    make(map[int64]*flapState, 1024),
+	}
+}
+
+// flapDetector 节点上下线抖动检测：节点频繁上下线时抑制单条告警，改为发送一条汇总事件，
+// 节点恢复稳定后再发送一条恢复事件。
+type flapDetector struct {
+	opt       FlapOption
+	recovered func(id int64, rec flapRecovery)
+	mutex     sync.Mutex
+	states    map[int64]*flapState
+	sweptAt   time.Time
+}
+
+// observe 记录一次上下线。
+func (fd *flapDetector) observe(id int64, inet string, online bool, at time.Time) flapDecision {
+	fd.mutex.Lock()
+	defer fd.mutex.Unlock()
+
+	start := at.Add(-fd.opt.Window)
+	fd.sweep(start, at)
+
+	st := fd.states[id]
+	if st == nil {
+		st = new(flapState)
+		fd.states[id] = st
+	}
+	st.inet = inet
+
+	// 滑出窗口的记录丢弃
+	idx := 0
+	for idx < len(st.changes) && st.changes[idx].at.Before(start) {
+		idx++
+	}
+	st.changes = append(st.changes[idx:], flapChange{at: at, online: online})
+
+	var dec flapDecision
+	for _, c := range st.changes {
+		if c.online {
+			dec.Online++
+		} else {
+			dec.Offline++
+		}
+	}
+
+	if st.flapping {
+		if online {
+			st.online++
+		} else {
+			st.offline++
+		}
+		st.recovery.Reset(fd.opt.Stable)
+		return dec
+	}
+	if len(st.changes) < fd.opt.Threshold {
+		dec.Alert = true
+		return dec
+	}
+
+	dec.Enter = true
+	st.flapping = true
+	st.since = at
+	st.online, st.offline = 0, 0
+	st.recovery = time.AfterFunc(fd.opt.Stable, func() { fd.recover(id, st) })
+
+	return dec
+}
+
+// sweep 每个窗口顺便清理一次未处于抖动状态、且所有记录都已滑出窗口的节点，调用方需持有锁。
+func (fd *flapDetector) sweep(start, now time.Time) {
+	if !fd.sweptAt.Before(start) {
+		return
+	}
+	fd.sweptAt = now
+	for id, st := range fd.states {
+		if st.flapping {
+			continue
+		}
+		if n := len(st.changes); n == 0 || st.changes[n-1].at.Before(start) {
+			delete(fd.states, id)
+		}
+	}
+}
+
+func (fd *flapDetector) recover(id int64, st *flapState) {
+	fd.mutex.Lock()
+	if fd.states[id] != st || !st.flapping {
+		fd.mutex.Unlock()
+		return
+	}
+	delete(fd.states, id)
+	rec := flapRecovery{Inet: st.inet, Since: st.since, Online: st.online, Offline: st.offline}
+	if n := len(st.changes); n != 0 && !st.changes[n-1].online {
+		rec.Down, rec.DownAt = true, st.changes[n-1].at
+	}
+	fd.mutex.Unlock()
+
+	if fd.recovered != nil {
+		fd.recovered(id, rec)
+	}
+}
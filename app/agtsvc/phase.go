@@ -9,6 +9,7 @@ import (
 	"github.com/vela-ssoc/ssoc-broker/app/mgtsvc"
 	"github.com/vela-ssoc/ssoc-broker/bridge/gateway"
 	"github.com/vela-ssoc/ssoc-broker/bridge/mlink"
+	"github.com/vela-ssoc/ssoc-broker/library/metrics"
 	"github.com/vela-ssoc/ssoc-common-mb/dal/model"
 	"github.com/vela-ssoc/ssoc-common-mb/gopool"
 	"github.com/vela-ssoc/ssoc-common-mb/integration/alarm"
@@ -20,21 +21,23 @@ type PhaseService interface {
 	SetService(svc mgtsvc.AgentService)
 }
 
-func Phase(cmdbc cmdb.Client, alert alarm.Alerter, log *slog.Logger) PhaseService {
+func Phase(cmdbc cmdb.Client, alert alarm.Alerter, flap FlapOption, log *slog.Logger) PhaseService {
 	return &nodeEventService{
-		cmdbc: cmdbc,
-		alert: alert,
-		pool:  gopool.New(1024),
-		log:   log,
+		cmdbc:  cmdbc,
+		alert:  alert,
+		events: NodeEvent(alert, flap, log),
+		pool:   metrics.WrapPool("phase", 1024, gopool.New(1024)),
+		log:    log,
 	}
 }
 
 type nodeEventService struct {
-	svc   mgtsvc.AgentService
-	cmdbc cmdb.Client
-	alert alarm.Alerter
-	pool  gopool.Pool
-	log   *slog.Logger
+	svc    mgtsvc.AgentService
+	cmdbc  cmdb.Client
+	alert  alarm.Alerter
+	events NodeEventRecorder
+	pool   gopool.Pool
+	log    *slog.Logger
 }
 
 func (biz *nodeEventService) SetService(svc mgtsvc.AgentService) {
@@ -44,6 +47,31 @@ func (biz *nodeEventService) SetService(svc mgtsvc.AgentService) {
 func (biz *nodeEventService) Repeated(id int64, ident gateway.Ident, at time.Time) {
 }
 
+// Quarantined 隔离事件已经由身份校验模块记录。
+func (biz *nodeEventService) Quarantined(id int64, ident gateway.Ident, reason string, at time.Time) {
+}
+
+// Collided 冲突事件已经由冲突检测模块记录。
+func (biz *nodeEventService) Collided(id int64, ident gateway.Ident, reason string, at time.Time) {
+}
+
+func (biz *nodeEventService) Takeover(id int64, ident gateway.Ident, brokerID int64, reason string, at time.Time) {
+	inet := ident.Inet.String()
+	msg := fmt.Sprintf("原会话（broker: %d）已失效，由新会话接管：%s", brokerID, reason)
+	evt := &model.Event{
+		MinionID:  id,
+		Inet:      inet,
+		Subject:   "节点会话接管",
+		FromCode:  "minion.takeover",
+		Msg:       msg,
+		Level:     model.ELvlNote,
+		SendAlert: false,
+		OccurAt:   at,
+		CreatedAt: time.Now(),
+	}
+	_ = biz.alert.EventSaveAndAlert(context.Background(), evt)
+}
+
 func (biz *nodeEventService) Connected(lnk mlink.Linker, ident gateway.Ident, issue gateway.Issue, at time.Time) {
 	mid, inet := issue.ID, ident.Inet.String()
 	biz.log.Info("Agent 上线", slog.Int64("minion_id", mid), slog.String("inet", inet))
@@ -51,41 +79,14 @@ func (biz *nodeEventService) Connected(lnk mlink.Linker, ident gateway.Ident, is
 	// 推送 startup 与配置脚本
 	ctx := context.Background()
 	_ = biz.svc.ReloadStartup(ctx, mid)
-	_ = biz.svc.RsyncTask(ctx, []int64{mid})
+	biz.svc.NotifyRsync(ctx, mid)
 
-	msg := fmt.Sprintf("当前 agent 版本：%s", ident.Semver)
-	now := time.Now()
-	evt := &model.Event{
-		MinionID:  mid,
-		Inet:      inet,
-		Subject:   "节点上线",
-		FromCode:  "minion.online",
-		Msg:       msg,
-		Level:     model.ELvlNote,
-		SendAlert: true,
-		OccurAt:   now,
-		CreatedAt: now,
-	}
-	_ = biz.alert.EventSaveAndAlert(ctx, evt)
+	biz.events.Online(mid, inet, ident.Semver)
 }
 
 func (biz *nodeEventService) Disconnected(lnk mlink.Linker, ident gateway.Ident, issue gateway.Issue, at time.Time, du time.Duration) {
 	mid, inet := issue.ID, ident.Inet.String()
 	biz.log.Warn("Agent 下线", slog.Int64("minion_id", mid), slog.String("inet", inet))
 
-	msg := fmt.Sprintf("当前 agent 版本：%s", ident.Semver)
-	now := time.Now()
-	evt := &model.Event{
-		MinionID:  mid,
-		Inet:      inet,
-		Subject:   "节点下线",
-		FromCode:  "minion.offline",
-		Msg:       msg,
-		Level:     model.ELvlMajor,
-		SendAlert: true,
-		OccurAt:   now,
-		CreatedAt: now,
-	}
-
-	_ = biz.alert.EventSaveAndAlert(context.Background(), evt)
+	biz.events.Offline(mid, inet, ident.Semver, du)
 }
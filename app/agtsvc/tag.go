@@ -3,10 +3,13 @@ package agtsvc
 import (
 	"context"
 	"errors"
+	"log/slog"
+	"time"
 
 	"github.com/vela-ssoc/ssoc-broker/app/mgtsvc"
 	"github.com/vela-ssoc/ssoc-common-mb/dal/model"
 	"github.com/vela-ssoc/ssoc-common-mb/dal/query"
+	"github.com/vela-ssoc/ssoc-common-mb/gopool"
 	"gorm.io/gorm/clause"
 )
 
@@ -14,13 +17,43 @@ type TagService interface {
 	Update(ctx context.Context, mid int64, creates, deletes []string) error
 }
 
-func Tag(qry *query.Query, svc mgtsvc.AgentService) TagService {
-	return &tagService{qry: qry, svc: svc}
+// Rsyncer 通知节点同步配置，调用后立即返回，同步在后台进行。
+//
+// 旧通道由 mgtsvc.AgentService 实现。
+type Rsyncer interface {
+	NotifyRsync(ctx context.Context, mid int64)
+}
+
+// PoolRsync 在协程池中同步配置，用于新通道。
+func PoolRsync(rsync mgtsvc.TaskRsyncer, pool gopool.Pool, log *slog.Logger) Rsyncer {
+	return &poolRsync{rsync: rsync, pool: pool, log: log}
+}
+
+type poolRsync struct {
+	rsync mgtsvc.TaskRsyncer
+	pool  gopool.Pool
+	log   *slog.Logger
+}
+
+func (pr *poolRsync) NotifyRsync(ctx context.Context, mid int64) {
+	// 同步与发起请求的生命周期解绑，HTTP 请求结束后继续执行。
+	ctx = context.WithoutCancel(ctx)
+	pr.pool.Go(func() {
+		ctx, cancel := context.WithTimeout(ctx, time.Minute)
+		defer cancel()
+		if err := pr.rsync.Rsync(ctx, mid); err != nil {
+			pr.log.Warn("同步节点配置出错", slog.Int64("minion_id", mid), slog.Any("error", err))
+		}
+	})
+}
+
+func Tag(qry *query.Query, rsync Rsyncer) TagService {
+	return &tagService{qry: qry, rsync: rsync}
 }
 
 type tagService struct {
-	qry *query.Query
-	svc mgtsvc.AgentService
+	qry   *query.Query
+	rsync Rsyncer
 }
 
 func (biz *tagService) Update(ctx context.Context, mid int64, creates, deletes []string) error {
@@ -56,7 +89,7 @@ func (biz *tagService) Update(ctx context.Context, mid int64, creates, deletes [
 		return err
 	}
 
-	_ = biz.svc.RsyncTask(ctx, []int64{mid})
+	biz.rsync.NotifyRsync(ctx, mid)
 
 	return nil
 }
@@ -0,0 +1,9 @@
+package param
+
+import "github.com/vela-ssoc/ssoc-common-mb/profile"
+
+// BootConfig 中心端推送的启动配置，字段与 negotiate.Issue 中的同名配置一致。
+type BootConfig struct {
+	Logger   profile.Logger   `json:"logger"`
+	Database profile.Database `json:"database"`
+}
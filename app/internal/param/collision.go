@@ -0,0 +1,6 @@
+package param
+
+type CollisionResolve struct {
+	MachineID  string `json:"machine_id" validate:"required"`
+	Resolution string `json:"resolution" validate:"oneof=dismiss split"` // dismiss-误报，split-确认是克隆主机
+}
@@ -0,0 +1,5 @@
+package param
+
+type MulticastJob struct {
+	ID string `json:"id" query:"id" validate:"required"`
+}
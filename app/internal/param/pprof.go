@@ -1,9 +1,20 @@
 package param
 
-import "github.com/vela-ssoc/ssoc-common-mb/param/negotiate"
+import (
+	"github.com/vela-ssoc/ssoc-broker/bridge/telecom"
+	"github.com/vela-ssoc/ssoc-broker/library/heartbeat"
+	"github.com/vela-ssoc/ssoc-broker/library/outbox"
+	"github.com/vela-ssoc/ssoc-common-mb/param/negotiate"
+)
 
 type PprofConfig struct {
 	Hide  negotiate.Hide  `json:"hide"`
 	Ident negotiate.Ident `json:"ident"`
 	Issue negotiate.Issue `json:"issue"`
+	// Dialer 中心端各个地址的健康状态
+	Dialer []*telecom.AddrHealth `json:"dialer"`
+	// Heartbeat 与中心端的应用层心跳统计
+	Heartbeat heartbeat.Stats `json:"heartbeat"`
+	// Outbox 断线期间暂存的单向消息队列统计
+	Outbox outbox.Stats `json:"outbox"`
 }
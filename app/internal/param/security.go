@@ -1,5 +1,7 @@
 package param
 
+import "github.com/vela-ssoc/ssoc-broker/library/inetx"
+
 type SecurityKindRequest struct {
 	Kind []string `json:"kind" query:"kind" validate:"lte=20"`
 }
@@ -17,6 +19,54 @@ type SecurityIPRequest struct {
 	Data []string `json:"data" validate:"gte=1,lte=100,dive,ip"`
 }
 
+// Candidates 查询条件：请求中的 IP 及其标准形式，
+// 使 IPv6 大小写、压缩写法以及 IPv4-mapped 地址都能命中。
+func (r SecurityIPRequest) Candidates() []string {
+	uniq := make(map[string]struct{}, 2*len(r.Data))
+	ret := make([]string, 0, 2*len(r.Data))
+	for _, ip := range r.Data {
+		for _, s := range []string{ip, inetx.Canonical(ip)} {
+			if _, ok := uniq[s]; !ok {
+				uniq[s] = struct{}{}
+				ret = append(ret, s)
+			}
+		}
+	}
+
+	return ret
+}
+
+// Remap 将查询结果按请求中的写法返回，同一地址的不同写法的结果合并。
+func (r SecurityIPRequest) Remap(kinds map[string][]string) map[string][]string {
+	canon := make(map[string][]string, len(kinds))
+	for ip, ks := range kinds {
+		key := inetx.Canonical(ip)
+		canon[key] = append(canon[key], ks...)
+	}
+
+	ret := make(map[string][]string, len(r.Data))
+	for _, ip := range r.Data {
+		if ks, ok := canon[inetx.Canonical(ip)]; ok {
+			ret[ip] = uniqStrings(ks)
+		}
+	}
+
+	return ret
+}
+
+func uniqStrings(strs []string) []string {
+	uniq := make(map[string]struct{}, len(strs))
+	ret := make([]string, 0, len(strs))
+	for _, s := range strs {
+		if _, ok := uniq[s]; !ok {
+			uniq[s] = struct{}{}
+			ret = append(ret, s)
+		}
+	}
+
+	return ret
+}
+
 type SecurityDNSRequest struct {
 	Data []string `json:"data" validate:"gte=1,lte=100,dive,hostname_rfc1123"`
 }
@@ -0,0 +1,19 @@
+package param
+
+import "github.com/vela-ssoc/ssoc-broker/bridge/mlink"
+
+type SessionPage struct {
+	Keyword string `json:"keyword" query:"keyword"`                                                                                              // 模糊匹配 inet/machine_id/hostname/remote_addr
+	Order   string `json:"order"   query:"order"   validate:"omitempty,oneof=connected_at active_at bytes_in bytes_out streams requests errors"` // 排序字段，降序
+	Page    int    `json:"page"    query:"page"    validate:"gte=0"`
+	Size    int    `json:"size"    query:"size"    validate:"gte=0,lte=1000"`
+}
+
+type SessionResult struct {
+	Total   int              `json:"total"`
+	Records []*mlink.Session `json:"records"`
+}
+
+type SessionDetail struct {
+	ID int64 `json:"id,string" query:"id" validate:"required"`
+}
@@ -0,0 +1,5 @@
+package param
+
+type TLSPins struct {
+	Pins []string `json:"pins" validate:"lte=20,dive,required"`
+}
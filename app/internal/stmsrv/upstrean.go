@@ -11,6 +11,7 @@ import (
 	"time"
 
 	"github.com/gorilla/websocket"
+	"github.com/vela-ssoc/ssoc-broker/library/inetx"
 	"github.com/vela-ssoc/vela-common-mba/netutil"
 )
 
@@ -21,7 +22,7 @@ type Upstreamer interface {
 }
 
 func Net() Upstreamer {
-	return &netUpstream{proto: []string{"tcp", "udp", "tls"}}
+	return &netUpstream{proto: []string{"tcp", "tcp4", "tcp6", "udp", "udp4", "udp6", "tls"}}
 }
 
 func HTTP() Upstreamer {
@@ -103,7 +104,7 @@ func (up *httpUpstream) Serve(dest *url.URL, upstream net.Conn, ws *websocket.Co
 			return err
 		}
 		req.URL.Scheme = dest.Scheme
-		req.Host = host
+		req.Host = inetx.HostHeader(host)
 		log.Printf("-------------------- %s", host)
 		if err = req.Write(upstream); err != nil {
 			return err
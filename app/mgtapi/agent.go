@@ -1,8 +1,11 @@
 package mgtapi
 
 import (
+	"net/http"
+
 	"github.com/vela-ssoc/ssoc-broker/app/mgtsvc"
 	"github.com/vela-ssoc/ssoc-broker/app/route"
+	"github.com/vela-ssoc/ssoc-broker/bridge/mlink"
 	"github.com/vela-ssoc/ssoc-common-mb/accord"
 	"github.com/xgfone/ship/v5"
 )
@@ -18,13 +21,13 @@ type agentREST struct {
 }
 
 func (rest *agentREST) Route(r *ship.RouteGroupBuilder) {
-	r.Route(accord.PathUpgrade).Data(route.Named("通知节点二进制升级")).POST(rest.Upgrade)
-	r.Route(accord.PathStartup).Data(route.Named("通知节点 startup 更新")).POST(rest.Startup)
-	r.Route(accord.PathCommand).Data(route.Named("通知节点执行命令")).POST(rest.Command)
-	r.Route(accord.PathTaskSync).Data(route.Named("通知节点同步配置")).POST(rest.RsyncTask)
-	r.Route(accord.PathTaskLoad).Data(route.Named("通知节点重启配置")).POST(rest.ReloadTask)
-	r.Route(accord.PathTaskTable).Data(route.Named("通知扫表任务")).POST(rest.TableTask)
-	r.Route(accord.PathThirdDiff).Data(route.Named("通知三方文件更新")).POST(rest.ThirdDiff)
+	r.Route(accord.PathUpgrade).Data(route.Control("通知节点二进制升级")).POST(rest.Upgrade)
+	r.Route(accord.PathStartup).Data(route.Control("通知节点 startup 更新")).POST(rest.Startup)
+	r.Route(accord.PathCommand).Data(route.Control("通知节点执行命令")).POST(rest.Command)
+	r.Route(accord.PathTaskSync).Data(route.Control("通知节点同步配置")).POST(rest.RsyncTask)
+	r.Route(accord.PathTaskLoad).Data(route.Control("通知节点重启配置")).POST(rest.ReloadTask)
+	r.Route(accord.PathTaskTable).Data(route.Control("通知扫表任务")).POST(rest.TableTask)
+	r.Route(accord.PathThirdDiff).Data(route.Control("通知三方文件更新")).POST(rest.ThirdDiff)
 }
 
 func (rest *agentREST) Upgrade(c *ship.Context) error {
@@ -34,8 +37,9 @@ func (rest *agentREST) Upgrade(c *ship.Context) error {
 	}
 
 	ctx := c.Request().Context()
+	job, err := rest.svc.Upgrade(ctx, &req)
 
-	return rest.svc.Upgrade(ctx, &req)
+	return rest.reply(c, job, err)
 }
 
 func (rest *agentREST) Startup(c *ship.Context) error {
@@ -57,14 +61,18 @@ func (rest *agentREST) Command(c *ship.Context) error {
 	}
 	ctx := c.Request().Context()
 
+	var job *mlink.MulticastJob
+	var err error
 	switch req.Cmd {
 	case "resync":
-		return rest.svc.RsyncTask(ctx, req.ID)
+		job, err = rest.svc.RsyncTask(ctx, req.ID)
 	case "upgrade":
-		return rest.svc.Upgrade(ctx, &accord.Upgrade{ID: req.ID})
+		job, err = rest.svc.Upgrade(ctx, &accord.Upgrade{ID: req.ID})
+	default:
+		job, err = rest.svc.Command(ctx, req.ID, req.Cmd)
 	}
 
-	return rest.svc.Command(ctx, req.ID, req.Cmd)
+	return rest.reply(c, job, err)
 }
 
 func (rest *agentREST) Offline(c *ship.Context) error {
@@ -74,7 +82,9 @@ func (rest *agentREST) Offline(c *ship.Context) error {
 	}
 	ctx := c.Request().Context()
 
-	return rest.svc.Command(ctx, req.ID, "offline")
+	job, err := rest.svc.Command(ctx, req.ID, "offline")
+
+	return rest.reply(c, job, err)
 }
 
 func (rest *agentREST) RsyncTask(c *ship.Context) error {
@@ -84,7 +94,9 @@ func (rest *agentREST) RsyncTask(c *ship.Context) error {
 	}
 	ctx := c.Request().Context()
 
-	return rest.svc.RsyncTask(ctx, req.ID)
+	job, err := rest.svc.RsyncTask(ctx, req.ID)
+
+	return rest.reply(c, job, err)
 }
 
 func (rest *agentREST) TableTask(c *ship.Context) error {
@@ -114,5 +126,16 @@ func (rest *agentREST) ThirdDiff(c *ship.Context) error {
 	}
 	ctx := c.Request().Context()
 
-	return rest.svc.ThirdDiff(ctx, req.Name, req.Event)
+	job, err := rest.svc.ThirdDiff(ctx, req.Name, req.Event)
+
+	return rest.reply(c, job, err)
+}
+
+// reply 响应多播任务信息，调用方可以根据任务 ID 查询每个节点的执行结果。
+func (rest *agentREST) reply(c *ship.Context, job *mlink.MulticastJob, err error) error {
+	if err != nil {
+		return err
+	}
+
+	return c.JSON(http.StatusOK, job.Snapshot())
 }
@@ -6,6 +6,7 @@ import (
 	"strconv"
 	"time"
 
+	"github.com/vela-ssoc/ssoc-broker/app/route"
 	"github.com/vela-ssoc/ssoc-broker/library/pipelog"
 	"github.com/vela-ssoc/ssoc-common/eventsource"
 	"github.com/xgfone/ship/v5"
@@ -22,8 +23,8 @@ type AgentConsole struct {
 }
 
 func (ac *AgentConsole) Route(r *ship.RouteGroupBuilder) {
-	r.Route("/agent/console/read").GET(ac.read)
-	r.Route("/console/remove").POST(ac.remove)
+	r.Route("/agent/console/read").Data(route.Diag("读取节点控制台日志")).GET(ac.read)
+	r.Route("/console/remove").Data(route.Control("删除节点控制台日志")).POST(ac.remove)
 }
 
 func (ac *AgentConsole) read(c *ship.Context) error {
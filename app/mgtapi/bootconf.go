@@ -0,0 +1,36 @@
+package mgtapi
+
+import (
+	"net/http"
+
+	"github.com/vela-ssoc/ssoc-broker/app/internal/param"
+	"github.com/vela-ssoc/ssoc-broker/app/mgtsvc"
+	"github.com/vela-ssoc/ssoc-broker/app/route"
+	"github.com/xgfone/ship/v5"
+)
+
+func BootConfig(svc mgtsvc.BootConfigService) route.Router {
+	return &bootConfigREST{svc: svc}
+}
+
+type bootConfigREST struct {
+	svc mgtsvc.BootConfigService
+}
+
+func (rest *bootConfigREST) Route(r *ship.RouteGroupBuilder) {
+	r.Route("/brr/boot-config/reload").Data(route.Lifecycle("热加载启动配置")).POST(rest.Reload)
+}
+
+// Reload 中心端修改了 broker 的日志或数据库配置后调用，无需重启 broker。
+func (rest *bootConfigREST) Reload(c *ship.Context) error {
+	var req param.BootConfig
+	if err := c.Bind(&req); err != nil {
+		return err
+	}
+	ctx := c.Request().Context()
+	if err := rest.svc.Reload(ctx, req.Logger, req.Database); err != nil {
+		return err
+	}
+
+	return c.NoContent(http.StatusNoContent)
+}
@@ -0,0 +1,44 @@
+package mgtapi
+
+import (
+	"net/http"
+
+	"github.com/vela-ssoc/ssoc-broker/app/internal/param"
+	"github.com/vela-ssoc/ssoc-broker/app/route"
+	"github.com/vela-ssoc/ssoc-broker/library/collision"
+	"github.com/xgfone/ship/v5"
+)
+
+func Collision(detect collision.Detector) route.Router {
+	return &collisionREST{detect: detect}
+}
+
+type collisionREST struct {
+	detect collision.Detector
+}
+
+func (rest *collisionREST) Route(r *ship.RouteGroupBuilder) {
+	r.Route("/brr/collisions").Data(route.Diag("机器码冲突列表")).GET(rest.List)
+	r.Route("/brr/collision/resolve").Data(route.Control("处理机器码冲突")).POST(rest.Resolve)
+}
+
+func (rest *collisionREST) List(c *ship.Context) error {
+	ctx := c.Request().Context()
+	ret, err := rest.detect.Collisions(ctx)
+	if err != nil {
+		return err
+	}
+
+	return c.JSON(http.StatusOK, ret)
+}
+
+func (rest *collisionREST) Resolve(c *ship.Context) error {
+	var req param.CollisionResolve
+	if err := c.Bind(&req); err != nil {
+		return err
+	}
+	ctx := c.Request().Context()
+	c.Warnf("处理机器码 %s 的冲突：%s", req.MachineID, req.Resolution)
+
+	return rest.detect.Resolve(ctx, req.MachineID, collision.Resolution(req.Resolution))
+}
@@ -0,0 +1,26 @@
+package mgtapi
+
+import (
+	"net/http"
+
+	"github.com/vela-ssoc/ssoc-broker/app/route"
+	"github.com/vela-ssoc/ssoc-broker/library/handshake"
+	"github.com/xgfone/ship/v5"
+)
+
+func Handshake(tracker handshake.Tracker) route.Router {
+	return &handshakeREST{tracker: tracker}
+}
+
+type handshakeREST struct {
+	tracker handshake.Tracker
+}
+
+func (rest *handshakeREST) Route(r *ship.RouteGroupBuilder) {
+	r.Route("/brr/handshake/rejects").Data(route.Diag("握手报文拒绝记录")).GET(rest.Rejects)
+}
+
+func (rest *handshakeREST) Rejects(c *ship.Context) error {
+	ret := rest.tracker.Rejections()
+	return c.JSON(http.StatusOK, ret)
+}
@@ -23,8 +23,8 @@ func (rest *intoREST) Route(r *ship.RouteGroupBuilder) {
 		http.MethodDelete, http.MethodConnect, http.MethodOptions, http.MethodTrace,
 		ship.PROPFIND, "LOCK", "MKCOL", "PROPPATCH", "COPY", "MOVE", "UNLOCK",
 	}
-	r.Route("/arr/*path").Data(route.Named("ARR 直接调用")).Method(rest.ARR, methods...)
-	r.Route("/aws/*path").Data(route.Named("AWS 直接调用")).GET(rest.AWS)
+	r.Route("/arr/*path").Data(route.Raw("ARR 直接调用")).Method(rest.ARR, methods...)
+	r.Route("/aws/*path").Data(route.Raw("AWS 直接调用")).GET(rest.AWS)
 }
 
 func (rest *intoREST) ARR(c *ship.Context) error {
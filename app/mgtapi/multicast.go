@@ -0,0 +1,92 @@
+package mgtapi
+
+import (
+	"encoding/json"
+	"net/http"
+
+	"github.com/vela-ssoc/ssoc-broker/app/internal/param"
+	"github.com/vela-ssoc/ssoc-broker/app/route"
+	"github.com/vela-ssoc/ssoc-broker/bridge/mlink"
+	"github.com/xgfone/ship/v5"
+)
+
+func Multicast(hub mlink.Huber) route.Router {
+	return &multicastREST{hub: hub}
+}
+
+type multicastREST struct {
+	hub mlink.Huber
+}
+
+func (rest *multicastREST) Route(r *ship.RouteGroupBuilder) {
+	r.Route("/brr/multicast/job").Data(route.Diag("查询多播任务进度")).GET(rest.Job)
+	r.Route("/brr/multicast/job/stream").Data(route.Diag("流式读取多播任务结果")).GET(rest.Stream)
+	r.Route("/brr/multicast/job/cancel").Data(route.Control("取消多播任务")).POST(rest.Cancel)
+}
+
+func (rest *multicastREST) Job(c *ship.Context) error {
+	job, err := rest.lookup(c)
+	if err != nil {
+		return err
+	}
+
+	return c.JSON(http.StatusOK, job.Snapshot())
+}
+
+// Stream 以 NDJSON 格式逐条输出节点的执行结果，直至任务结束或客户端断开。
+func (rest *multicastREST) Stream(c *ship.Context) error {
+	job, err := rest.lookup(c)
+	if err != nil {
+		return err
+	}
+
+	ctx := c.Request().Context()
+	w := c.Response()
+	w.Header().Set(ship.HeaderContentType, "application/x-ndjson")
+	w.WriteHeader(http.StatusOK)
+	enc := json.NewEncoder(w)
+
+	var offset int
+	for {
+		results, wait, done := job.Next(offset)
+		for _, res := range results {
+			if err = enc.Encode(res); err != nil {
+				return nil
+			}
+		}
+		offset += len(results)
+		w.Flush()
+		if done {
+			return nil
+		}
+
+		select {
+		case <-ctx.Done():
+			return nil
+		case <-wait:
+		}
+	}
+}
+
+func (rest *multicastREST) Cancel(c *ship.Context) error {
+	job, err := rest.lookup(c)
+	if err != nil {
+		return err
+	}
+	job.Cancel()
+
+	return nil
+}
+
+func (rest *multicastREST) lookup(c *ship.Context) (*mlink.MulticastJob, error) {
+	var req param.MulticastJob
+	if err := c.BindQuery(&req); err != nil {
+		return nil, err
+	}
+	job := rest.hub.Job(req.ID)
+	if job == nil {
+		return nil, ship.ErrNotFound
+	}
+
+	return job, nil
+}
@@ -7,27 +7,34 @@ import (
 	"github.com/vela-ssoc/ssoc-broker/app/internal/param"
 	"github.com/vela-ssoc/ssoc-broker/app/route"
 	"github.com/vela-ssoc/ssoc-broker/bridge/telecom"
+	"github.com/vela-ssoc/ssoc-broker/library/metrics"
+	"github.com/vela-ssoc/ssoc-broker/library/outbox"
 	"github.com/xgfone/ship/v5"
 )
 
-func Pprof(lnk telecom.Linker) route.Router {
+func Pprof(lnk telecom.Linker, box outbox.Outbox) route.Router {
 	return &pprofREST{
 		lnk: lnk,
+		box: box,
 	}
 }
 
 type pprofREST struct {
 	lnk telecom.Linker
+	box outbox.Outbox
 }
 
 func (rest *pprofREST) Route(r *ship.RouteGroupBuilder) {
-	r.Route("/brr/pprof/config").Data(route.Named("pprof-config")).GET(rest.Config)
-	r.Route("/brr/pprof/index").Data(route.Named("pprof-index")).GET(rest.Index)
-	r.Route("/brr/pprof/cmdline").Data(route.Named("pprof-cmdline")).GET(rest.Cmdline)
-	r.Route("/brr/pprof/profile").Data(route.Named("pprof-profile")).GET(rest.Profile)
-	r.Route("/brr/pprof/symbol").Data(route.Named("pprof-symbol")).GET(rest.Symbol)
-	r.Route("/brr/pprof/trace").Data(route.Named("pprof-trace")).GET(rest.Trace)
-	r.Route("/brr/pprof/*path").Data(route.Named("pprof-path")).GET(rest.Path)
+	r.Route("/brr/metrics").Data(route.Diag("metrics")).GET(rest.Metrics)
+	r.Route("/brr/pprof/config").Data(route.Diag("pprof-config")).GET(rest.Config)
+	r.Route("/brr/pprof/dialer").Data(route.Diag("pprof-dialer")).GET(rest.Dialer)
+	r.Route("/brr/pprof/outbox").Data(route.Diag("pprof-outbox")).GET(rest.Outbox)
+	r.Route("/brr/pprof/index").Data(route.Diag("pprof-index")).GET(rest.Index)
+	r.Route("/brr/pprof/cmdline").Data(route.Diag("pprof-cmdline")).GET(rest.Cmdline)
+	r.Route("/brr/pprof/profile").Data(route.Diag("pprof-profile")).GET(rest.Profile)
+	r.Route("/brr/pprof/symbol").Data(route.Diag("pprof-symbol")).GET(rest.Symbol)
+	r.Route("/brr/pprof/trace").Data(route.Diag("pprof-trace")).GET(rest.Trace)
+	r.Route("/brr/pprof/*path").Data(route.Diag("pprof-path")).GET(rest.Path)
 }
 
 func (rest *pprofREST) Config(c *ship.Context) error {
@@ -36,14 +43,33 @@ func (rest *pprofREST) Config(c *ship.Context) error {
 	issue := rest.lnk.Issue()
 
 	res := &param.PprofConfig{
-		Hide:  hide,
-		Ident: ident,
-		Issue: issue,
+		Hide:      hide,
+		Ident:     ident,
+		Issue:     issue,
+		Dialer:    rest.lnk.Health(),
+		Heartbeat: rest.lnk.Heartbeat(),
+		Outbox:    rest.box.Stats(),
 	}
 
 	return c.JSON(http.StatusOK, res)
 }
 
+// Dialer 中心端各个地址的健康状态。
+func (rest *pprofREST) Dialer(c *ship.Context) error {
+	return c.JSON(http.StatusOK, rest.lnk.Health())
+}
+
+// Outbox 断线期间暂存的单向消息队列统计。
+func (rest *pprofREST) Outbox(c *ship.Context) error {
+	return c.JSON(http.StatusOK, rest.box.Stats())
+}
+
+// Metrics 经中心端通道代理拉取 broker 指标（Prometheus 文本格式）。
+func (rest *pprofREST) Metrics(c *ship.Context) error {
+	metrics.Default.ServeHTTP(c.Response(), c.Request())
+	return nil
+}
+
 func (rest *pprofREST) Index(c *ship.Context) error {
 	pprof.Index(c.Response(), c.Request())
 	return nil
@@ -0,0 +1,44 @@
+package mgtapi
+
+import (
+	"net/http"
+
+	"github.com/vela-ssoc/ssoc-broker/app/internal/param"
+	"github.com/vela-ssoc/ssoc-broker/app/route"
+	"github.com/vela-ssoc/ssoc-broker/library/identity"
+	"github.com/xgfone/ship/v5"
+)
+
+func Quarantine(verify identity.Verifier) route.Router {
+	return &quarantineREST{verify: verify}
+}
+
+type quarantineREST struct {
+	verify identity.Verifier
+}
+
+func (rest *quarantineREST) Route(r *ship.RouteGroupBuilder) {
+	r.Route("/brr/quarantines").Data(route.Diag("节点隔离列表")).GET(rest.List)
+	r.Route("/brr/quarantine/release").Data(route.Control("解除节点隔离")).POST(rest.Release)
+}
+
+func (rest *quarantineREST) List(c *ship.Context) error {
+	ctx := c.Request().Context()
+	ret, err := rest.verify.Quarantines(ctx)
+	if err != nil {
+		return err
+	}
+
+	return c.JSON(http.StatusOK, ret)
+}
+
+func (rest *quarantineREST) Release(c *ship.Context) error {
+	var req param.QuarantineRelease
+	if err := c.Bind(&req); err != nil {
+		return err
+	}
+	ctx := c.Request().Context()
+	c.Warnf("解除节点 %d 的隔离，重新绑定公钥：%t", req.ID, req.Rebind)
+
+	return rest.verify.Release(ctx, req.ID, req.Rebind)
+}
@@ -30,15 +30,15 @@ type resetREST struct {
 
 func (rest *resetREST) Route(r *ship.RouteGroupBuilder) {
 	r.Route(accord.PathElasticReset).
-		Data(route.Named("elastic 配置 reset")).POST(rest.Elastic)
+		Data(route.Lifecycle("elastic 配置 reset")).POST(rest.Elastic)
 	r.Route(accord.PathStoreReset).
-		Data(route.Named("store 配置 reset")).POST(rest.Store)
+		Data(route.Lifecycle("store 配置 reset")).POST(rest.Store)
 	r.Route(accord.PathNotifierReset).
-		Data(route.Named("告警人 reset")).POST(rest.Notifier)
+		Data(route.Lifecycle("告警人 reset")).POST(rest.Notifier)
 	r.Route(accord.PathEmcReset).
-		Data(route.Named("咚咚服务号 reset")).POST(rest.Emc)
+		Data(route.Lifecycle("咚咚服务号 reset")).POST(rest.Emc)
 	r.Route(accord.PathEmailReset).
-		Data(route.Named("邮箱发送账号 reset")).POST(rest.Email)
+		Data(route.Lifecycle("邮箱发送账号 reset")).POST(rest.Email)
 }
 
 func (rest *resetREST) Elastic(c *ship.Context) error {
@@ -0,0 +1,60 @@
+package mgtapi
+
+import (
+	"net/http"
+
+	"github.com/vela-ssoc/ssoc-broker/app/internal/param"
+	"github.com/vela-ssoc/ssoc-broker/app/mgtsvc"
+	"github.com/vela-ssoc/ssoc-broker/app/route"
+	"github.com/xgfone/ship/v5"
+)
+
+func Session(svc mgtsvc.SessionService) route.Router {
+	return &sessionREST{svc: svc}
+}
+
+type sessionREST struct {
+	svc mgtsvc.SessionService
+}
+
+func (rest *sessionREST) Route(r *ship.RouteGroupBuilder) {
+	r.Route("/brr/sessions").Data(route.Diag("节点会话列表")).GET(rest.Page)
+	r.Route("/brr/session").Data(route.Diag("节点会话详情")).GET(rest.Detail)
+	r.Route("/brr/session/features").Data(route.Diag("节点协商的特性")).GET(rest.Features)
+}
+
+func (rest *sessionREST) Page(c *ship.Context) error {
+	var req param.SessionPage
+	if err := c.BindQuery(&req); err != nil {
+		return err
+	}
+	ret := rest.svc.Page(&req)
+
+	return c.JSON(http.StatusOK, ret)
+}
+
+func (rest *sessionREST) Detail(c *ship.Context) error {
+	var req param.SessionDetail
+	if err := c.BindQuery(&req); err != nil {
+		return err
+	}
+	sess := rest.svc.Detail(req.ID)
+	if sess == nil {
+		return ship.ErrNotFound
+	}
+
+	return c.JSON(http.StatusOK, sess)
+}
+
+func (rest *sessionREST) Features(c *ship.Context) error {
+	var req param.SessionDetail
+	if err := c.BindQuery(&req); err != nil {
+		return err
+	}
+	features, ok := rest.svc.Features(req.ID)
+	if !ok {
+		return ship.ErrNotFound
+	}
+
+	return c.JSON(http.StatusOK, features)
+}
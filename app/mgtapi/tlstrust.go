@@ -0,0 +1,39 @@
+package mgtapi
+
+import (
+	"net/http"
+
+	"github.com/vela-ssoc/ssoc-broker/app/internal/param"
+	"github.com/vela-ssoc/ssoc-broker/app/route"
+	"github.com/vela-ssoc/ssoc-broker/library/tlstrust"
+	"github.com/xgfone/ship/v5"
+)
+
+func TLSTrust(trust tlstrust.Trust) route.Router {
+	return &tlsTrustREST{trust: trust}
+}
+
+type tlsTrustREST struct {
+	trust tlstrust.Trust
+}
+
+func (rest *tlsTrustREST) Route(r *ship.RouteGroupBuilder) {
+	r.Route("/brr/tls/pins").Data(route.Diag("查看中心端证书指纹")).GET(rest.Pins)
+	r.Route("/brr/tls/pins/rotate").Data(route.Lifecycle("轮换中心端证书指纹")).POST(rest.Rotate)
+}
+
+func (rest *tlsTrustREST) Pins(c *ship.Context) error {
+	res := &param.TLSPins{Pins: rest.trust.Pins()}
+	return c.JSON(http.StatusOK, res)
+}
+
+// Rotate 中心端更换证书前下发新的指纹（一般同时包含新旧证书的指纹），对之后建立的连接生效。
+// 下发空的指纹列表会清除指纹校验，之后按照 CA 或系统根证书校验。
+func (rest *tlsTrustREST) Rotate(c *ship.Context) error {
+	var req param.TLSPins
+	if err := c.Bind(&req); err != nil {
+		return err
+	}
+
+	return rest.trust.SetPins(req.Pins)
+}
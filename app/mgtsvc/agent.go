@@ -5,6 +5,7 @@ import (
 	"log/slog"
 
 	"github.com/vela-ssoc/ssoc-broker/bridge/mlink"
+	"github.com/vela-ssoc/ssoc-broker/library/metrics"
 	"github.com/vela-ssoc/ssoc-common-mb/accord"
 	"github.com/vela-ssoc/ssoc-common-mb/dal/query"
 	"github.com/vela-ssoc/ssoc-common-mb/gopool"
@@ -15,8 +16,11 @@ type AgentService interface {
 	// TableTask 同步表任务。
 	TableTask(ctx context.Context, tid int64) error
 
-	// RsyncTask 同步 agent 节点的配置。
-	RsyncTask(ctx context.Context, mids []int64) error
+	// RsyncTask 同步 agent 节点的配置，返回可查询进度的多播任务，仅供 manager 调用。
+	RsyncTask(ctx context.Context, mids []int64) (*mlink.MulticastJob, error)
+
+	// NotifyRsync 在后台同步单个节点的配置，不创建多播任务，用于节点上线、标签变更等内部触发。
+	NotifyRsync(ctx context.Context, mid int64)
 
 	// ReloadTask 重新加载指定节点的指定配置。
 	ReloadTask(ctx context.Context, mid, sid int64) error
@@ -24,14 +28,14 @@ type AgentService interface {
 	// ReloadStartup 重新加载指定节点的 startup 配置。
 	ReloadStartup(ctx context.Context, mid int64) error
 
-	// ThirdDiff 通知 agent 节点三方文件发生了变更。
-	ThirdDiff(ctx context.Context, name, event string) error
+	// ThirdDiff 通知所有在线节点三方文件发生了变更。
+	ThirdDiff(ctx context.Context, name, event string) (*mlink.MulticastJob, error)
 
 	// Command 向节点发送命令
-	Command(ctx context.Context, mids []int64, cmd string) error
+	Command(ctx context.Context, mids []int64, cmd string) (*mlink.MulticastJob, error)
 
 	// Upgrade 向节点发送升级命令
-	Upgrade(ctx context.Context, req *accord.Upgrade) error
+	Upgrade(ctx context.Context, req *accord.Upgrade) (*mlink.MulticastJob, error)
 }
 
 func Agent(qry *query.Query, lnk mlink.Linker, mon MinionService, store storage.Storer, log *slog.Logger) AgentService {
@@ -41,8 +45,9 @@ func Agent(qry *query.Query, lnk mlink.Linker, mon MinionService, store storage.
 		mon:   mon,
 		store: store,
 		log:   log,
-		pool:  gopool.New(512),
-		cycle: 5,
+		pool:  metrics.WrapPool("agent", 512, gopool.New(512)),
+
+		taskRsync: newTaskRsync(qry, lnk, mon),
 	}
 }
 
@@ -53,5 +58,6 @@ type agentService struct {
 	store storage.Storer
 	log   *slog.Logger
 	pool  gopool.Pool
-	cycle int
+
+	*taskRsync
 }
@@ -2,31 +2,12 @@ package mgtsvc
 
 import (
 	"context"
-	"time"
+
+	"github.com/vela-ssoc/ssoc-broker/bridge/mlink"
 )
 
-func (biz *agentService) broadcast(path string, data any) {
+// broadcast 通知所有在线节点，返回多播任务，调用方可以根据任务 ID 查询每个节点的调用结果。
+func (biz *agentService) broadcast(ctx context.Context, path string, data any) *mlink.MulticastJob {
 	ids := biz.lnk.ConnectIDs()
-	for _, mid := range ids {
-		task := &messageTask{
-			biz:  biz,
-			mid:  mid,
-			path: path,
-			data: data,
-		}
-		biz.pool.Go(task.Run)
-	}
-}
-
-type messageTask struct {
-	biz  *agentService
-	mid  int64
-	path string
-	data any
-}
-
-func (mt *messageTask) Run() {
-	ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
-	defer cancel()
-	_ = mt.biz.lnk.Oneway(ctx, mt.mid, mt.path, mt.data)
+	return biz.lnk.Multicast(ctx, ids, path, data)
 }
@@ -2,35 +2,27 @@ package mgtsvc
 
 import (
 	"context"
-	"time"
 
+	"github.com/vela-ssoc/ssoc-broker/bridge/mlink"
+	"github.com/vela-ssoc/ssoc-broker/library/capability"
 	"github.com/vela-ssoc/ssoc-common-mb/accord"
 )
 
-func (biz *agentService) Command(_ context.Context, mids []int64, cmd string) error {
-	for _, mid := range mids {
-		task := &commandTask{biz: biz, mid: mid, cmd: cmd}
-		biz.pool.Go(task.Run)
-	}
-	return nil
-}
-
-type commandTask struct {
-	biz *agentService
-	mid int64
-	cmd string
-}
-
-func (ct *commandTask) Run() {
-	ctx, cancel := context.WithTimeout(context.Background(), time.Minute)
-	defer cancel()
-
-	dat := &accord.Command{Cmd: ct.cmd}
+func (biz *agentService) Command(ctx context.Context, mids []int64, cmd string) (*mlink.MulticastJob, error) {
+	dat := &accord.Command{Cmd: cmd}
 	path := "/api/v1/agent/notice/command"
+	lnk := biz.lnk
+
+	job := lnk.MulticastFunc(ctx, mids, path, func(cctx context.Context, mid int64) error {
+		features, _ := lnk.Features(mid)
+		err := lnk.Oneway(cctx, mid, path, dat)
+		// 支持类型化命令的节点处理完 offline/restart 后会自行断开连接，
+		// 不再强制踢下线，防止命令还未处理连接就被断开。
+		if (cmd == "offline" || cmd == "restart") && !features.Has(capability.TypedCommand) {
+			lnk.Knockout(mid)
+		}
+		return err
+	})
 
-	lnk := ct.biz.lnk
-	_ = lnk.Oneway(ctx, ct.mid, path, dat)
-	if ct.cmd == "offline" || ct.cmd == "restart" {
-		lnk.Knockout(ct.mid)
-	}
+	return job, nil
 }
@@ -2,21 +2,69 @@ package mgtsvc
 
 import (
 	"context"
+	"log/slog"
 	"time"
 
 	"github.com/vela-ssoc/ssoc-broker/app/internal/param"
+	"github.com/vela-ssoc/ssoc-broker/bridge/mlink"
 	"github.com/vela-ssoc/ssoc-common-mb/dal/model"
+	"github.com/vela-ssoc/ssoc-common-mb/dal/query"
 )
 
-func (biz *agentService) RsyncTask(_ context.Context, mids []int64) error {
-	for _, mid := range mids {
-		task := &rsyncTask{biz: biz, mid: mid}
-		biz.pool.Go(task.Run)
+// Unicaster 向单个节点发送请求，旧通道由 mlink.Huber 实现。
+type Unicaster interface {
+	Unicast(ctx context.Context, id int64, path string, body, resp any) error
+}
+
+// TaskRsyncer 将节点运行的配置与数据库中关联的配置对齐。
+type TaskRsyncer interface {
+	Rsync(ctx context.Context, mid int64) error
+}
+
+// TaskRsync 配置同步，与通道无关，新旧通道共用。
+func TaskRsync(qry *query.Query, uni Unicaster, mon MinionService) TaskRsyncer {
+	return newTaskRsync(qry, uni, mon)
+}
+
+func newTaskRsync(qry *query.Query, uni Unicaster, mon MinionService) *taskRsync {
+	return &taskRsync{qry: qry, uni: uni, mon: mon, cycle: 5}
+}
+
+type taskRsync struct {
+	qry   *query.Query
+	uni   Unicaster
+	mon   MinionService
+	cycle int
+}
+
+func (biz *agentService) RsyncTask(ctx context.Context, mids []int64) (*mlink.MulticastJob, error) {
+	job := biz.lnk.MulticastFunc(ctx, mids, "/api/v1/agent/task/diff", biz.rsyncTask)
+	return job, nil
+}
+
+func (biz *agentService) NotifyRsync(_ context.Context, mid int64) {
+	task := &rsyncTask{biz: biz, mid: mid}
+	biz.pool.Go(task.Run)
+}
+
+type rsyncTask struct {
+	biz *agentService
+	mid int64
+}
+
+func (rt *rsyncTask) Run() {
+	ctx, cancel := context.WithTimeout(context.Background(), time.Minute)
+	defer cancel()
+	if err := rt.biz.rsyncTask(ctx, rt.mid); err != nil {
+		rt.biz.log.Debug("同步节点配置出错", slog.Int64("minion_id", rt.mid), slog.Any("error", err))
 	}
-	return nil
 }
 
-func (biz *agentService) rsyncTask(ctx context.Context, mid int64) error {
+func (biz *taskRsync) Rsync(ctx context.Context, mid int64) error {
+	return biz.rsyncTask(ctx, mid)
+}
+
+func (biz *taskRsync) rsyncTask(ctx context.Context, mid int64) error {
 	// 查询节点信息
 	light, err := biz.mon.LightID(ctx, mid)
 	if err != nil {
@@ -26,7 +74,7 @@ func (biz *agentService) rsyncTask(ctx context.Context, mid int64) error {
 	return biz.rsync(ctx, light)
 }
 
-func (biz *agentService) rsync(ctx context.Context, light *param.MinionLight) error {
+func (biz *taskRsync) rsync(ctx context.Context, light *param.MinionLight) error {
 	mid, inet := light.ID, light.Inet
 	report, err := biz.fetchTaskStatus(ctx, mid) // 拉取最新上报的配置运行状态
 	if err != nil {
@@ -53,7 +101,7 @@ func (biz *agentService) rsync(ctx context.Context, light *param.MinionLight) er
 	return err
 }
 
-func (biz *agentService) spinRsync(ctx context.Context, light *param.MinionLight, report *param.TaskReport, subs model.Substances) (*param.TaskReport, error) {
+func (biz *taskRsync) spinRsync(ctx context.Context, light *param.MinionLight, report *param.TaskReport, subs model.Substances) (*param.TaskReport, error) {
 	var cycle int
 	mid := light.ID
 	subs.Sort()
@@ -71,31 +119,20 @@ func (biz *agentService) spinRsync(ctx context.Context, light *param.MinionLight
 	return report, nil
 }
 
-func (biz *agentService) fetchRsync(ctx context.Context, mid int64, diff *param.TaskDiff) (*param.TaskReport, error) {
+func (biz *taskRsync) fetchRsync(ctx context.Context, mid int64, diff *param.TaskDiff) (*param.TaskReport, error) {
 	path := "/api/v1/agent/task/diff"
 	ret := new(param.TaskReport)
-	if err := biz.lnk.Unicast(ctx, mid, path, diff, ret); err != nil {
+	if err := biz.uni.Unicast(ctx, mid, path, diff, ret); err != nil {
 		return nil, err
 	}
 	return ret, nil
 }
 
-func (biz *agentService) fetchTaskStatus(ctx context.Context, mid int64) (*param.TaskReport, error) {
+func (biz *taskRsync) fetchTaskStatus(ctx context.Context, mid int64) (*param.TaskReport, error) {
 	path := "/api/v1/agent/task/status"
 	ret := new(param.TaskReport)
-	if err := biz.lnk.Unicast(ctx, mid, path, nil, ret); err != nil {
+	if err := biz.uni.Unicast(ctx, mid, path, nil, ret); err != nil {
 		return nil, err
 	}
 	return ret, nil
 }
-
-type rsyncTask struct {
-	biz *agentService
-	mid int64
-}
-
-func (rt *rsyncTask) Run() {
-	ctx, cancel := context.WithTimeout(context.Background(), time.Minute)
-	defer cancel()
-	_ = rt.biz.rsyncTask(ctx, rt.mid)
-}
@@ -3,11 +3,13 @@ package mgtsvc
 import (
 	"context"
 
+	"github.com/vela-ssoc/ssoc-broker/bridge/mlink"
 	"github.com/vela-ssoc/ssoc-common-mb/accord"
 )
 
-func (biz *agentService) ThirdDiff(_ context.Context, name, event string) error {
+func (biz *agentService) ThirdDiff(ctx context.Context, name, event string) (*mlink.MulticastJob, error) {
 	req := &accord.ThirdDiff{Name: name, Event: event}
-	go biz.broadcast("/api/v1/agent/third/diff", req)
-	return nil
+	job := biz.broadcast(ctx, "/api/v1/agent/third/diff", req)
+
+	return job, nil
 }
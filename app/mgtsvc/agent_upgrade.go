@@ -3,22 +3,14 @@ package mgtsvc
 import (
 	"context"
 
+	"github.com/vela-ssoc/ssoc-broker/bridge/mlink"
 	"github.com/vela-ssoc/ssoc-common-mb/accord"
 )
 
-func (biz *agentService) Upgrade(_ context.Context, req *accord.Upgrade) error {
+func (biz *agentService) Upgrade(ctx context.Context, req *accord.Upgrade) (*mlink.MulticastJob, error) {
 	path := "/api/v1/agent/notice/upgrade"
 	data := &accord.Upgrade{Semver: req.Semver, Customized: req.Customized}
+	job := biz.lnk.Multicast(ctx, req.ID, path, data)
 
-	for _, mid := range req.ID {
-		task := &messageTask{
-			biz:  biz,
-			mid:  mid,
-			path: path,
-			data: data,
-		}
-		biz.pool.Go(task.Run)
-	}
-
-	return nil
+	return job, nil
 }
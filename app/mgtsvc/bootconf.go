@@ -0,0 +1,114 @@
+package mgtsvc
+
+import (
+	"context"
+	"log/slog"
+	"sync"
+
+	"github.com/vela-ssoc/ssoc-broker/library/hotdb"
+	"github.com/vela-ssoc/ssoc-common-mb/profile"
+	"github.com/vela-ssoc/ssoc-common/logger"
+)
+
+type BootConfigService interface {
+	// Reload 热加载中心端下发的日志与数据库配置，未变化的部分不做处理。
+	Reload(ctx context.Context, lc profile.Logger, dbc profile.Database) error
+
+	// Close 关闭日志文件。
+	Close() error
+}
+
+// BootConfig 创建启动配置热加载服务，lc 为认证时下发的日志配置。
+//
+// 启动时保持控制台输出，之后下发的日志配置与 lc 不一致时才切换。
+func BootConfig(db hotdb.DB, logh logger.Handler, lc profile.Logger, log *slog.Logger) BootConfigService {
+	return &bootConfigService{db: db, logh: logh, log: log, lcfg: lc}
+}
+
+type bootConfigService struct {
+	db    hotdb.DB
+	logh  logger.Handler
+	log   *slog.Logger
+	mutex sync.Mutex
+	lcfg  profile.Logger
+	lw    profile.LogWriter
+}
+
+func (biz *bootConfigService) Reload(ctx context.Context, lc profile.Logger, dbc profile.Database) error {
+	if dbc.DSN != "" {
+		cfg := DatabaseConfig(dbc)
+		if err := biz.db.Apply(ctx, cfg); err != nil {
+			return err
+		}
+	}
+	biz.swapLogger(lc)
+
+	return nil
+}
+
+func (biz *bootConfigService) Close() error {
+	biz.mutex.Lock()
+	defer biz.mutex.Unlock()
+
+	return biz.lcfg.Close()
+}
+
+// swapLogger 替换日志输出：配置未变化时保持现有输出（包括启动时的控制台输出），
+// 仅日志级别变化时原地调整级别，否则重建输出并替换日志 handler。未下发日志配置时保持现有输出。
+func (biz *bootConfigService) swapLogger(lc profile.Logger) {
+	if lc.Logger == nil && !lc.Console && lc.Level == "" {
+		return
+	}
+
+	biz.mutex.Lock()
+	defer biz.mutex.Unlock()
+
+	old := biz.lcfg
+	if sameOutput(old, lc) {
+		if old.Level == lc.Level {
+			return
+		}
+		if biz.lw != nil {
+			_ = biz.lw.Level().UnmarshalText([]byte(lc.Level))
+			biz.lcfg.Level = lc.Level
+			biz.log.Info("日志级别已更新", slog.String("level", lc.Level))
+			return
+		}
+	}
+
+	lw := lc.LogWriter()
+	opt := &slog.HandlerOptions{AddSource: true, Level: lw.Level()}
+	biz.logh.Replace(logger.NewTint(lw, opt))
+	_ = old.Close()
+	biz.lcfg, biz.lw = lc, lw
+	biz.log.Info("日志输出已切换", slog.Bool("console", lc.Console), slog.String("level", lc.Level))
+}
+
+// sameOutput 判断两份日志配置的输出目标是否一致。
+func sameOutput(a, b profile.Logger) bool {
+	if a.Console != b.Console {
+		return false
+	}
+	la, lb := a.Logger, b.Logger
+	if la == nil || lb == nil {
+		return la == lb
+	}
+
+	return la.Filename == lb.Filename &&
+		la.MaxSize == lb.MaxSize &&
+		la.MaxAge == lb.MaxAge &&
+		la.MaxBackups == lb.MaxBackups &&
+		la.LocalTime == lb.LocalTime &&
+		la.Compress == lb.Compress
+}
+
+// DatabaseConfig 将中心端下发的数据库配置转为连接池配置。
+func DatabaseConfig(dbc profile.Database) hotdb.Config {
+	return hotdb.Config{
+		DSN:         dbc.DSN,
+		MaxOpenConn: dbc.MaxOpenConn,
+		MaxIdleConn: dbc.MaxIdleConn,
+		MaxLifeTime: dbc.MaxLifeTime.Duration(),
+		MaxIdleTime: dbc.MaxIdleTime.Duration(),
+	}
+}
@@ -0,0 +1,99 @@
+package mgtsvc
+
+import (
+	"cmp"
+	"slices"
+	"strings"
+
+	"github.com/vela-ssoc/ssoc-broker/app/internal/param"
+	"github.com/vela-ssoc/ssoc-broker/bridge/mlink"
+	"github.com/vela-ssoc/ssoc-broker/library/capability"
+)
+
+type SessionService interface {
+	// Page 分页查询当前 broker 上的节点连接会话。
+	Page(req *param.SessionPage) *param.SessionResult
+
+	// Detail 查询单个节点的会话，节点不在线返回 nil。
+	Detail(id int64) *mlink.Session
+
+	// Features 查询在线节点协商的特性，节点不在线返回 false。
+	Features(id int64) (capability.Set, bool)
+}
+
+func Session(hub mlink.Huber) SessionService {
+	return &sessionService{hub: hub}
+}
+
+type sessionService struct {
+	hub mlink.Huber
+}
+
+func (biz *sessionService) Page(req *param.SessionPage) *param.SessionResult {
+	sessions := biz.hub.Sessions()
+	if kw := req.Keyword; kw != "" {
+		sessions = slices.DeleteFunc(sessions, func(s *mlink.Session) bool {
+			return !strings.Contains(s.Inet, kw) &&
+				!strings.Contains(s.MachineID, kw) &&
+				!strings.Contains(s.Hostname, kw) &&
+				!strings.Contains(s.RemoteAddr, kw)
+		})
+	}
+
+	less := biz.lessFunc(req.Order)
+	slices.SortFunc(sessions, func(a, b *mlink.Session) int {
+		if less(a, b) {
+			return 1
+		} else if less(b, a) {
+			return -1
+		}
+		return cmp.Compare(a.ID, b.ID)
+	})
+
+	total := len(sessions)
+	page, size := req.Page, req.Size
+	if page <= 0 {
+		page = 1
+	}
+	if size <= 0 {
+		size = 20
+	}
+	start := min((page-1)*size, total)
+	end := min(start+size, total)
+
+	return &param.SessionResult{Total: total, Records: sessions[start:end]}
+}
+
+func (biz *sessionService) Detail(id int64) *mlink.Session {
+	for _, sess := range biz.hub.Sessions() {
+		if sess.ID == id {
+			return sess
+		}
+	}
+
+	return nil
+}
+
+func (biz *sessionService) Features(id int64) (capability.Set, bool) {
+	return biz.hub.Features(id)
+}
+
+// lessFunc 根据排序字段返回比较函数，Page 按照该字段降序排列。
+func (*sessionService) lessFunc(order string) func(a, b *mlink.Session) bool {
+	switch order {
+	case "active_at":
+		return func(a, b *mlink.Session) bool { return a.ActiveAt.Before(b.ActiveAt) }
+	case "bytes_in":
+		return func(a, b *mlink.Session) bool { return a.BytesIn < b.BytesIn }
+	case "bytes_out":
+		return func(a, b *mlink.Session) bool { return a.BytesOut < b.BytesOut }
+	case "streams":
+		return func(a, b *mlink.Session) bool { return a.Streams < b.Streams }
+	case "requests":
+		return func(a, b *mlink.Session) bool { return a.Requests < b.Requests }
+	case "errors":
+		return func(a, b *mlink.Session) bool { return a.Errors < b.Errors }
+	default:
+		return func(a, b *mlink.Session) bool { return a.ConnectedAt.Before(b.ConnectedAt) }
+	}
+}
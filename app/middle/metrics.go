@@ -0,0 +1,17 @@
+package middle
+
+import (
+	"github.com/vela-ssoc/ssoc-broker/app/route"
+	"github.com/vela-ssoc/ssoc-broker/library/metrics"
+	"github.com/xgfone/ship/v5"
+)
+
+// Metrics 按照路由名（route.Named）统计接口耗时，未命名的路由使用路由路径。
+func Metrics(router string) ship.Middleware {
+	return metrics.Middleware(router, func(c *ship.Context) string {
+		if desc, ok := c.Route.Data.(route.Describer); ok {
+			return desc.Name()
+		}
+		return ""
+	})
+}
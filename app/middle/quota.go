@@ -0,0 +1,29 @@
+package middle
+
+import (
+	"errors"
+	"strconv"
+
+	"github.com/vela-ssoc/ssoc-broker/library/quota"
+	"github.com/xgfone/ship/v5"
+)
+
+// AgentQuota 限制节点的读取速率，并按照路由族限制节点请求报文的大小。
+func AgentQuota(h ship.Handler) ship.Handler {
+	return func(c *ship.Context) error {
+		r := c.Request()
+		guard := quota.FromContext(r.Context())
+		if err := guard.Throttle(); err != nil {
+			var te *quota.ThrottleError
+			if errors.As(err, &te) {
+				c.SetRespHeader("Retry-After", strconv.Itoa(te.Seconds()))
+			}
+			return ship.ErrTooManyRequests.New(err)
+		}
+		if err := guard.LimitBody(c.Response(), r); err != nil {
+			return ship.ErrStatusRequestEntityTooLarge.New(err)
+		}
+
+		return h(c)
+	}
+}
@@ -0,0 +1,71 @@
+package middle
+
+import (
+	"errors"
+	"log/slog"
+
+	"github.com/vela-ssoc/ssoc-broker/app/route"
+	"github.com/vela-ssoc/ssoc-broker/library/scopeauth"
+	"github.com/xgfone/ship/v5"
+)
+
+// ManagerScope 校验中心端调用携带的令牌是否拥有路由声明的授权范围。
+//
+// 未声明授权范围的路由按 broker 生命周期处理（权限最高），避免新增接口时遗漏声明而被越权调用。
+// 不论是否校验都会删除令牌请求头，防止透传类接口将其转发给节点。所有未通过校验的请求都会记录审计日志，
+// 只有 enforce 模式才会拒绝请求，audit 模式用于中心端全部升级之前观察。
+// 令牌是一次性的，中心端每次调用（包括重试）都要签发新的令牌。
+func ManagerScope(auth scopeauth.Authority, mode scopeauth.Mode, log *slog.Logger) ship.Middleware {
+	mode = mode.Format()
+	return func(h ship.Handler) ship.Handler {
+		return func(c *ship.Context) error {
+			r := c.Request()
+			token := r.Header.Get(scopeauth.Header)
+			r.Header.Del(scopeauth.Header)
+			if mode == scopeauth.ModeOff {
+				return h(c)
+			}
+
+			var name string
+			scope := scopeauth.ScopeBrokerLifecycle
+			if desc, ok := c.Route.Data.(route.Describer); ok {
+				name = desc.Name()
+				if s := desc.Scope(); s != "" {
+					scope = s
+				}
+			}
+
+			claims, err := auth.Verify(token)
+			if err == nil && !claims.Has(scope) {
+				err = scopeauth.ErrScopeDenied
+			}
+			if err == nil {
+				return h(c)
+			}
+
+			msg := "[审计] 中心端调用被拒绝"
+			if mode != scopeauth.ModeEnforce {
+				msg = "[审计] 中心端调用未通过令牌校验（仅审计，未拒绝）"
+			}
+			log.Warn(msg,
+				slog.String("mode", string(mode)),
+				slog.String("route", name),
+				slog.String("method", r.Method),
+				slog.String("path", r.URL.Path),
+				slog.String("scope", string(scope)),
+				slog.String("subject", claims.Subject),
+				slog.String("token_id", claims.ID),
+				slog.Any("scopes", claims.Scopes),
+				slog.Any("error", err),
+			)
+			if mode != scopeauth.ModeEnforce {
+				return h(c)
+			}
+			if errors.Is(err, scopeauth.ErrScopeDenied) {
+				return ship.ErrForbidden.New(err)
+			}
+
+			return ship.ErrUnauthorized.New(err)
+		}
+	}
+}
@@ -0,0 +1,19 @@
+package middle
+
+import (
+	"github.com/vela-ssoc/ssoc-broker/bridge/mlink"
+	"github.com/xgfone/ship/v5"
+)
+
+// AgentStat 按照路由统计节点的请求次数与出错次数。
+func AgentStat(h ship.Handler) ship.Handler {
+	return func(c *ship.Context) error {
+		err := h(c)
+		if infer := mlink.Ctx(c.Request().Context()); infer != nil {
+			failed := err != nil || c.StatusCode() >= 400
+			infer.Record(c.Route.Path, failed)
+		}
+
+		return err
+	}
+}
@@ -1,10 +1,17 @@
 package route
 
-import "time"
+import (
+	"time"
+
+	"github.com/vela-ssoc/ssoc-broker/library/scopeauth"
+)
 
 type Describer interface {
 	Ignore(du time.Duration) bool
 	Name() string
+
+	// Scope 中心端调用该接口需要的授权范围，为空代表未声明。
+	Scope() scopeauth.Scope
 }
 
 func Named(name string) Describer {
@@ -15,13 +22,35 @@ func Ignore() Describer {
 	return routeDesc{ignore: true}
 }
 
+// Diag 只读诊断类接口。
+func Diag(name string) Describer {
+	return routeDesc{name: name, scope: scopeauth.ScopeDiagRead}
+}
+
+// Control 节点控制类接口。
+func Control(name string) Describer {
+	return routeDesc{name: name, scope: scopeauth.ScopeAgentControl}
+}
+
+// Raw 节点原始访问类接口。
+func Raw(name string) Describer {
+	return routeDesc{name: name, scope: scopeauth.ScopeAgentRaw}
+}
+
+// Lifecycle broker 生命周期类接口。
+func Lifecycle(name string) Describer {
+	return routeDesc{name: name, scope: scopeauth.ScopeBrokerLifecycle}
+}
+
 type routeDesc struct {
 	ignore  bool
 	name    string
+	scope   scopeauth.Scope
 	timeout time.Duration
 }
 
-func (r routeDesc) Name() string { return r.name }
+func (r routeDesc) Name() string           { return r.name }
+func (r routeDesc) Scope() scopeauth.Scope { return r.scope }
 func (r routeDesc) Ignore(du time.Duration) bool {
 	if r.ignore {
 		return true
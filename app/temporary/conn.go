@@ -10,6 +10,9 @@ import (
 	"github.com/xgfone/ship/v5"
 )
 
+// defaultTimeout Handler 没有指定读超时时使用的默认值。
+const defaultTimeout = 15 * time.Minute
+
 type Conn struct {
 	conn      *websocket.Conn
 	ident     Ident
@@ -30,7 +33,12 @@ func (c *Conn) ID() int64 {
 	return c.claim.ID
 }
 
+// Inet 节点的主地址，IPv6-only 节点返回 IPv6 地址。
 func (c *Conn) Inet() net.IP {
+	if len(c.ident.Inet) == 0 {
+		return c.ident.Inet6
+	}
+
 	return c.ident.Inet
 }
 
@@ -65,7 +73,12 @@ func (c *Conn) receive(timeout time.Duration) (*Receive, error) {
 		return nil, io.EOF
 	}
 
-	_ = c.conn.SetReadDeadline(time.Now().Add(timeout))
+	if timeout <= 0 {
+		timeout = defaultTimeout
+	}
+	if err := c.conn.SetReadDeadline(time.Now().Add(timeout)); err != nil {
+		return nil, err
+	}
 	_, raw, err := c.conn.ReadMessage()
 	if err != nil {
 		return nil, err
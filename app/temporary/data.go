@@ -9,7 +9,7 @@ import (
 )
 
 type Ident struct {
-	Inet    net.IP           `json:"inet"    validate:"required"`
+	Inet    net.IP           `json:"inet"` // IPv6-only 节点可以为空，与 Inet6 至少有一个有效
 	Inet6   net.IP           `json:"inet6"`
 	MAC     net.HardwareAddr `json:"mac"`
 	Goos    string           `json:"goos"    validate:"oneof=linux windows darwin"`
@@ -16,6 +16,7 @@ import (
 	"github.com/vela-ssoc/ssoc-broker/app/temporary"
 	"github.com/vela-ssoc/ssoc-broker/app/temporary/linkhub/concurrent"
 	"github.com/vela-ssoc/ssoc-broker/bridge/telecom"
+	"github.com/vela-ssoc/ssoc-broker/library/inetx"
 	"github.com/vela-ssoc/ssoc-common-mb/dal/gridfs"
 	"github.com/vela-ssoc/ssoc-common-mb/dal/model"
 	"github.com/vela-ssoc/ssoc-common-mb/dal/query"
@@ -88,19 +89,21 @@ func New(db *gorm.DB, qry *query.Query, brk telecom.Linker, log *slog.Logger, gf
 }
 
 func (hub *minionHub) Authorize(ident temporary.Ident) (claim temporary.Claim, err error) {
-	var inet string
-	if ip4 := ident.Inet.To4(); ip4 != nil {
-		inet = ip4.String()
-	}
-	var inet6 string
-	if ip16 := ident.Inet6.To16(); ip16 != nil {
-		inet6 = ip16.String()
+	pair := inetx.FromIP(ident.Inet, ident.Inet6)
+	if !pair.Valid() {
+		err = ship.ErrBadRequest
+		return
 	}
+	inet, inet6 := pair.Inet(), pair.Inet6()
 	mac := ident.MAC.String()
 
-	// 通过inet查询节点信息
+	// 通过 inet 查询节点信息，IPv6-only 节点通过 inet6 查询
 	var mn model.Minion
-	if err = hub.db.Take(&mn, "inet = ?", inet).Error; err != nil {
+	db := hub.db.Where("inet = ?", inet)
+	if inet == "" {
+		db = hub.db.Where("inet = '' AND inet6 = ?", inet6)
+	}
+	if err = db.Take(&mn).Error; err != nil {
 		if !errors.Is(err, gorm.ErrRecordNotFound) {
 			hub.log.Warn("minion 节点新增错误", slog.Any("error", err))
 			err = ship.ErrBadRequest
@@ -119,10 +122,12 @@ func (hub *minionHub) Authorize(ident temporary.Ident) (claim temporary.Claim, e
 		}
 		mid := mn.ID
 		tags := []*model.MinionTag{
-			{Tag: inet, MinionID: mid, Kind: model.TkLifelong},
 			{Tag: ident.Arch, MinionID: mid, Kind: model.TkLifelong},
 			{Tag: ident.Goos, MinionID: mid, Kind: model.TkLifelong},
 		}
+		for _, tag := range pair.Tags() {
+			tags = append(tags, &model.MinionTag{Tag: tag, MinionID: mid, Kind: model.TkLifelong})
+		}
 		hub.db.Clauses(clause.OnConflict{DoNothing: true}).Create(tags)
 	}
 	if mn.Status == model.MSDelete { // 标记为删除则禁止登录
@@ -145,12 +150,9 @@ func (hub *minionHub) Timeout() time.Duration {
 }
 
 func (hub *minionHub) Connect(conn *temporary.Conn) {
-	inet, minionID := conn.Inet(), conn.ID()
-	ident := conn.Ident()
-	var inet6 string
-	if ipv6 := ident.Inet6.To16(); ipv6 != nil {
-		inet6 = ipv6.String()
-	}
+	ident, minionID := conn.Ident(), conn.ID()
+	pair := inetx.FromIP(ident.Inet, ident.Inet6)
+	inet, inet6 := pair.Primary(), pair.Inet6()
 	mac := ident.MAC.String()
 	goos, arch, edition := ident.Goos, ident.Arch, ident.Edition
 
@@ -3,10 +3,16 @@ package temporary
 import (
 	"encoding/binary"
 	"encoding/json"
+	"fmt"
+	"io"
 
+	"github.com/vela-ssoc/ssoc-broker/library/handshake"
 	"github.com/xgfone/ship/v5"
 )
 
+// maxMessage 单条消息的大小上限。
+const maxMessage = 8 * 1024 * 1024
+
 type Receive struct {
 	minionID  int64          // 来源节点 ID: minion 节点 ID
 	opcode    Opcode         // 操作码
@@ -35,10 +41,12 @@ func (r Receive) JSON(v any) error {
 	return r.validator.Validate(v)
 }
 
-// unmarshal 处理字节数据
+// unmarshal 处理字节数据，消息至少包含 2 字节的操作码，且不能超过 maxMessage。
 func (r *Receive) unmarshal(raw []byte) error {
-	if len(raw) < 2 {
-		return &json.SyntaxError{Offset: 2}
+	if size := len(raw); size < 2 {
+		return handshake.Reject(handshake.ReasonTruncated, io.ErrUnexpectedEOF)
+	} else if size > maxMessage {
+		return handshake.Reject(handshake.ReasonOversize, fmt.Errorf("消息长度 %d 超过上限 %d", size, maxMessage))
 	}
 
 	if mask := r.mask; mask != 0 {
@@ -0,0 +1,39 @@
+package temporary
+
+import (
+	"encoding/binary"
+	"testing"
+
+	"github.com/vela-ssoc/ssoc-broker/library/handshake"
+)
+
+func FuzzReceiveUnmarshal(f *testing.F) {
+	f.Add([]byte{0, 1, '{', '}'}, byte(0))
+	f.Add([]byte{0x12, 0x34}, byte(0x5a))
+	f.Add([]byte{0}, byte(0))
+	f.Add([]byte{}, byte(0xff))
+
+	f.Fuzz(func(t *testing.T, raw []byte, mask byte) {
+		plain := make([]byte, len(raw))
+		for i, b := range raw {
+			plain[i] = b ^ mask
+		}
+
+		r := &Receive{mask: mask}
+		if err := r.unmarshal(raw); err != nil {
+			if len(raw) >= 2 && len(raw) <= maxMessage {
+				t.Fatalf("长度 %d 的消息不应出错：%v", len(raw), err)
+			}
+			if reason := handshake.Classify(err); reason != handshake.ReasonTruncated && reason != handshake.ReasonOversize {
+				t.Fatalf("拒绝原因 %s 不正确", reason)
+			}
+			return
+		}
+		if want := Opcode(binary.BigEndian.Uint16(plain)); r.opcode != want {
+			t.Fatalf("操作码 %d，期望 %d", r.opcode, want)
+		}
+		if string(r.data) != string(plain[2:]) {
+			t.Fatalf("消息体与原始数据不一致")
+		}
+	})
+}
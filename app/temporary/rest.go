@@ -1,6 +1,8 @@
 package temporary
 
 import (
+	"errors"
+	"fmt"
 	"io"
 	"log/slog"
 	"net"
@@ -8,9 +10,14 @@ import (
 	"time"
 
 	"github.com/gorilla/websocket"
+	"github.com/vela-ssoc/ssoc-broker/library/handshake"
+	"github.com/vela-ssoc/ssoc-broker/library/inetx"
 	"github.com/xgfone/ship/v5"
 )
 
+// maxIdent 加密后的认证信息大小上限。
+const maxIdent = 100 * 1024
+
 type httpREST struct {
 	upgrade *websocket.Upgrader
 	log     *slog.Logger
@@ -46,18 +53,28 @@ func REST(handler Handler, valid ship.Validator, log *slog.Logger) *httpREST {
 //	429 http.StatusTooManyRequests : 重复登录
 func (rest *httpREST) Endpoint(c *ship.Context) error {
 	// 解密参数
+	addr := c.Request().RemoteAddr
+	auth := c.GetReqHeader(ship.HeaderAuthorization)
+	if size := len(auth); size > maxIdent {
+		err := handshake.Reject(handshake.ReasonOversize, fmt.Errorf("认证信息长度 %d 超过上限 %d", size, maxIdent))
+		handshake.Record("temporary", addr, err)
+		rest.log.Warn("minion 认证信息过长", slog.Any("error", err), slog.String("remote_addr", addr))
+		return c.NoContent(http.StatusRequestEntityTooLarge)
+	}
 	var ident Ident
-	if err := ident.decrypt(c.GetReqHeader(ship.HeaderAuthorization)); err != nil {
+	if err := ident.decrypt(auth); err != nil {
+		handshake.Record("temporary", addr, handshake.Reject(handshake.ReasonMalformed, err))
 		rest.log.Warn("minion 认证信息解密失败", slog.Any("error", err))
 		return c.NoContent(http.StatusBadRequest)
 	}
 	// 校验参数
 	if err := rest.valid.Validate(ident); err != nil {
+		handshake.Record("temporary", addr, handshake.Reject(handshake.ReasonInvalid, err))
 		rest.log.Info("minion 认证信息校验失败", slog.Any("error", err))
 		return c.NoContent(http.StatusBadRequest)
 	}
 
-	inet := ident.Inet.String()
+	inet := inetx.FromIP(ident.Inet, ident.Inet6).Primary()
 	// 认证授权
 	claim, err := rest.handler.Authorize(ident)
 	if err != nil {
@@ -96,6 +113,18 @@ func (rest *httpREST) Endpoint(c *ship.Context) error {
 	for {
 		rec, ex := conn.receive(timeout)
 		if ex != nil {
+			// 超过大小上限的消息无法继续读取，格式错误的消息丢弃即可。
+			if errors.Is(ex, websocket.ErrReadLimit) {
+				handshake.Record("temporary", addr, handshake.Reject(handshake.ReasonOversize, ex))
+				rest.log.Warn("minion 消息超过大小上限，断开连接", slog.String("inet", inet), slog.Int64("minion_id", id))
+				break
+			}
+			var re *handshake.RejectError
+			if errors.As(ex, &re) {
+				handshake.Record("temporary", addr, ex)
+				rest.log.Warn("minion 消息格式错误", slog.Any("error", ex), slog.String("inet", inet), slog.Int64("minion_id", id))
+				continue
+			}
 			if rest.closedErr(ex) {
 				rest.log.Warn("minion 断开连接", slog.Any("error", ex), slog.String("inet", inet), slog.Int64("minion_id", id))
 				break
@@ -110,6 +139,7 @@ func (rest *httpREST) Endpoint(c *ship.Context) error {
 }
 
 func (rest *httpREST) newConn(ws *websocket.Conn, ident Ident, claim Claim) *Conn {
+	ws.SetReadLimit(maxMessage)
 	return &Conn{conn: ws, ident: ident, claim: claim, validator: rest.valid}
 }
 
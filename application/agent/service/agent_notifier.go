@@ -1,35 +1,269 @@
 package service
 
 import (
+	"context"
 	"log/slog"
+	"sync"
+	"time"
 
 	"github.com/vela-ssoc/ssoc-broker/channel/agtrpc"
+	"github.com/vela-ssoc/ssoc-broker/channel/serverd"
+	"github.com/vela-ssoc/ssoc-broker/library/metrics"
+	"github.com/vela-ssoc/ssoc-common-mb/dal/model"
 	"github.com/vela-ssoc/ssoc-common-mb/dal/query"
+	"github.com/vela-ssoc/ssoc-common-mb/integration/cmdb"
 	"github.com/vela-ssoc/ssoc-common/gopool"
 	"github.com/vela-ssoc/ssoc-common/linkhub"
 )
 
-func NewAgentNotifier(qry *query.Query, cli agtrpc.Client, pool gopool.Pool, log *slog.Logger) *AgentNotifier {
+// TaskRsyncer 将 agent 运行的配置与数据库中关联的配置对齐。
+type TaskRsyncer interface {
+	Rsync(ctx context.Context, agentID int64) error
+}
+
+// NodeEventRecorder 记录节点上下线事件并告警，与旧通道共用同一套抖动抑制逻辑。
+type NodeEventRecorder interface {
+	Online(mid int64, inet, semver string)
+	Offline(mid int64, inet, semver string, du time.Duration)
+}
+
+// NewAgentNotifier workers 为 pool 的容量，同时执行的任务达到该值后新提交的任务进入有界队列排队，
+// 队列容量为 workers 的 16 倍。
+func NewAgentNotifier(qry *query.Query, cli agtrpc.Client, rsync TaskRsyncer, events NodeEventRecorder, cmdbc cmdb.Client, pool gopool.Pool, workers int, log *slog.Logger) *AgentNotifier {
+	if workers <= 0 {
+		workers = 1
+	}
+
 	return &AgentNotifier{
-		qry:  qry,
-		cli:  cli,
-		log:  log,
-		pool: pool,
+		qry:     qry,
+		cli:     cli,
+		rsync:   rsync,
+		events:  events,
+		cmdbc:   cmdbc,
+		log:     log,
+		pool:    pool,
+		slots:   make(chan struct{}, workers),
+		backlog: make(chan func(), workers*16),
+		pending: make(map[int64]string, 64),
+		dropped: metrics.PoolDropped.With("notifier"),
 	}
 }
 
+// AgentNotifier agent 上下线通知。
+//
+// 上线时推送 startup、同步配置，新节点拉取 cmdb 信息，这些任务在协程池中执行，协程池满时进入有界队列排队，
+// 队列也满时才丢弃并计数，不会阻塞 agent 上线；丢弃的 startup 推送在 Run 的下一次检查时重试。
+// 上下线事件单独排队，按照发生顺序逐个处理，从不丢弃。
 type AgentNotifier struct {
-	qry  *query.Query
-	cli  agtrpc.Client
-	pool gopool.Pool
-	log  *slog.Logger
+	qry     *query.Query
+	cli     agtrpc.Client
+	rsync   TaskRsyncer
+	events  NodeEventRecorder
+	cmdbc   cmdb.Client
+	pool    gopool.Pool
+	slots   chan struct{}
+	backlog chan func() // 协程池满时排队的任务
+	dropped *metrics.Counter
+	log     *slog.Logger
+
+	mutex   sync.Mutex
+	pending map[int64]string // 被丢弃、等待重试 startup 推送的节点：agent ID -> inet
+	lane    []func()         // 等待处理的上下线事件
+	laning  bool             // 是否有协程正在处理上下线事件
+}
+
+// Run 每隔 interval 重试一次被丢弃的 startup 推送，并唤醒排队的任务，直到 ctx 结束。
+func (an *AgentNotifier) Run(ctx context.Context, interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+
+		an.mutex.Lock()
+		pending := an.pending
+		an.pending = make(map[int64]string, 64)
+		an.mutex.Unlock()
+
+		for id, inet := range pending {
+			an.startup(id, inet)
+		}
+		if len(an.backlog) != 0 {
+			select {
+			case an.slots <- struct{}{}:
+				an.pool.Go(func() { an.work(nil) })
+			default:
+			}
+		}
+	}
 }
 
 func (an *AgentNotifier) AgentConnected(peer linkhub.Peer) {
-	// TODO 每次上线要同步配置脚本
-	// 记录上线事件（告警）
+	info := peer.Info()
+	agt, _ := serverd.AgentOf(peer)
+	attrs := []any{slog.Int64("agent_id", info.ID), slog.String("inet", info.Inet)}
+	an.log.Info("agent 上线", attrs...)
+
+	an.startup(info.ID, info.Inet)
+	if agt.Created {
+		an.submit("cmdb", attrs, func() {
+			ctx, cancel := context.WithTimeout(context.Background(), time.Minute)
+			defer cancel()
+
+			if err := an.cmdbc.FetchAndSave(ctx, info.ID, info.Inet); err != nil {
+				an.log.Info("拉取 cmdb 信息错误", append(attrs, slog.Any("error", err))...)
+			}
+		})
+	}
+	an.record(func() {
+		an.events.Online(info.ID, info.Inet, agt.Semver)
+	})
+}
+
+func (an *AgentNotifier) AgentDisconnected(peer linkhub.Peer) {
+	info := peer.Info()
+	agt, _ := serverd.AgentOf(peer)
+	attrs := []any{slog.Int64("agent_id", info.ID), slog.String("inet", info.Inet)}
+	an.log.Warn("agent 下线", attrs...)
+
+	var du time.Duration
+	if !agt.ConnectedAt.IsZero() {
+		du = time.Since(agt.ConnectedAt)
+	}
+	an.mutex.Lock()
+	delete(an.pending, info.ID)
+	an.mutex.Unlock()
+	an.record(func() {
+		an.events.Offline(info.ID, info.Inet, agt.Semver, du)
+	})
+}
+
+// startup 提交 startup 推送与配置同步任务，任务被丢弃时记下节点，等待 Run 重试。
+func (an *AgentNotifier) startup(agentID int64, inet string) {
+	attrs := []any{slog.Int64("agent_id", agentID), slog.String("inet", inet)}
+	submitted := an.submit("startup", attrs, func() {
+		ctx, cancel := context.WithTimeout(context.Background(), time.Minute)
+		defer cancel()
+
+		if err := an.pushStartup(ctx, agentID); err != nil {
+			an.log.Warn("推送 startup 配置出错", append(attrs, slog.Any("error", err))...)
+		}
+		if err := an.rsync.Rsync(ctx, agentID); err != nil {
+			an.log.Warn("同步配置出错", append(attrs, slog.Any("error", err))...)
+		}
+	})
+	if !submitted {
+		an.mutex.Lock()
+		an.pending[agentID] = inet
+		an.mutex.Unlock()
+	}
+}
+
+// submit 非阻塞地提交任务：优先交给协程池，协程池已满时进入队列排队，队列也满时丢弃任务并计数。
+func (an *AgentNotifier) submit(task string, attrs []any, fn func()) bool {
+	select {
+	case an.slots <- struct{}{}:
+		an.pool.Go(func() { an.work(fn) })
+		return true
+	default:
+	}
+	select {
+	case an.backlog <- fn:
+		return true
+	default:
+		an.dropped.Inc()
+		an.log.Warn("通知队列已满，丢弃任务", append(attrs, slog.String("task", task))...)
+		return false
+	}
+}
+
+// work 执行任务，完成后继续处理排队的任务，直到队列为空再归还协程池的名额。
+func (an *AgentNotifier) work(fn func()) {
+	defer func() { <-an.slots }()
+	for {
+		if fn != nil {
+			fn()
+		}
+		select {
+		case fn = <-an.backlog:
+		default:
+			return
+		}
+	}
 }
 
-func (an *AgentNotifier) AgentDisconnected(agentID int64) {
-	// 记录下线事件（告警）
+// record 上下线事件进入单独的队列，由一个协程按照发生顺序处理，从不丢弃。
+func (an *AgentNotifier) record(fn func()) {
+	an.mutex.Lock()
+	an.lane = append(an.lane, fn)
+	if an.laning {
+		an.mutex.Unlock()
+		return
+	}
+	an.laning = true
+	an.mutex.Unlock()
+
+	go func() {
+		for {
+			an.mutex.Lock()
+			if len(an.lane) == 0 {
+				an.laning = false
+				an.mutex.Unlock()
+				return
+			}
+			fn := an.lane[0]
+			an.lane[0] = nil
+			an.lane = an.lane[1:]
+			an.mutex.Unlock()
+
+			fn()
+		}
+	}()
+}
+
+// pushStartup 推送 startup 配置，节点没有单独配置时使用全局默认配置。
+func (an *AgentNotifier) pushStartup(ctx context.Context, agentID int64) error {
+	startup, err := an.getStartup(ctx, agentID)
+	if err != nil {
+		return err
+	}
+	err = an.cli.Operator(agentID).Startup(ctx, startup)
+
+	tbl := an.qry.Startup
+	failed, reason := tbl.Failed.Value(false), tbl.Reason.Value("")
+	if err != nil {
+		failed, reason = tbl.Failed.Value(true), tbl.Reason.Value(err.Error())
+	}
+	_, _ = tbl.WithContext(ctx).Where(tbl.ID.Eq(agentID)).UpdateSimple(failed, reason)
+
+	return err
+}
+
+func (an *AgentNotifier) getStartup(ctx context.Context, agentID int64) (*model.StartupFallback, error) {
+	{
+		tbl := an.qry.Startup
+		dat, err := tbl.WithContext(ctx).Where(tbl.ID.Eq(agentID)).First()
+		if err == nil && dat.Logger != nil {
+			ret := &model.StartupFallback{
+				ID:        agentID,
+				Logger:    *dat.Logger,
+				CreatedAt: dat.CreatedAt,
+				UpdatedAt: dat.UpdatedAt,
+			}
+			return ret, nil
+		}
+	}
+
+	tbl := an.qry.StartupFallback
+	ret, err := tbl.WithContext(ctx).Order(tbl.ID.Desc()).First()
+	if err != nil {
+		return nil, err
+	}
+	ret.ID = agentID
+
+	return ret, nil
 }
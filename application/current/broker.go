@@ -57,6 +57,19 @@ func (brok *Broker) ResetAgents(parent context.Context) error {
 	return err
 }
 
+// Alternates 查询除当前 broker 以外在线的 broker，用于通知 agent 迁移。
+func (brok *Broker) Alternates(ctx context.Context) ([]*model.Broker, error) {
+	this, err := brok.Load(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	tbl := brok.qry.Broker
+	dao := tbl.WithContext(ctx)
+
+	return dao.Where(tbl.ID.Neq(this.ID), tbl.Status.Is(true)).Find()
+}
+
 func (brok *Broker) Certificates(ctx context.Context) ([]*tls.Certificate, error) {
 	this, err := brok.Load(ctx)
 	if err != nil {
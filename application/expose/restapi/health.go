@@ -0,0 +1,43 @@
+package restapi
+
+import (
+	"github.com/vela-ssoc/ssoc-broker/library/health"
+	"github.com/xgfone/ship/v5"
+)
+
+// NewHealth 存活与就绪检查接口，供 systemd watchdog、k8s 探针和负载均衡使用。
+func NewHealth(probe *health.Probe) *Health {
+	return &Health{probe: probe}
+}
+
+type Health struct {
+	probe *health.Probe
+}
+
+func (h *Health) BindRoute(rgb *ship.RouteGroupBuilder) error {
+	rgb.Route("/healthz").GET(h.healthz)
+	rgb.Route("/readyz").GET(h.readyz)
+	rgb.Route("/local/healthz").GET(h.localHealthz)
+	rgb.Route("/local/readyz").GET(h.localReadyz)
+	return nil
+}
+
+func (h *Health) healthz(c *ship.Context) error {
+	h.probe.Healthz(c.ResponseWriter(), c.Request())
+	return nil
+}
+
+func (h *Health) readyz(c *ship.Context) error {
+	h.probe.Readyz(c.ResponseWriter(), c.Request())
+	return nil
+}
+
+func (h *Health) localHealthz(c *ship.Context) error {
+	h.probe.LocalHealthz(c.ResponseWriter(), c.Request())
+	return nil
+}
+
+func (h *Health) localReadyz(c *ship.Context) error {
+	h.probe.LocalReadyz(c.ResponseWriter(), c.Request())
+	return nil
+}
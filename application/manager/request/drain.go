@@ -0,0 +1,16 @@
+package request
+
+import (
+	"time"
+
+	"github.com/vela-ssoc/ssoc-broker/library/drain"
+)
+
+type Drain struct {
+	Wave     int            `json:"wave"     query:"wave"     validate:"gte=0,lte=10000"` // 每批次释放的 agent 个数
+	Interval drain.Duration `json:"interval" query:"interval" validate:"gte=0"`           // 每批次之间的间隔，如 "2s"
+}
+
+func (d Drain) Option() drain.Option {
+	return drain.Option{Wave: d.Wave, Interval: time.Duration(d.Interval)}
+}
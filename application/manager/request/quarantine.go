@@ -0,0 +1,6 @@
+package request
+
+type QuarantineRelease struct {
+	ID     int64 `json:"id,string" validate:"required"` // agent ID
+	Rebind bool  `json:"rebind"`                        // 是否将隔离请求中的公钥重新绑定到该 agent
+}
@@ -0,0 +1,36 @@
+package restapi
+
+import (
+	"net/http"
+
+	"github.com/vela-ssoc/ssoc-broker/app/route"
+	"github.com/vela-ssoc/ssoc-broker/application/manager/service"
+	"github.com/vela-ssoc/ssoc-broker/channel/clientd"
+	"github.com/xgfone/ship/v5"
+)
+
+func NewBootConfig(svc *service.BootConfig) *BootConfig {
+	return &BootConfig{svc: svc}
+}
+
+type BootConfig struct {
+	svc *service.BootConfig
+}
+
+func (bc *BootConfig) BindRoute(rgb *ship.RouteGroupBuilder) error {
+	rgb.Route("/broker/boot-config/reload").Data(route.Lifecycle("热加载启动配置")).POST(bc.reload)
+	return nil
+}
+
+func (bc *BootConfig) reload(c *ship.Context) error {
+	req := new(clientd.BootConfig)
+	if err := c.Bind(req); err != nil {
+		return err
+	}
+	ctx := c.Request().Context()
+	if err := bc.svc.Reload(ctx, *req); err != nil {
+		return err
+	}
+
+	return c.NoContent(http.StatusNoContent)
+}
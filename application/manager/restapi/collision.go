@@ -0,0 +1,45 @@
+package restapi
+
+import (
+	"net/http"
+
+	"github.com/vela-ssoc/ssoc-broker/app/route"
+	"github.com/vela-ssoc/ssoc-broker/application/manager/request"
+	"github.com/vela-ssoc/ssoc-broker/application/manager/service"
+	"github.com/vela-ssoc/ssoc-broker/library/collision"
+	"github.com/xgfone/ship/v5"
+)
+
+func NewCollision(svc *service.Collision) *Collision {
+	return &Collision{svc: svc}
+}
+
+type Collision struct {
+	svc *service.Collision
+}
+
+func (cl *Collision) BindRoute(rgb *ship.RouteGroupBuilder) error {
+	rgb.Route("/broker/collisions").Data(route.Diag("机器码冲突列表")).GET(cl.list)
+	rgb.Route("/broker/collision/resolve").Data(route.Control("处理机器码冲突")).POST(cl.resolve)
+	return nil
+}
+
+func (cl *Collision) list(c *ship.Context) error {
+	ctx := c.Request().Context()
+	ret, err := cl.svc.List(ctx)
+	if err != nil {
+		return err
+	}
+
+	return c.JSON(http.StatusOK, ret)
+}
+
+func (cl *Collision) resolve(c *ship.Context) error {
+	req := new(request.CollisionResolve)
+	if err := c.Bind(req); err != nil {
+		return err
+	}
+	ctx := c.Request().Context()
+
+	return cl.svc.Resolve(ctx, req.MachineID, collision.Resolution(req.Resolution))
+}
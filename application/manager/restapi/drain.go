@@ -0,0 +1,44 @@
+package restapi
+
+import (
+	"net/http"
+
+	"github.com/vela-ssoc/ssoc-broker/app/route"
+	"github.com/vela-ssoc/ssoc-broker/application/manager/request"
+	"github.com/vela-ssoc/ssoc-broker/application/manager/service"
+	"github.com/xgfone/ship/v5"
+)
+
+func NewDrain(svc *service.Drain) *Drain {
+	return &Drain{svc: svc}
+}
+
+type Drain struct {
+	svc *service.Drain
+}
+
+func (d *Drain) BindRoute(rgb *ship.RouteGroupBuilder) error {
+	rgb.Route("/broker/drain").Data(route.Lifecycle("broker 排空节点")).POST(d.drain)
+	rgb.Route("/broker/undrain").Data(route.Lifecycle("broker 退出排空")).POST(d.undrain)
+	return nil
+}
+
+func (d *Drain) drain(c *ship.Context) error {
+	req := new(request.Drain)
+	if err := c.Bind(req); err != nil {
+		return err
+	}
+	if err := d.svc.Start(req.Option()); err != nil {
+		return err
+	}
+
+	return c.NoContent(http.StatusAccepted)
+}
+
+func (d *Drain) undrain(c *ship.Context) error {
+	if err := d.svc.Undrain(); err != nil {
+		return err
+	}
+
+	return c.NoContent(http.StatusOK)
+}
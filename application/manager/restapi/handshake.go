@@ -0,0 +1,28 @@
+package restapi
+
+import (
+	"net/http"
+
+	"github.com/vela-ssoc/ssoc-broker/app/route"
+	"github.com/vela-ssoc/ssoc-broker/library/handshake"
+	"github.com/xgfone/ship/v5"
+)
+
+func NewHandshake(tracker handshake.Tracker) *Handshake {
+	return &Handshake{tracker: tracker}
+}
+
+// Handshake 查询 agent 握手报文被拒绝的记录（按来源 IP 统计）。
+type Handshake struct {
+	tracker handshake.Tracker
+}
+
+func (h *Handshake) BindRoute(rgb *ship.RouteGroupBuilder) error {
+	rgb.Route("/broker/handshake/rejects").Data(route.Diag("握手报文拒绝记录")).GET(h.rejects)
+	return nil
+}
+
+func (h *Handshake) rejects(c *ship.Context) error {
+	ret := h.tracker.Rejections()
+	return c.JSON(http.StatusOK, ret)
+}
@@ -0,0 +1,26 @@
+package restapi
+
+import (
+	"github.com/vela-ssoc/ssoc-broker/app/route"
+	"github.com/vela-ssoc/ssoc-broker/library/metrics"
+	"github.com/xgfone/ship/v5"
+)
+
+func NewMetrics(reg *metrics.Registry) *Metrics {
+	return &Metrics{reg: reg}
+}
+
+// Metrics 经中心端通道代理拉取 broker 指标（Prometheus 文本格式）。
+type Metrics struct {
+	reg *metrics.Registry
+}
+
+func (m *Metrics) BindRoute(rgb *ship.RouteGroupBuilder) error {
+	rgb.Route("/broker/metrics").Data(route.Diag("metrics")).GET(m.metrics)
+	return nil
+}
+
+func (m *Metrics) metrics(c *ship.Context) error {
+	m.reg.ServeHTTP(c.Response(), c.Request())
+	return nil
+}
@@ -3,6 +3,7 @@ package restapi
 import (
 	"net/http"
 
+	"github.com/vela-ssoc/ssoc-broker/app/route"
 	"github.com/xgfone/ship/v5"
 )
 
@@ -13,7 +14,7 @@ func NewPing() *Ping {
 type Ping struct{}
 
 func (p Ping) BindRoute(rgb *ship.RouteGroupBuilder) error {
-	rgb.Route("/ping").GET(p.pong)
+	rgb.Route("/ping").Data(route.Diag("ping")).GET(p.pong)
 	return nil
 }
 
@@ -0,0 +1,44 @@
+package restapi
+
+import (
+	"net/http"
+
+	"github.com/vela-ssoc/ssoc-broker/app/route"
+	"github.com/vela-ssoc/ssoc-broker/application/manager/request"
+	"github.com/vela-ssoc/ssoc-broker/application/manager/service"
+	"github.com/xgfone/ship/v5"
+)
+
+func NewQuarantine(svc *service.Quarantine) *Quarantine {
+	return &Quarantine{svc: svc}
+}
+
+type Quarantine struct {
+	svc *service.Quarantine
+}
+
+func (q *Quarantine) BindRoute(rgb *ship.RouteGroupBuilder) error {
+	rgb.Route("/broker/quarantines").Data(route.Diag("节点隔离列表")).GET(q.list)
+	rgb.Route("/broker/quarantine/release").Data(route.Control("解除节点隔离")).POST(q.release)
+	return nil
+}
+
+func (q *Quarantine) list(c *ship.Context) error {
+	ctx := c.Request().Context()
+	ret, err := q.svc.List(ctx)
+	if err != nil {
+		return err
+	}
+
+	return c.JSON(http.StatusOK, ret)
+}
+
+func (q *Quarantine) release(c *ship.Context) error {
+	req := new(request.QuarantineRelease)
+	if err := c.Bind(req); err != nil {
+		return err
+	}
+	ctx := c.Request().Context()
+
+	return q.svc.Release(ctx, req.ID, req.Rebind)
+}
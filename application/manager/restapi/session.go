@@ -0,0 +1,42 @@
+package restapi
+
+import (
+	"net/http"
+
+	"github.com/vela-ssoc/ssoc-broker/app/route"
+	"github.com/vela-ssoc/ssoc-broker/application/manager/request"
+	"github.com/vela-ssoc/ssoc-broker/application/manager/service"
+	"github.com/xgfone/ship/v5"
+)
+
+func NewSession(svc *service.Session) *Session {
+	return &Session{svc: svc}
+}
+
+type Session struct {
+	svc *service.Session
+}
+
+func (ss *Session) BindRoute(rgb *ship.RouteGroupBuilder) error {
+	rgb.Route("/broker/sessions").Data(route.Diag("agent 会话列表")).GET(ss.list)
+	rgb.Route("/broker/session/features").Data(route.Diag("agent 协商的特性")).GET(ss.features)
+	return nil
+}
+
+func (ss *Session) list(c *ship.Context) error {
+	ret := ss.svc.List()
+	return c.JSON(http.StatusOK, ret)
+}
+
+func (ss *Session) features(c *ship.Context) error {
+	req := new(request.Int64ID)
+	if err := c.BindQuery(req); err != nil {
+		return err
+	}
+	features, ok := ss.svc.Features(req.ID)
+	if !ok {
+		return ship.ErrNotFound
+	}
+
+	return c.JSON(http.StatusOK, features)
+}
@@ -0,0 +1,118 @@
+package service
+
+import (
+	"context"
+	"log/slog"
+	"sync"
+	"time"
+
+	"github.com/vela-ssoc/ssoc-broker/channel/clientd"
+	"github.com/vela-ssoc/ssoc-broker/library/hotdb"
+)
+
+func NewBootConfig(mux clientd.Muxer, db hotdb.DB, log *slog.Logger) *BootConfig {
+	return &BootConfig{
+		mux: mux,
+		db:  db,
+		log: log,
+	}
+}
+
+// BootConfig 中心端下发的启动配置热加载。
+type BootConfig struct {
+	mux     clientd.Muxer
+	db      hotdb.DB
+	log     *slog.Logger
+	mutex   sync.Mutex
+	latest  *clientd.BootConfig // 等待后台应用的最新配置
+	running bool                // 后台应用协程是否在运行
+}
+
+// Reload 中心端推送了新的启动配置，数据库切换失败时返回错误且不更新配置。
+func (bc *BootConfig) Reload(ctx context.Context, cfg clientd.BootConfig) error {
+	if err := bc.apply(ctx, cfg); err != nil {
+		return err
+	}
+	// 通知订阅者，Watch 发现数据库配置已经生效不会重复应用。
+	bc.mux.Reload(cfg)
+
+	return nil
+}
+
+// Watch 订阅通道上的启动配置变更（如：断线重连后中心端下发了不同的配置）。
+//
+// 回调在通道重连流程中同步执行，切换数据库可能耗时较长，所以放到后台执行，
+// 连续多次变更只应用最新的一份。
+func (bc *BootConfig) Watch(old, cur clientd.BootConfig) {
+	bc.log.Info("中心端下发的启动配置发生变化",
+		"dsn_changed", old.DSN != cur.DSN, "old", DatabaseConfig(old), "new", DatabaseConfig(cur))
+
+	bc.mutex.Lock()
+	bc.latest = &cur
+	running := bc.running
+	bc.running = true
+	bc.mutex.Unlock()
+
+	if !running {
+		go bc.background()
+	}
+}
+
+func (bc *BootConfig) background() {
+	for {
+		bc.mutex.Lock()
+		cfg := bc.latest
+		bc.latest = nil
+		if cfg == nil {
+			bc.running = false
+			bc.mutex.Unlock()
+			return
+		}
+		bc.mutex.Unlock()
+
+		ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+		if err := bc.apply(ctx, *cfg); err != nil {
+			bc.log.Error("启动配置热加载失败", "error", err)
+		}
+		cancel()
+	}
+}
+
+// apply 应用数据库配置：未下发 DSN 时不做处理，下发的连接池参数为零时沿用当前值，
+// 与当前生效的配置一致时不做处理。
+func (bc *BootConfig) apply(ctx context.Context, cfg clientd.BootConfig) error {
+	if cfg.DSN == "" {
+		return nil
+	}
+
+	cur := bc.db.Config()
+	dbc := DatabaseConfig(cfg)
+	if dbc.MaxOpenConn == 0 {
+		dbc.MaxOpenConn = cur.MaxOpenConn
+	}
+	if dbc.MaxIdleConn == 0 {
+		dbc.MaxIdleConn = cur.MaxIdleConn
+	}
+	if dbc.MaxLifeTime == 0 {
+		dbc.MaxLifeTime = cur.MaxLifeTime
+	}
+	if dbc.MaxIdleTime == 0 {
+		dbc.MaxIdleTime = cur.MaxIdleTime
+	}
+	if dbc == cur {
+		return nil
+	}
+
+	return bc.db.Apply(ctx, dbc)
+}
+
+// DatabaseConfig 从启动配置中提取数据库连接配置。
+func DatabaseConfig(cfg clientd.BootConfig) hotdb.Config {
+	return hotdb.Config{
+		DSN:         cfg.DSN,
+		MaxOpenConn: cfg.MaxOpenConn,
+		MaxIdleConn: cfg.MaxIdleConn,
+		MaxLifeTime: cfg.MaxLifeTime,
+		MaxIdleTime: cfg.MaxIdleTime,
+	}
+}
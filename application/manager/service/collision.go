@@ -0,0 +1,33 @@
+package service
+
+import (
+	"context"
+	"log/slog"
+
+	"github.com/vela-ssoc/ssoc-broker/library/collision"
+)
+
+func NewCollision(detect collision.Detector, log *slog.Logger) *Collision {
+	return &Collision{
+		detect: detect,
+		log:    log,
+	}
+}
+
+type Collision struct {
+	detect collision.Detector
+	log    *slog.Logger
+}
+
+func (cl *Collision) List(ctx context.Context) ([]*collision.Collision, error) {
+	return cl.detect.Collisions(ctx)
+}
+
+func (cl *Collision) Resolve(ctx context.Context, machineID string, res collision.Resolution) error {
+	if err := cl.detect.Resolve(ctx, machineID, res); err != nil {
+		return err
+	}
+	cl.log.Warn("处理 agent 机器码冲突", "machine_id", machineID, "resolution", res)
+
+	return nil
+}
@@ -0,0 +1,87 @@
+package service
+
+import (
+	"context"
+	"log/slog"
+	"time"
+
+	"github.com/vela-ssoc/ssoc-broker/application/current"
+	"github.com/vela-ssoc/ssoc-broker/channel/agtrpc"
+	"github.com/vela-ssoc/ssoc-broker/channel/agtrpc/arequest"
+	"github.com/vela-ssoc/ssoc-broker/channel/serverd"
+	"github.com/vela-ssoc/ssoc-broker/library/drain"
+	"github.com/vela-ssoc/ssoc-common/linkhub"
+)
+
+func NewDrain(srv serverd.Server, brok *current.Broker, cli agtrpc.Client, log *slog.Logger) *Drain {
+	return &Drain{
+		srv:  srv,
+		brok: brok,
+		cli:  cli,
+		log:  log,
+	}
+}
+
+type Drain struct {
+	srv  serverd.Server
+	brok *current.Broker
+	cli  agtrpc.Client
+	log  *slog.Logger
+}
+
+// Start 异步排空，供 manager 调用。
+//
+// 是否已经处于排空模式在调用时同步判断，并发的请求只有一个会成功。
+func (dr *Drain) Start(opt drain.Option) error {
+	ctx, cancel := context.WithTimeout(context.Background(), time.Hour)
+	done, err := dr.srv.DrainAsync(ctx, dr.option(ctx, opt))
+	if err != nil {
+		cancel()
+		return err
+	}
+
+	go func() {
+		defer cancel()
+		if exx := <-done; exx != nil {
+			dr.log.Warn("排空 agent 出错", "error", exx)
+		}
+	}()
+
+	return nil
+}
+
+// Drain 通知 agent 迁移到其它在线的 broker 并分批断开连接，阻塞直至全部释放。
+func (dr *Drain) Drain(ctx context.Context, opt drain.Option) error {
+	return dr.srv.Drain(ctx, dr.option(ctx, opt))
+}
+
+// option 查询可迁移的 broker，生成通知 agent 迁移的排空参数。
+func (dr *Drain) option(ctx context.Context, opt drain.Option) serverd.DrainOption {
+	req := &arequest.Migrate{}
+	if brks, err := dr.brok.Alternates(ctx); err != nil {
+		dr.log.Warn("查询可迁移的 broker 出错", "error", err)
+	} else {
+		for _, brk := range brks {
+			req.Brokers = append(req.Brokers, &arequest.MigrateBroker{
+				ID:         brk.ID,
+				Name:       brk.Name,
+				Servername: brk.Servername,
+				LAN:        brk.LAN,
+				VIP:        brk.VIP,
+			})
+		}
+	}
+
+	return serverd.DrainOption{
+		Option: opt,
+		Notify: func(ctx context.Context, peer linkhub.Peer) error {
+			agentID := peer.Info().ID
+			return dr.cli.Operator(agentID).Migrate(ctx, req)
+		},
+	}
+}
+
+// Undrain 退出排空模式，重新接入 agent。
+func (dr *Drain) Undrain() error {
+	return dr.srv.Undrain()
+}
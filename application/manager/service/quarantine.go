@@ -0,0 +1,33 @@
+package service
+
+import (
+	"context"
+	"log/slog"
+
+	"github.com/vela-ssoc/ssoc-broker/library/identity"
+)
+
+func NewQuarantine(verify identity.Verifier, log *slog.Logger) *Quarantine {
+	return &Quarantine{
+		verify: verify,
+		log:    log,
+	}
+}
+
+type Quarantine struct {
+	verify identity.Verifier
+	log    *slog.Logger
+}
+
+func (qt *Quarantine) List(ctx context.Context) ([]*identity.Quarantine, error) {
+	return qt.verify.Quarantines(ctx)
+}
+
+func (qt *Quarantine) Release(ctx context.Context, id int64, rebind bool) error {
+	if err := qt.verify.Release(ctx, id, rebind); err != nil {
+		return err
+	}
+	qt.log.Warn("解除 agent 隔离", "agent_id", id, "rebind", rebind)
+
+	return nil
+}
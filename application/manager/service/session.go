@@ -0,0 +1,38 @@
+package service
+
+import (
+	"cmp"
+	"slices"
+
+	"github.com/vela-ssoc/ssoc-broker/channel/serverd"
+	"github.com/vela-ssoc/ssoc-broker/library/capability"
+	"github.com/vela-ssoc/ssoc-common/linkhub"
+)
+
+func NewSession(huber linkhub.Huber) *Session {
+	return &Session{huber: huber}
+}
+
+// Session 当前 broker 上 agent 的会话。
+type Session struct {
+	huber linkhub.Huber
+}
+
+// List 查询所有在线 agent 上线时上报的信息，按照 ID 排序。
+func (ss *Session) List() []serverd.Agent {
+	peers := ss.huber.All()
+	ret := make([]serverd.Agent, 0, len(peers))
+	for _, peer := range peers {
+		if agt, ok := serverd.AgentOf(peer); ok {
+			ret = append(ret, agt)
+		}
+	}
+	slices.SortFunc(ret, func(a, b serverd.Agent) int { return cmp.Compare(a.ID, b.ID) })
+
+	return ret
+}
+
+// Features 查询在线 agent 协商的特性，agent 不在线返回 false。
+func (ss *Session) Features(id int64) (capability.Set, bool) {
+	return serverd.FeaturesOf(ss.huber, id)
+}
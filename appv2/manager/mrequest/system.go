@@ -1,7 +1,21 @@
 package mrequest
 
-import "github.com/vela-ssoc/ssoc-common-mb/dal/model"
+import (
+	"time"
+
+	"github.com/vela-ssoc/ssoc-broker/library/drain"
+	"github.com/vela-ssoc/ssoc-common-mb/dal/model"
+)
 
 type SystemUpdate struct {
 	Semver model.Semver `json:"semver" query:"semver"`
 }
+
+type SystemDrain struct {
+	Wave     int            `json:"wave"     query:"wave"     validate:"gte=0,lte=10000"` // 每批次释放的节点个数
+	Interval drain.Duration `json:"interval" query:"interval" validate:"gte=0"`           // 每批次之间的间隔，如 "2s"
+}
+
+func (d SystemDrain) Option() drain.Option {
+	return drain.Option{Wave: d.Wave, Interval: time.Duration(d.Interval)}
+}
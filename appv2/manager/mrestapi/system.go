@@ -2,7 +2,9 @@ package mrestapi
 
 import (
 	"log/slog"
+	"net/http"
 
+	"github.com/vela-ssoc/ssoc-broker/app/route"
 	"github.com/vela-ssoc/ssoc-broker/appv2/manager/mrequest"
 	"github.com/vela-ssoc/ssoc-broker/appv2/manager/mservice"
 	"github.com/xgfone/ship/v5"
@@ -19,8 +21,10 @@ type System struct {
 }
 
 func (sys *System) BindRoute(r *ship.RouteGroupBuilder) error {
-	r.Route("/system/exit").POST(sys.exit)
-	r.Route("/system/update").POST(sys.update)
+	r.Route("/system/exit").Data(route.Lifecycle("broker 退出")).POST(sys.exit)
+	r.Route("/system/update").Data(route.Lifecycle("broker 升级")).POST(sys.update)
+	r.Route("/system/drain").Data(route.Lifecycle("broker 排空节点")).POST(sys.drain)
+	r.Route("/system/undrain").Data(route.Lifecycle("broker 退出排空")).POST(sys.undrain)
 	return nil
 }
 
@@ -42,3 +46,23 @@ func (sys *System) update(c *ship.Context) error {
 
 	return nil
 }
+
+func (sys *System) drain(c *ship.Context) error {
+	req := new(mrequest.SystemDrain)
+	if err := c.Bind(req); err != nil {
+		return err
+	}
+	if err := sys.svc.Drain(req.Option()); err != nil {
+		return err
+	}
+
+	return c.NoContent(http.StatusAccepted)
+}
+
+func (sys *System) undrain(c *ship.Context) error {
+	if err := sys.svc.Undrain(); err != nil {
+		return err
+	}
+
+	return c.NoContent(http.StatusOK)
+}
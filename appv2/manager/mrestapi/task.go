@@ -1,6 +1,7 @@
 package mrestapi
 
 import (
+	"github.com/vela-ssoc/ssoc-broker/app/route"
 	"github.com/vela-ssoc/ssoc-broker/appv2/manager/mrequest"
 	"github.com/vela-ssoc/ssoc-broker/appv2/manager/mservice"
 	"github.com/xgfone/ship/v5"
@@ -15,12 +16,12 @@ type Task struct {
 }
 
 func (tsk *Task) BindRoute(r *ship.RouteGroupBuilder) error {
-	r.Route("/task/push").POST(tsk.Push)
+	r.Route("/task/push").Data(route.Control("推送任务")).POST(tsk.Push)
 	return nil
 }
 
 func (tsk *Task) Route(r *ship.RouteGroupBuilder) {
-	r.Route("/task/push").POST(tsk.Push)
+	r.Route("/task/push").Data(route.Control("推送任务")).POST(tsk.Push)
 }
 
 func (tsk *Task) Push(c *ship.Context) error {
@@ -12,6 +12,7 @@ import (
 
 	"gorm.io/gorm"
 
+	"github.com/vela-ssoc/ssoc-broker/bridge/mlink"
 	"github.com/vela-ssoc/ssoc-broker/bridge/telecom"
 	"github.com/vela-ssoc/ssoc-common-mb/dal/gridfs"
 	"github.com/vela-ssoc/ssoc-common-mb/dal/model"
@@ -20,9 +21,10 @@ import (
 	"gorm.io/gen"
 )
 
-func NewSystem(link telecom.Linker, qry *query.Query, gfs gridfs.FS, log *slog.Logger) *System {
+func NewSystem(link telecom.Linker, hub mlink.Linker, qry *query.Query, gfs gridfs.FS, log *slog.Logger) *System {
 	return &System{
 		link: link,
+		hub:  hub,
 		qry:  qry,
 		gfs:  gfs,
 		log:  log,
@@ -31,6 +33,7 @@ func NewSystem(link telecom.Linker, qry *query.Query, gfs gridfs.FS, log *slog.L
 
 type System struct {
 	link   telecom.Linker
+	hub    mlink.Linker
 	qry    *query.Query
 	gfs    gridfs.FS
 	log    *slog.Logger
@@ -51,6 +54,32 @@ func (sys *System) Exit() {
 	os.Exit(0)
 }
 
+// Drain 异步排空节点：停止接入新节点，通知已连接的节点迁移并分批释放。
+//
+// 是否已经处于排空模式在调用时同步判断，并发的请求只有一个会成功。
+func (sys *System) Drain(opt mlink.DrainOption) error {
+	ctx, cancel := context.WithTimeout(context.Background(), time.Hour)
+	done, err := sys.hub.DrainAsync(ctx, opt)
+	if err != nil {
+		cancel()
+		return err
+	}
+
+	go func() {
+		defer cancel()
+		if exx := <-done; exx != nil {
+			sys.log.Warn("排空节点出错", slog.Any("error", exx))
+		}
+	}()
+
+	return nil
+}
+
+// Undrain 退出排空模式，重新接入节点。
+func (sys *System) Undrain() error {
+	return sys.hub.Undrain()
+}
+
 func (sys *System) Update(semver model.Semver) error {
 	ident := sys.link.Ident()
 	goos, arch := ident.Goos, ident.Arch
@@ -4,13 +4,15 @@ import (
 	"net"
 	"time"
 
+	"github.com/vela-ssoc/ssoc-broker/library/capability"
 	"github.com/vela-ssoc/vela-common-mba/ciphertext"
 )
 
 // Ident minion 节点握手认证时需要携带的信息，
 type Ident struct {
 	MachineID  string        `json:"machine_id"` // 机器 ID
-	Inet       net.IP        `json:"inet"`       // 内网出口 IP
+	Inet       net.IP        `json:"inet"`       // 内网出口 IP，IPv6-only 节点为 IPv6 地址
+	Inet6      net.IP        `json:"inet6"`      // 双栈节点的 IPv6 地址
 	Goos       string        `json:"goos"`       // 操作系统
 	Arch       string        `json:"arch"`       // 操作系统架构
 	MAC        string        `json:"mac"`        // 出口 IP 所在网卡的 MAC 地址
@@ -26,6 +28,13 @@ type Ident struct {
 	Unload     bool          `json:"unload"`     // 是否开启静默模式，仅在新注册节点时有效
 	Unstable   bool          `json:"unstable"`   // 不稳定版本
 	Customized string        `json:"customized"` // 定制版本
+	PublicKey  []byte        `json:"public_key"` // 节点 ed25519 公钥，首次上线时登记
+	Nonce      string        `json:"nonce"`      // broker 下发的一次性随机数
+	Signature  []byte        `json:"signature"`  // 使用私钥对 nonce 的签名
+	Ticket     string        `json:"ticket"`     // 上次连接 broker 下发的会话恢复凭证
+
+	Protocol int                  `json:"protocol"` // 握手协议版本，老版本 agent 为 0
+	Features []capability.Feature `json:"features"` // agent 支持的特性
 }
 
 // Decrypt 认证身份信息解密
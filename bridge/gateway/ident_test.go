@@ -0,0 +1,42 @@
+package gateway
+
+import (
+	"net"
+	"testing"
+
+	"github.com/vela-ssoc/vela-common-mba/ciphertext"
+)
+
+func FuzzIdentDecrypt(f *testing.F) {
+	seed := Ident{
+		MachineID: "abc",
+		Inet:      net.ParseIP("10.0.0.1"),
+		Hostname:  "web-01",
+		Semver:    "4.0.0",
+	}
+	if enc, err := ciphertext.EncryptJSON(seed); err == nil {
+		f.Add(enc)
+	}
+	f.Add([]byte{})
+	f.Add([]byte("{}"))
+	f.Add([]byte{0xff, 0x00, 0x01})
+
+	f.Fuzz(func(t *testing.T, enc []byte) {
+		var ident Ident
+		if err := ident.Decrypt(enc); err != nil {
+			return
+		}
+		// 能够解密的报文重新加密后应能解出相同的机器码
+		again, err := ciphertext.EncryptJSON(ident)
+		if err != nil {
+			t.Fatalf("重新加密出错：%v", err)
+		}
+		var ret Ident
+		if err = ret.Decrypt(again); err != nil {
+			t.Fatalf("重新解密出错：%v", err)
+		}
+		if ret.MachineID != ident.MachineID || ret.Hostname != ident.Hostname {
+			t.Fatalf("重新解密的结果不一致：%+v，期望 %+v", ret, ident)
+		}
+	})
+}
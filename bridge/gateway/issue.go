@@ -1,11 +1,19 @@
 package gateway
 
-import "github.com/vela-ssoc/vela-common-mba/ciphertext"
+import (
+	"github.com/vela-ssoc/ssoc-broker/library/capability"
+	"github.com/vela-ssoc/vela-common-mba/ciphertext"
+)
 
 // Issue 信息
 type Issue struct {
-	ID     int64  `json:"id"`
-	Passwd []byte `json:"passwd"`
+	ID      int64  `json:"id"`
+	Passwd  []byte `json:"passwd"`
+	Ticket  string `json:"ticket"`  // 会话恢复凭证，断开后短时间内携带该凭证重连可快速恢复会话
+	Resumed bool   `json:"resumed"` // 本次连接是否为会话恢复
+
+	Protocol int            `json:"protocol,omitempty"` // 协商后的握手协议版本
+	Features capability.Set `json:"features,omitempty"` // 协商后双方都支持的特性
 }
 
 func (iss Issue) Encrypt() ([]byte, error) {
@@ -3,14 +3,27 @@ package gateway
 import (
 	"bytes"
 	"context"
+	"errors"
 	"fmt"
 	"io"
 	"net"
 	"net/http"
+	"strconv"
+	"time"
 
+	"github.com/vela-ssoc/ssoc-broker/library/admission"
+	"github.com/vela-ssoc/ssoc-broker/library/handshake"
+	"github.com/vela-ssoc/ssoc-broker/library/metrics"
 	"github.com/vela-ssoc/ssoc-common-mb/problem"
 	"github.com/vela-ssoc/ssoc-common-mb/validation"
-	"golang.org/x/time/rate"
+)
+
+const (
+	// maxIdent 加密后的认证报文大小上限。
+	maxIdent = 100 * 1024
+
+	// readTimeout 读取认证报文的超时时间。
+	readTimeout = 30 * time.Second
 )
 
 type Joiner interface {
@@ -19,15 +32,12 @@ type Joiner interface {
 	Join(context.Context, net.Conn, Ident, Issue) error
 }
 
-func New(joiner Joiner, valid *validation.Validate) http.Handler {
-	maxsize := 150
-	throughput := rate.NewLimiter(rate.Limit(maxsize), maxsize)
-
+func New(joiner Joiner, valid *validation.Validate, admit admission.Controller) http.Handler {
 	return &minionGateway{
-		name:       joiner.Name(),
-		joiner:     joiner,
-		valid:      valid,
-		throughput: throughput,
+		name:   joiner.Name(),
+		joiner: joiner,
+		valid:  valid,
+		admit:  admit,
 	}
 }
 
@@ -35,33 +45,59 @@ type minionGateway struct {
 	name   string
 	valid  *validation.Validate
 	joiner Joiner
-	// throughput 限流器，防止 broker 上下线引起的
+	// admit 准入控制，防止 broker 上下线引起的
 	// agent 节点蜂涌重连，拖慢数据库。
-	throughput *rate.Limiter
+	admit admission.Controller
 }
 
 func (gate *minionGateway) ServeHTTP(w http.ResponseWriter, r *http.Request) {
-	if !gate.throughput.Allow() {
-		gate.writeError(w, r, http.StatusTooManyRequests, "请求过多稍候再试。")
+	// 认证报文必须在限定时间内读完，超过大小上限直接拒绝，不做截断。
+	_ = http.NewResponseController(w).SetReadDeadline(time.Now().Add(readTimeout))
+	buf, err := handshake.ReadLimit(r.Body, maxIdent)
+	if err != nil {
+		code := http.StatusBadRequest
+		if handshake.Classify(err) == handshake.ReasonOversize {
+			code = http.StatusRequestEntityTooLarge
+		}
+		gate.reject(r, err)
+		gate.writeError(w, r, code, "认证信息读取错误")
 		return
 	}
-
-	buf := make([]byte, 100*1024)
-	n, _ := io.ReadFull(r.Body, buf)
 	var ident Ident
-	if err := ident.Decrypt(buf[:n]); err != nil {
+	if err = ident.Decrypt(buf); err != nil {
+		gate.reject(r, handshake.Reject(handshake.ReasonMalformed, err))
 		gate.writeError(w, r, http.StatusBadRequest, "认证信息错误")
 		return
 	}
-	if err := gate.valid.Validate(ident); err != nil {
+	if err = gate.valid.Validate(ident); err != nil {
+		gate.reject(r, handshake.Reject(handshake.ReasonInvalid, err))
 		gate.writeError(w, r, http.StatusBadRequest, err.Error())
 		return
 	}
+	_ = http.NewResponseController(w).SetReadDeadline(time.Time{})
+
+	// 准入控制放在读取报文之后，让反馈的耗时只包含认证（主要是数据库操作），不受客户端网速影响。
+	tkt, err := gate.admit.Admit()
+	if err != nil {
+		var re *admission.RejectError
+		if errors.As(err, &re) {
+			w.Header().Set("Retry-After", strconv.Itoa(re.Seconds()))
+		}
+		gate.authResult(http.StatusTooManyRequests)
+		gate.writeError(w, r, http.StatusTooManyRequests, "请求过多稍候再试："+err.Error())
+		return
+	}
+	defer tkt.Done()
 
 	// 鉴权
 	ctx := r.Context()
 	issue, header, code, exx := gate.joiner.Auth(ctx, ident)
+	tkt.Done() // 认证结束即释放准入名额，不要等到连接断开
+	gate.authResult(code)
 	if exx != nil {
+		for k, vs := range header { // 例如：身份校验时下发的 nonce
+			w.Header()[k] = vs
+		}
 		//code := http.StatusBadRequest
 		//if forbid {
 		//	code = http.StatusNotAcceptable
@@ -113,6 +149,17 @@ func (gate *minionGateway) ServeHTTP(w http.ResponseWriter, r *http.Request) {
 	}
 }
 
+// reject 记录认证报文被拒绝的原因。
+func (gate *minionGateway) reject(r *http.Request, err error) {
+	gate.authResult(http.StatusBadRequest)
+	handshake.Record("mlink", r.RemoteAddr, err)
+}
+
+// authResult 统计认证结果。
+func (*minionGateway) authResult(code int) {
+	metrics.AgentAuth.With("mlink", metrics.AuthReason(code)).Inc()
+}
+
 // writeError 写入错误
 func (gate *minionGateway) writeError(w http.ResponseWriter, r *http.Request, code int, msg string, args ...string) {
 	if len(args) != 0 {
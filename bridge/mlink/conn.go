@@ -5,6 +5,8 @@ import (
 	"net"
 
 	"github.com/vela-ssoc/ssoc-broker/bridge/gateway"
+	"github.com/vela-ssoc/ssoc-broker/library/drain"
+	"github.com/vela-ssoc/ssoc-broker/library/quota"
 	"github.com/vela-ssoc/vela-common-mba/smux"
 )
 
@@ -12,6 +14,9 @@ type Infer interface {
 	Ident() gateway.Ident
 	Issue() gateway.Issue
 	Inet() net.IP
+
+	// Record 记录一次节点请求，用于会话统计。
+	Record(route string, failed bool)
 }
 
 type connect struct {
@@ -19,21 +24,78 @@ type connect struct {
 	ident gateway.Ident
 	issue gateway.Issue
 	mux   *smux.Session
+	stat  *sessionStat
+	guard *quota.Guard
+	done  chan struct{} // 会话结束并清理完毕后关闭
 }
 
 func (c *connect) Ident() gateway.Ident { return c.ident }
 func (c *connect) Issue() gateway.Issue { return c.issue }
 func (c *connect) Inet() net.IP         { return c.ident.Inet }
 
+func (c *connect) Record(route string, failed bool) {
+	c.stat.record(route, failed)
+}
+
+// session 生成当前连接的统计快照。
+func (c *connect) session() *Session {
+	ident := c.ident
+	sess := &Session{
+		ID:        c.id,
+		Inet:      inetPair(ident).Primary(),
+		Inet6:     inetPair(ident).Inet6(),
+		MachineID: ident.MachineID,
+		Hostname:  ident.Hostname,
+		Semver:    ident.Semver,
+		Protocol:  c.issue.Protocol,
+		Features:  c.issue.Features,
+		Interval:  drain.Duration(ident.Interval),
+		Streams:   c.mux.NumStreams(),
+		Breaches:  c.guard.Breaches(),
+	}
+	c.stat.fill(sess)
+
+	return sess
+}
+
 type contextKey struct{ name string }
 
 var minionCtxKey = &contextKey{name: "minion-context"}
 
+// Ctx 获取发起请求的节点信息。
 func Ctx(ctx context.Context) Infer {
-	if ctx != nil {
-		infer, _ := ctx.Value(minionCtxKey).(Infer)
+	if ctx == nil {
+		return nil
+	}
+	if infer, ok := ctx.Value(minionCtxKey).(Infer); ok {
 		return infer
 	}
 
 	return nil
 }
+
+// WithInfer 将节点信息放入 ctx，其它通道接入的节点适配为 Infer 后，
+// 调用 Ctx 的 agtapi 接口也可以使用。
+func WithInfer(ctx context.Context, infer Infer) context.Context {
+	return context.WithValue(ctx, minionCtxKey, infer)
+}
+
+// quotaListener 并发虚拟流超过配额的直接关闭，每个虚拟流单独限速。
+type quotaListener struct {
+	net.Listener
+	mux   *smux.Session
+	guard *quota.Guard
+}
+
+func (ql *quotaListener) Accept() (net.Conn, error) {
+	for {
+		conn, err := ql.Listener.Accept()
+		if err != nil {
+			return nil, err
+		}
+		if ql.guard.AllowStream(ql.mux.NumStreams()) {
+			return ql.guard.WrapConn(conn), nil
+		}
+		_ = conn.Close()
+	}
+}
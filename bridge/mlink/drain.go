@@ -0,0 +1,134 @@
+package mlink
+
+import (
+	"context"
+	"log/slog"
+	"time"
+
+	"github.com/vela-ssoc/ssoc-broker/library/drain"
+)
+
+var ErrBrokerDraining = drain.ErrDraining
+
+// Drainer broker 优雅下线：停止接入新节点，通知已连接的节点迁移到其它 broker 并分批释放连接。
+type Drainer interface {
+	// Drain 进入排空模式，该方法会阻塞直至所有节点释放完毕、ctx 取消或 Undrain。
+	Drain(ctx context.Context, opt DrainOption) error
+
+	// DrainAsync 进入排空模式后立即返回，已经处于排空模式返回 ErrBrokerDraining，
+	// 排空的结果从返回的通道中读取。
+	DrainAsync(ctx context.Context, opt DrainOption) (<-chan error, error)
+
+	// Undrain 退出排空模式，重新接入新节点，正在进行的排空会停止。
+	Undrain() error
+
+	// Draining 是否处于排空模式。
+	Draining() bool
+}
+
+// DrainOption 排空参数
+type DrainOption = drain.Option
+
+// MigrateNotice 通知节点迁移的报文
+type MigrateNotice struct {
+	Brokers []*MigrateBroker `json:"brokers"`
+}
+
+// MigrateBroker 可迁移的 broker 地址
+type MigrateBroker struct {
+	ID         int64    `json:"id,string"`
+	Name       string   `json:"name"`
+	Servername string   `json:"servername"`
+	LAN        []string `json:"lan"`
+	VIP        []string `json:"vip"`
+}
+
+func (hub *minionHub) Draining() bool {
+	return hub.drain.Draining()
+}
+
+func (hub *minionHub) Undrain() error {
+	if err := hub.drain.Resume(); err != nil {
+		return err
+	}
+	hub.resume.reopen()
+	hub.log.Warn("broker 退出排空模式")
+
+	return nil
+}
+
+func (hub *minionHub) Drain(parent context.Context, opt DrainOption) error {
+	done, err := hub.DrainAsync(parent, opt)
+	if err != nil {
+		return err
+	}
+
+	return <-done
+}
+
+func (hub *minionHub) DrainAsync(parent context.Context, opt DrainOption) (<-chan error, error) {
+	ctx, err := hub.drain.Begin(parent)
+	if err != nil {
+		return nil, err
+	}
+
+	done := make(chan error, 1)
+	go func() { done <- hub.drainWaves(ctx, opt) }()
+
+	return done, nil
+}
+
+// drainWaves 分批释放已连接的节点，调用方需已进入排空模式。
+func (hub *minionHub) drainWaves(ctx context.Context, opt DrainOption) error {
+	// 排空期间节点不会回来，延迟的下线回调立即执行，不能等到退出时丢失。
+	hub.resume.flush()
+	opt = opt.Format()
+	notice := hub.migrateNotice(ctx)
+	ids := hub.section.IDs()
+	attrs := []any{slog.Int("agents", len(ids)), slog.Int("wave", opt.Wave), slog.Duration("interval", opt.Interval)}
+	hub.log.Warn("broker 进入排空模式", attrs...)
+
+	release := func(ctx context.Context, mid int64) {
+		const path = "/api/v1/agent/notice/migrate"
+		cctx, cancel := context.WithTimeout(ctx, 5*time.Second)
+		defer cancel()
+		if exx := hub.Oneway(cctx, mid, path, notice); exx != nil {
+			hub.log.Debug("通知节点迁移失败", slog.Int64("minion_id", mid), slog.Any("error", exx))
+		}
+		hub.Knockout(mid)
+	}
+	if !drain.Waves(ctx, ids, opt, release, hub.Knockout) {
+		hub.log.Warn("排空已取消，剩余节点保持连接")
+		return nil
+	}
+	hub.log.Warn("节点已全部释放，准备重置节点状态")
+
+	return hub.ResetDB()
+}
+
+// migrateNotice 查询除自己以外在线的 broker 作为迁移目标。
+func (hub *minionHub) migrateNotice(parent context.Context) *MigrateNotice {
+	ctx, cancel := context.WithTimeout(parent, 10*time.Second)
+	defer cancel()
+
+	notice := &MigrateNotice{Brokers: []*MigrateBroker{}}
+	tbl := hub.qry.Broker
+	brks, err := tbl.WithContext(ctx).
+		Where(tbl.ID.Neq(hub.bid), tbl.Status.Is(true)).
+		Find()
+	if err != nil {
+		hub.log.Warn("查询可迁移的 broker 出错", slog.Any("error", err))
+		return notice
+	}
+	for _, brk := range brks {
+		notice.Brokers = append(notice.Brokers, &MigrateBroker{
+			ID:         brk.ID,
+			Name:       brk.Name,
+			Servername: brk.Servername,
+			LAN:        brk.LAN,
+			VIP:        brk.VIP,
+		})
+	}
+
+	return notice
+}
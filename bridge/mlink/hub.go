@@ -13,16 +13,24 @@ import (
 	"net/url"
 	"strconv"
 	"strings"
+	"sync/atomic"
 	"time"
 
 	"github.com/gorilla/websocket"
 	"github.com/vela-ssoc/ssoc-broker/bridge/gateway"
 	"github.com/vela-ssoc/ssoc-broker/bridge/telecom"
+	"github.com/vela-ssoc/ssoc-broker/library/capability"
+	"github.com/vela-ssoc/ssoc-broker/library/collision"
+	"github.com/vela-ssoc/ssoc-broker/library/drain"
+	"github.com/vela-ssoc/ssoc-broker/library/identity"
+	"github.com/vela-ssoc/ssoc-broker/library/inetx"
+	"github.com/vela-ssoc/ssoc-broker/library/quota"
 	"github.com/vela-ssoc/ssoc-common-mb/dal/model"
 	"github.com/vela-ssoc/ssoc-common-mb/dal/query"
 	"github.com/vela-ssoc/ssoc-common-mb/problem"
 	"github.com/vela-ssoc/vela-common-mba/netutil"
 	"github.com/vela-ssoc/vela-common-mba/smux"
+	"gorm.io/gen/field"
 	"gorm.io/gorm/clause"
 )
 
@@ -35,16 +43,33 @@ var (
 	ErrMinionOffline   = errors.New("节点未在线")
 )
 
+// HeaderNonce 身份校验时 broker 下发一次性随机数的 Header
+const HeaderNonce = "X-Auth-Nonce"
+
 type Linker interface {
 	ResetDB() error
+	Identity() identity.Verifier
+
+	// Collision 机器码冲突检测。
+	Collision() collision.Detector
+
+	// SetQuota 修改单个节点的资源配额，仅对之后上线的节点生效。
+	SetQuota(cfg quota.Config)
 	gateway.Joiner
 	Huber
+	Drainer
 	Link() telecom.Linker
 }
 
 type Huber interface {
 	ConnectIDs() []int64
 
+	// Sessions 当前所有节点连接的会话统计快照。
+	Sessions() []*Session
+
+	// Features 查询在线节点协商的特性，节点不在线返回 false。
+	Features(id int64) (capability.Set, bool)
+
 	Forward(http.ResponseWriter, *http.Request)
 
 	Stream(ctx context.Context, id int64, path string, header http.Header) (*websocket.Conn, *http.Response, error)
@@ -53,6 +78,18 @@ type Huber interface {
 
 	Unicast(ctx context.Context, id int64, path string, body, resp any) error
 
+	// Multicast 向多个节点发送消息，立即返回任务对象，通过任务对象可以查询每个节点的调用结果。
+	Multicast(ctx context.Context, ids []int64, path string, body any) *MulticastJob
+
+	// MulticastFunc 对多个节点执行自定义调用，name 仅用于标识任务。
+	MulticastFunc(ctx context.Context, ids []int64, name string, fn func(ctx context.Context, id int64) error) *MulticastJob
+
+	// Job 根据任务 ID 查询多播任务，任务不存在或已过期返回 nil。
+	Job(id string) *MulticastJob
+
+	// SetMulticast 修改多播任务参数，只影响之后创建的任务。
+	SetMulticast(opt MulticastOption)
+
 	// Knockout 根据 minionID 断开节点连接
 	Knockout(mid int64)
 }
@@ -71,10 +108,17 @@ func LinkHub(qry *query.Query, link telecom.Linker, handler http.Handler, phase
 		section: newSegmentMap(128, 64), // 预分配 8192 个连接空间，已经足够使用了。
 		phase:   phase,
 		random:  random,
+		jobs:    newJobStore(),
+		verify:  identity.New(qry, log),
+		collide: collision.New(qry, collision.Option{}, log),
+		resume:  newResumption(resumeGrace),
 	}
+	hub.SetQuota(quota.DefaultConfig())
+	hub.SetMulticast(MulticastOption{})
 
 	trip := &http.Transport{DialContext: hub.dialContext}
 	hub.client = netutil.NewClient(trip)
+	hub.mgt = netutil.NewClient(&http.Transport{DialContext: link.DialContext})
 	hub.stream = netutil.NewStream(hub.dialContext)
 	hub.proxy = netutil.NewForward(trip, hub.forwardError)
 
@@ -87,6 +131,7 @@ type minionHub struct {
 	handler http.Handler
 	log     *slog.Logger
 	client  netutil.HTTPClient
+	mgt     netutil.HTTPClient // 请求中心端
 	proxy   netutil.Forwarder
 	stream  netutil.Streamer
 	phase   NodePhaser
@@ -94,6 +139,26 @@ type minionHub struct {
 	bid     int64  // 当前 broker ID
 	name    string // 当前 broker 名字
 	random  *rand.Rand
+	jobs    *jobStore
+	multi   atomic.Pointer[MulticastOption]
+	verify  identity.Verifier
+	collide collision.Detector
+	quota   atomic.Pointer[quota.Config]
+	resume  *resumption
+	// drain 排空模式下不再接入新节点
+	drain drain.Gate
+}
+
+func (hub *minionHub) Identity() identity.Verifier {
+	return hub.verify
+}
+
+func (hub *minionHub) Collision() collision.Detector {
+	return hub.collide
+}
+
+func (hub *minionHub) SetQuota(cfg quota.Config) {
+	hub.quota.Store(&cfg)
 }
 
 func (hub *minionHub) Link() telecom.Linker {
@@ -102,27 +167,66 @@ func (hub *minionHub) Link() telecom.Linker {
 
 func (hub *minionHub) Auth(ctx context.Context, ident gateway.Ident) (gateway.Issue, http.Header, int, error) {
 	var issue gateway.Issue
+	if hub.drain.Draining() {
+		return issue, nil, http.StatusServiceUnavailable, ErrBrokerDraining
+	}
+
+	// 协商握手协议版本与特性，老版本 agent 根据版本号推断。
+	issue.Protocol, issue.Features = capability.Negotiate(ident.Protocol, ident.Features, ident.Semver)
 
 	// FIXME 旧版本 agent 没有机器 ID 的概念，但是也要保证能够上线。
 	//machineID := ident.MachineID
 	//if machineID == "" {
 	//	return issue, nil, http.StatusBadRequest, ErrMinionMachineID
 	//}
-	// 4.0 版本的 agent 必须要有机器 ID。
-	if semver := ident.Semver; semver != "" &&
-		strings.HasPrefix(semver, "4.") &&
-		ident.MachineID == "" {
+	// 支持特性协商（协议版本 >= 1）或支持机器码的 agent 必须要有机器 ID，
+	// 即使 agent 在特性列表中漏报了 machine-id 也不能跳过校验。
+	if (issue.Protocol >= 1 || issue.Features.Has(capability.MachineID)) && ident.MachineID == "" {
 		return issue, nil, http.StatusBadRequest, ErrMinionMachineID
 	}
 
-	ip := ident.Inet.To4()
-	if ip == nil || ip.IsLoopback() || ip.IsUnspecified() {
+	// 支持 IPv4-only、IPv6-only 和双栈节点。
+	if !inetPair(ident).Valid() {
 		return issue, nil, http.StatusBadRequest, ErrMinionBadInet
 	}
 
+	// 克隆主机会携带相同的机器码，按照冲突策略决定使用哪个机器码查找节点。
+	// 冲突在身份校验通过后才记录，防止伪造的认证请求污染冲突记录。
+	lookup := ident
+	collide := collision.Claim{
+		MachineID: ident.MachineID,
+		Inet:      inetPair(ident).Primary(),
+		Hostname:  ident.Hostname,
+		MAC:       ident.MAC,
+	}
+	claim := identity.Claim{
+		MachineID: ident.MachineID,
+		Inet:      inetPair(ident).Primary(),
+		PublicKey: ident.PublicKey,
+		Nonce:     ident.Nonce,
+		Signature: ident.Signature,
+
+		KeyRequired: issue.Features.Has(capability.IdentityKey),
+	}
+	verdict, err := hub.collide.Observe(ctx, collide)
+	if err != nil {
+		if !errors.Is(err, collision.ErrCollision) {
+			return issue, nil, http.StatusInternalServerError, err
+		}
+		// 被隔离的新主机还没有对应的节点，只有证明持有所声明公钥的私钥后才记录冲突。
+		if exx := hub.verify.Prove(claim); exx != nil {
+			return hub.unverified(issue, ident, 0, exx)
+		}
+		if code, exx := hub.commitCollision(ctx, collide, 0, ident); exx != nil && !errors.Is(exx, collision.ErrCollision) {
+			return issue, nil, code, exx
+		}
+		return issue, nil, http.StatusForbidden, err
+	}
+	lookup.MachineID = verdict.MachineID
+
 	// 先通过机器码查询，如果查询不到再通过 inet 查询（兼容老的方式）。
 	// 如果通过 inet 查询出来并且 machine_id 为空，则进行合并
-	mon, err := hub.lookupOrCreate(ctx, ident)
+	mon, err := hub.lookupOrCreate(ctx, lookup)
 	if err != nil {
 		return issue, nil, http.StatusInternalServerError, err
 	}
@@ -131,11 +235,32 @@ func (hub *minionHub) Auth(ctx context.Context, ident gateway.Ident) (gateway.Is
 	if status == model.MSDelete {
 		return issue, nil, http.StatusForbidden, ErrMinionRemove
 	}
+
+	// 校验节点身份，必须在接管旧会话之前，防止冒充的节点挤掉正常节点。
+	claim.MinionID = mon.ID
+	if err = hub.verify.Verify(ctx, claim); err != nil {
+		return hub.unverified(issue, ident, mon.ID, err)
+	}
+	if verdict.Collided {
+		if code, exx := hub.commitCollision(ctx, collide, mon.ID, ident); exx != nil {
+			return issue, nil, code, exx
+		}
+	}
 	if status == model.MSOnline {
-		return issue, nil, http.StatusConflict, ErrMinionOnline
+		// 原会话可能因为 broker 宕机或 TCP 半开连接而残留，检查是否可以接管。
+		ok, exx := hub.takeover(ctx, mon, ident)
+		if exx != nil {
+			return issue, nil, http.StatusInternalServerError, exx
+		}
+		if !ok {
+			hub.phase.Repeated(mon.ID, ident, time.Now())
+			return issue, nil, http.StatusConflict, ErrMinionOnline
+		}
 	}
 
 	issue.ID = mon.ID
+	issue.Resumed = hub.resume.resume(ident.Ticket, mon.ID, ident)
+	issue.Ticket = hub.resume.issue(mon.ID, ident)
 	// 随机生成一个 32-64 位长度的加密密钥
 	psz := hub.random.Intn(33) + 32
 	passwd := make([]byte, psz)
@@ -145,32 +270,90 @@ func (hub *minionHub) Auth(ctx context.Context, ident gateway.Ident) (gateway.Is
 	return issue, nil, http.StatusAccepted, nil
 }
 
+// unverified 身份校验未通过时的响应，需要签名时下发 nonce。
+func (hub *minionHub) unverified(issue gateway.Issue, ident gateway.Ident, minionID int64, err error) (gateway.Issue, http.Header, int, error) {
+	switch {
+	case errors.Is(err, identity.ErrChallenge):
+		header := http.Header{HeaderNonce: []string{hub.verify.Challenge(ident.MachineID)}}
+		return issue, header, http.StatusUnauthorized, err
+	case errors.Is(err, identity.ErrBadSignature), errors.Is(err, identity.ErrBadPublicKey),
+		errors.Is(err, identity.ErrKeyRequired):
+		return issue, nil, http.StatusUnauthorized, err
+	case errors.Is(err, identity.ErrQuarantined):
+		hub.phase.Quarantined(minionID, ident, err.Error(), time.Now())
+		return issue, nil, http.StatusForbidden, err
+	default:
+		return issue, nil, http.StatusInternalServerError, err
+	}
+}
+
+// commitCollision 记录已通过身份校验的冲突，同一台新主机只在首次冲突时记录事件。
+// 被隔离的新主机还没有对应的节点，minionID 为 0。
+func (hub *minionHub) commitCollision(ctx context.Context, claim collision.Claim, minionID int64, ident gateway.Ident) (int, error) {
+	verdict, err := hub.collide.Commit(ctx, claim)
+	if err != nil && !errors.Is(err, collision.ErrCollision) {
+		return http.StatusInternalServerError, err
+	}
+	if verdict.Collided && verdict.First {
+		reason := "机器码与其它主机冲突"
+		if err != nil {
+			reason = err.Error()
+		} else if verdict.Derived {
+			reason += "，使用派生的机器码：" + verdict.MachineID
+		}
+		hub.phase.Collided(minionID, ident, reason, time.Now())
+	}
+	if err != nil {
+		return http.StatusForbidden, err
+	}
+
+	return http.StatusOK, nil
+}
+
 func (hub *minionHub) Join(parent context.Context, tran net.Conn, ident gateway.Ident, issue gateway.Issue) error {
+	if hub.drain.Draining() {
+		return ErrBrokerDraining
+	}
+
 	cfg := smux.DefaultConfig()
 	cfg.Passwd = issue.Passwd
 	if inter := ident.Interval; inter > 0 {
 		cfg.ReadTimeout = 3 * inter // 3 倍心跳周期还未收到消息，强制断开连接
 	}
-	mux := smux.Server(tran, cfg)
+	id := issue.ID
+	pair := inetPair(ident)
+	inet := pair.Primary()
+	now := time.Now()
+	stat := newSessionStat(tran.RemoteAddr(), now)
+
+	// 资源超限先限流，一段时间内违规次数过多则断开连接。
+	var mux *smux.Session
+	guard := quota.NewGuard(*hub.quota.Load(), func(reason string) {
+		hub.log.Warn(fmt.Sprintf("节点 %s(%d) 资源使用多次超限，断开连接：%s", inet, id, reason))
+		_ = mux.Close()
+	})
+	mux = smux.Server(&statConn{Conn: tran, stat: stat}, cfg)
 	//goland:noinspection GoUnhandledErrorResult
 	defer mux.Close()
 
-	id := issue.ID
-	inet := ident.Inet.String()
-	now := time.Now()
 	sid := strconv.FormatInt(id, 10) // 方便 dialContext
 	conn := &connect{
 		id:    id,
 		ident: ident,
 		issue: issue,
 		mux:   mux,
+		stat:  stat,
+		guard: guard,
+		done:  make(chan struct{}),
 	}
+	// 最先注册最后执行，接管时以此判断旧会话已清理完毕。
+	defer close(conn.done)
 
 	if !hub.section.Put(sid, conn) {
 		hub.phase.Repeated(id, ident, now)
 		return ErrMinionOnline
 	}
-	defer hub.section.Del(sid)
+	defer hub.section.Remove(sid, conn)
 
 	nullableAt := sql.NullTime{Valid: true, Time: now}
 	brokerID, brokerName := hub.link.Ident().ID, hub.link.Issue().Name
@@ -183,7 +366,8 @@ func (hub *minionHub) Join(parent context.Context, tran net.Conn, ident gateway.
 			Where(minionTbl.ID.Eq(id), minionTbl.Status.Eq(offline)).
 			UpdateSimple(
 				minionTbl.Status.Value(online),
-				minionTbl.Inet.Value(inet),
+				minionTbl.Inet.Value(pair.Inet()),
+				minionTbl.Inet6.Value(pair.Inet6()),
 				minionTbl.MAC.Value(ident.MAC),
 				minionTbl.Goos.Value(ident.Goos),
 				minionTbl.Arch.Value(ident.Arch),
@@ -213,13 +397,14 @@ func (hub *minionHub) Join(parent context.Context, tran net.Conn, ident gateway.
 		}
 	}()
 
-	{
+	if !issue.Resumed {
 		ctx, cancel := context.WithTimeout(parent, 20*time.Second)
 		// 每次上线都要重新初始化内置标签，长时间运行后，服务器可能重装系统。
 		_ = hub.qry.Transaction(func(tx *query.Query) error {
 			const kind = model.TkLifelong
-			tags := model.MinionTags{
-				&model.MinionTag{MinionID: id, Tag: inet, Kind: kind},
+			tags := make(model.MinionTags, 0, 4)
+			for _, tag := range pair.Tags() {
+				tags = append(tags, &model.MinionTag{MinionID: id, Tag: tag, Kind: kind})
 			}
 			if goos := ident.Goos; goos != "" {
 				tags = append(tags, &model.MinionTag{MinionID: id, Tag: goos, Kind: kind})
@@ -243,15 +428,28 @@ func (hub *minionHub) Join(parent context.Context, tran net.Conn, ident gateway.
 	srv := &http.Server{
 		Handler: hub.handler,
 		BaseContext: func(net.Listener) context.Context {
-			return context.WithValue(context.Background(), minionCtxKey, conn)
+			ctx := context.WithValue(context.Background(), minionCtxKey, conn)
+			return quota.WithContext(ctx, guard)
 		},
 	}
 
-	hub.phase.Connected(hub, ident, issue, now)
-	_ = srv.Serve(mux)
+	// 上线成功后才取消原会话延迟的下线回调，上线失败时下线回调按时执行。
+	if issue.Resumed && !hub.resume.claim(id) {
+		issue.Resumed = false
+	}
+	if issue.Resumed {
+		hub.log.Info(fmt.Sprintf("节点 %s(%d) 会话恢复", inet, id))
+	} else {
+		hub.phase.Connected(hub, ident, issue, now)
+	}
+	hub.resume.attach(issue.Ticket)
+	_ = srv.Serve(&quotaListener{Listener: mux, mux: mux, guard: guard})
 	after := time.Now()
 	du := after.Sub(now)
-	hub.phase.Disconnected(hub, ident, issue, after, du)
+	// 下线回调延迟执行，节点在有效期内恢复会话则不再执行。
+	hub.resume.detach(issue.Ticket, id, func() {
+		hub.phase.Disconnected(hub, ident, issue, after, du)
+	})
 
 	return nil
 }
@@ -306,6 +504,25 @@ func (hub *minionHub) ConnectIDs() []int64 {
 	return hub.section.IDs()
 }
 
+func (hub *minionHub) Features(id int64) (capability.Set, bool) {
+	conn := hub.section.Get(strconv.FormatInt(id, 10))
+	if conn == nil {
+		return nil, false
+	}
+
+	return conn.issue.Features, true
+}
+
+func (hub *minionHub) Sessions() []*Session {
+	conns := hub.section.Conns()
+	ret := make([]*Session, 0, len(conns))
+	for _, conn := range conns {
+		ret = append(ret, conn.session())
+	}
+
+	return ret
+}
+
 func (hub *minionHub) Knockout(mid int64) {
 	if mid == 0 {
 		return
@@ -361,7 +578,7 @@ func (hub *minionHub) dialContext(_ context.Context, _, addr string) (net.Conn,
 	if stream, exx := conn.mux.OpenStream(); exx != nil {
 		return nil, exx
 	} else {
-		return stream, nil
+		return conn.guard.WrapConn(stream), nil
 	}
 }
 
@@ -387,8 +604,7 @@ func (hub *minionHub) lookupOrCreate(ctx context.Context, ident gateway.Ident) (
 
 	// FIXME 搜索条件暂时忽略机器 ID，因为 3.0 升级到 4.0 有可能某些原因回退到低版本，
 	// 	尽管此时已经绑定了机器 ID。
-	inet := ident.Inet.String()
-	mon1, err1 := dao.Where(tbl.Inet.Eq(inet) /*, tbl.MachineID.Eq("")*/).First()
+	mon1, err1 := dao.Where(inetCond(tbl.Inet, tbl.Inet6, inetPair(ident)) /*, tbl.MachineID.Eq("")*/).First()
 	if err1 == nil {
 		return mon1, nil
 	}
@@ -407,8 +623,7 @@ func (hub *minionHub) lookupByMachineID(ctx context.Context, ident gateway.Ident
 	}
 
 	// 尝试通过 inet 查找（自动给老的 agent 绑定机器码）
-	inet := ident.Inet.String()
-	mon1, err1 := dao.Where(tbl.Inet.Eq(inet), tbl.MachineID.Eq("")).First()
+	mon1, err1 := dao.Where(inetCond(tbl.Inet, tbl.Inet6, inetPair(ident)), tbl.MachineID.Eq("")).First()
 	if err1 == nil {
 		// 关联绑定机器 ID
 		_, _ = dao.Where(tbl.ID.Eq(mon1.ID)).UpdateSimple(tbl.MachineID.Value(machineID))
@@ -419,9 +634,11 @@ func (hub *minionHub) lookupByMachineID(ctx context.Context, ident gateway.Ident
 }
 
 func (hub *minionHub) createNew(ctx context.Context, ident gateway.Ident) (*model.Minion, error) {
+	pair := inetPair(ident)
 	data := &model.Minion{
 		MachineID:  ident.MachineID,
-		Inet:       ident.Inet.String(),
+		Inet:       pair.Inet(),
+		Inet6:      pair.Inet6(),
 		MAC:        ident.MAC,
 		Goos:       ident.Goos,
 		Arch:       ident.Arch,
@@ -438,6 +655,22 @@ func (hub *minionHub) createNew(ctx context.Context, ident gateway.Ident) (*mode
 	if err := dao.Create(data); err != nil {
 		return nil, err
 	}
+	hub.phase.Created(data.ID, pair.Primary(), time.Now())
 
 	return data, nil
 }
+
+// inetPair 节点上报的双栈地址。
+func inetPair(ident gateway.Ident) inetx.Pair {
+	return inetx.FromIP(ident.Inet, ident.Inet6)
+}
+
+// inetCond 按照地址查询节点：优先 IPv4，IPv6-only 节点使用 inet 为空且 inet6 相等的记录，
+// 与 temporary 通道的查询条件保持一致，避免匹配到 IPv6 相同的双栈节点。
+func inetCond(inet, inet6 field.String, pair inetx.Pair) field.Expr {
+	if v4 := pair.Inet(); v4 != "" {
+		return inet.Eq(v4)
+	}
+
+	return field.And(inet.Eq(""), inet6.Eq(pair.Inet6()))
+}
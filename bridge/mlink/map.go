@@ -6,7 +6,10 @@ type container interface {
 	Put(id string, conn *connect) bool
 	Get(id string) *connect
 	Del(id string) *connect
+	// Remove 仅当 id 对应的连接仍是 conn 时才删除，防止误删接管后的新连接。
+	Remove(id string, conn *connect) bool
 	IDs() []int64
+	Conns() []*connect
 }
 
 type Iter interface {
@@ -56,6 +59,16 @@ func (sm *safeMap) Del(id string) *connect {
 	return c
 }
 
+func (sm *safeMap) Remove(id string, conn *connect) bool {
+	sm.mutex.Lock()
+	defer sm.mutex.Unlock()
+	if c, ok := sm.elems[id]; ok && c == conn {
+		delete(sm.elems, id)
+		return true
+	}
+	return false
+}
+
 func (sm *safeMap) connections() []*connect {
 	sm.mutex.RLock()
 	defer sm.mutex.RUnlock()
@@ -101,6 +114,10 @@ func (sm *segmentMap) Del(id string) *connect {
 	return sm.getSLOT(id).Del(id)
 }
 
+func (sm *segmentMap) Remove(id string, conn *connect) bool {
+	return sm.getSLOT(id).Remove(id, conn)
+}
+
 func (sm *segmentMap) IDs() []int64 {
 	ret := make([]int64, 0, 2000)
 	for _, c := range sm.slot {
@@ -114,6 +131,15 @@ func (sm *segmentMap) IDs() []int64 {
 	return ret
 }
 
+func (sm *segmentMap) Conns() []*connect {
+	ret := make([]*connect, 0, 2000)
+	for _, c := range sm.slot {
+		ret = append(ret, c.connections()...)
+	}
+
+	return ret
+}
+
 // getSLOT 根据 key 计算所在的存储桶
 func (sm *segmentMap) getSLOT(key string) *safeMap {
 	hash := sm.fnv32(key)
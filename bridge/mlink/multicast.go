@@ -0,0 +1,320 @@
+package mlink
+
+import (
+	"context"
+	"errors"
+	"net"
+	"strconv"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/vela-ssoc/vela-common-mba/netutil"
+)
+
+// CallStatus 单个节点的调用结果状态。
+type CallStatus string
+
+const (
+	CallOK        CallStatus = "ok"         // 调用成功
+	CallOffline   CallStatus = "offline"    // 节点不在线
+	CallTimeout   CallStatus = "timeout"    // 调用超时
+	CallHTTPError CallStatus = "http_error" // 节点响应了非 2xx/3xx 状态码
+	CallError     CallStatus = "error"      // 其它错误
+	CallCanceled  CallStatus = "canceled"   // 任务被取消，未执行
+)
+
+// CallResult 单个节点的调用结果。
+type CallResult struct {
+	ID         int64         `json:"id,string"`
+	Status     CallStatus    `json:"status"`
+	Code       int           `json:"code,omitempty"`  // HTTP 状态码，仅 http_error 时有值
+	Body       string        `json:"body,omitempty"`  // 节点响应的报文，仅 http_error 时有值，最多 1024 字节
+	Error      string        `json:"error,omitempty"` // 错误信息
+	Elapsed    time.Duration `json:"elapsed"`
+	FinishedAt time.Time     `json:"finished_at"`
+}
+
+// JobSnapshot 多播任务的进度快照。
+type JobSnapshot struct {
+	ID        string                `json:"id"`
+	Path      string                `json:"path"`
+	Total     int                   `json:"total"`
+	Finished  int                   `json:"finished"`
+	Succeed   int                   `json:"succeed"`
+	Failed    int                   `json:"failed"`
+	Done      bool                  `json:"done"`
+	CreatedAt time.Time             `json:"created_at"`
+	DoneAt    time.Time             `json:"done_at,omitzero"`
+	Results   map[int64]*CallResult `json:"results"`
+}
+
+// MulticastOption 多播任务参数，为零的字段使用默认值。
+type MulticastOption struct {
+	Concurrency int           `json:"concurrency"` // 单个任务的最大并发数，默认 128
+	Timeout     time.Duration `json:"timeout"`     // 单个节点调用超时时间，默认 1m
+	Retention   time.Duration `json:"retention"`   // 任务结束后保留多久以供查询，默认 30m
+}
+
+func (opt MulticastOption) format() MulticastOption {
+	if opt.Concurrency <= 0 {
+		opt.Concurrency = 128
+	}
+	if opt.Timeout <= 0 {
+		opt.Timeout = time.Minute
+	}
+	if opt.Retention <= 0 {
+		opt.Retention = 30 * time.Minute
+	}
+
+	return opt
+}
+
+var multicastSeq atomic.Uint64
+
+// MulticastJob 向多个节点发起调用的任务，可以轮询进度或流式读取结果。
+type MulticastJob struct {
+	id        string
+	path      string
+	total     int
+	createdAt time.Time
+	cancel    context.CancelFunc
+	done      chan struct{}
+
+	mutex   sync.Mutex
+	results []*CallResult
+	succeed int
+	doneAt  time.Time
+	notify  chan struct{} // 有新结果时关闭并替换
+}
+
+func newMulticastJob(path string, total int, cancel context.CancelFunc) *MulticastJob {
+	now := time.Now()
+	seq := multicastSeq.Add(1)
+	id := strconv.FormatInt(now.UnixMilli(), 36) + "-" + strconv.FormatUint(seq, 36)
+
+	return &MulticastJob{
+		id:        id,
+		path:      path,
+		total:     total,
+		createdAt: now,
+		cancel:    cancel,
+		done:      make(chan struct{}),
+		results:   make([]*CallResult, 0, total),
+		notify:    make(chan struct{}),
+	}
+}
+
+// ID 任务 ID
+func (job *MulticastJob) ID() string { return job.id }
+
+// Done 任务结束时关闭
+func (job *MulticastJob) Done() <-chan struct{} { return job.done }
+
+// Cancel 取消任务，尚未执行的节点结果为 canceled。
+func (job *MulticastJob) Cancel() { job.cancel() }
+
+// Wait 阻塞等待任务结束或 ctx 取消。
+func (job *MulticastJob) Wait(ctx context.Context) error {
+	select {
+	case <-job.done:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+// Snapshot 任务当前的进度快照。
+func (job *MulticastJob) Snapshot() *JobSnapshot {
+	job.mutex.Lock()
+	defer job.mutex.Unlock()
+
+	finished := len(job.results)
+	snap := &JobSnapshot{
+		ID:        job.id,
+		Path:      job.path,
+		Total:     job.total,
+		Finished:  finished,
+		Succeed:   job.succeed,
+		Failed:    finished - job.succeed,
+		Done:      !job.doneAt.IsZero(),
+		CreatedAt: job.createdAt,
+		DoneAt:    job.doneAt,
+		Results:   make(map[int64]*CallResult, finished),
+	}
+	for _, res := range job.results {
+		snap.Results[res.ID] = res
+	}
+
+	return snap
+}
+
+// Next 从 offset 开始读取已完成的结果，当没有新结果时可以等待返回的 chan
+// 关闭后再次读取，done 表示任务已结束且所有结果已读完。
+func (job *MulticastJob) Next(offset int) (results []*CallResult, wait <-chan struct{}, done bool) {
+	job.mutex.Lock()
+	defer job.mutex.Unlock()
+
+	if offset < 0 {
+		offset = 0
+	}
+	if offset < len(job.results) {
+		results = job.results[offset:]
+	}
+	done = !job.doneAt.IsZero() && offset+len(results) >= len(job.results)
+
+	return results, job.notify, done
+}
+
+func (job *MulticastJob) add(res *CallResult) {
+	job.mutex.Lock()
+	defer job.mutex.Unlock()
+
+	job.results = append(job.results, res)
+	if res.Status == CallOK {
+		job.succeed++
+	}
+	close(job.notify)
+	job.notify = make(chan struct{})
+}
+
+func (job *MulticastJob) finish() {
+	job.mutex.Lock()
+	job.doneAt = time.Now()
+	close(job.notify)
+	job.notify = make(chan struct{})
+	job.mutex.Unlock()
+
+	job.cancel()
+	close(job.done)
+}
+
+func (hub *minionHub) SetMulticast(opt MulticastOption) {
+	opt = opt.format()
+	hub.multi.Store(&opt)
+}
+
+func (hub *minionHub) Multicast(ctx context.Context, ids []int64, path string, body any) *MulticastJob {
+	return hub.multicast(ctx, ids, path, func(cctx context.Context, id int64) error {
+		return hub.Oneway(cctx, id, path, body)
+	})
+}
+
+func (hub *minionHub) MulticastFunc(ctx context.Context, ids []int64, name string, fn func(ctx context.Context, id int64) error) *MulticastJob {
+	return hub.multicast(ctx, ids, name, fn)
+}
+
+func (hub *minionHub) Job(id string) *MulticastJob {
+	return hub.jobs.get(id)
+}
+
+func (hub *minionHub) multicast(parent context.Context, ids []int64, path string, fn func(context.Context, int64) error) *MulticastJob {
+	// 任务与发起请求的生命周期解绑，HTTP 请求结束后任务继续执行。
+	opt := *hub.multi.Load()
+	ctx, cancel := context.WithCancel(context.WithoutCancel(parent))
+	job := newMulticastJob(path, len(ids), cancel)
+	hub.jobs.put(job, opt.Retention)
+
+	go func() {
+		defer job.finish()
+
+		sem := make(chan struct{}, opt.Concurrency)
+		wg := new(sync.WaitGroup)
+		for _, id := range ids {
+			select {
+			case sem <- struct{}{}:
+			case <-ctx.Done():
+				job.add(&CallResult{ID: id, Status: CallCanceled, Error: ctx.Err().Error(), FinishedAt: time.Now()})
+				continue
+			}
+
+			wg.Add(1)
+			go func(mid int64) {
+				defer func() {
+					<-sem
+					wg.Done()
+				}()
+				job.add(hub.call(ctx, mid, opt.Timeout, fn))
+			}(id)
+		}
+		wg.Wait()
+	}()
+
+	return job
+}
+
+func (hub *minionHub) call(parent context.Context, id int64, timeout time.Duration, fn func(context.Context, int64) error) *CallResult {
+	start := time.Now()
+	res := &CallResult{ID: id, Status: CallOK}
+	if hub.section.Get(strconv.FormatInt(id, 10)) == nil {
+		res.Status = CallOffline
+		res.Error = ErrMinionOffline.Error()
+	} else {
+		ctx, cancel := context.WithTimeout(parent, timeout)
+		err := fn(ctx, id)
+		cancel()
+		if err != nil {
+			res.Error = err.Error()
+			res.Status, res.Code, res.Body = hub.classify(err)
+		}
+	}
+	res.FinishedAt = time.Now()
+	res.Elapsed = res.FinishedAt.Sub(start)
+
+	return res
+}
+
+// classify 将调用错误归类。
+func (*minionHub) classify(err error) (CallStatus, int, string) {
+	var he *netutil.HTTPError
+	if errors.As(err, &he) {
+		return CallHTTPError, he.Code, string(he.Body)
+	}
+	if errors.Is(err, ErrMinionOffline) {
+		return CallOffline, 0, ""
+	}
+	if errors.Is(err, context.Canceled) {
+		return CallCanceled, 0, ""
+	}
+	if errors.Is(err, context.DeadlineExceeded) {
+		return CallTimeout, 0, ""
+	}
+	var ne net.Error
+	if errors.As(err, &ne) && ne.Timeout() {
+		return CallTimeout, 0, ""
+	}
+
+	return CallError, 0, ""
+}
+
+func newJobStore() *jobStore {
+	return &jobStore{jobs: make(map[string]*MulticastJob, 16)}
+}
+
+// jobStore 保存多播任务，任务结束后保留一段时间以供查询。
+type jobStore struct {
+	mutex sync.Mutex
+	jobs  map[string]*MulticastJob
+}
+
+func (js *jobStore) put(job *MulticastJob, retention time.Duration) {
+	js.mutex.Lock()
+	js.jobs[job.id] = job
+	js.mutex.Unlock()
+
+	go func() {
+		<-job.done
+		time.AfterFunc(retention, func() {
+			js.mutex.Lock()
+			delete(js.jobs, job.id)
+			js.mutex.Unlock()
+		})
+	}()
+}
+
+func (js *jobStore) get(id string) *MulticastJob {
+	js.mutex.Lock()
+	defer js.mutex.Unlock()
+
+	return js.jobs[id]
+}
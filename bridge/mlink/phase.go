@@ -13,6 +13,15 @@ type NodePhaser interface {
 	// Repeated 节点重复登录
 	Repeated(id int64, ident gateway.Ident, at time.Time)
 
+	// Quarantined 节点身份校验不通过，已被隔离
+	Quarantined(id int64, ident gateway.Ident, reason string, at time.Time)
+
+	// Collided 节点机器码与其它主机冲突，id 为节点最终使用的 ID，被拒绝上线时为 0
+	Collided(id int64, ident gateway.Ident, reason string, at time.Time)
+
+	// Takeover 节点原会话已失效，由新会话接管
+	Takeover(id int64, ident gateway.Ident, brokerID int64, reason string, at time.Time)
+
 	// Connected 节点连接成功
 	Connected(lnk Linker, ident gateway.Ident, issue gateway.Issue, at time.Time)
 
@@ -0,0 +1,116 @@
+package mlink
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/vela-ssoc/ssoc-broker/bridge/gateway"
+	"github.com/vela-ssoc/ssoc-broker/library/hookbus"
+)
+
+// Phasers 将多个 NodePhaser 串联，按照顺序依次调用。
+func Phasers(phs ...NodePhaser) NodePhaser {
+	return phaseChain(phs)
+}
+
+type phaseChain []NodePhaser
+
+func (pc phaseChain) Created(id int64, inet string, at time.Time) {
+	for _, ph := range pc {
+		ph.Created(id, inet, at)
+	}
+}
+
+func (pc phaseChain) Repeated(id int64, ident gateway.Ident, at time.Time) {
+	for _, ph := range pc {
+		ph.Repeated(id, ident, at)
+	}
+}
+
+func (pc phaseChain) Quarantined(id int64, ident gateway.Ident, reason string, at time.Time) {
+	for _, ph := range pc {
+		ph.Quarantined(id, ident, reason, at)
+	}
+}
+
+func (pc phaseChain) Collided(id int64, ident gateway.Ident, reason string, at time.Time) {
+	for _, ph := range pc {
+		ph.Collided(id, ident, reason, at)
+	}
+}
+
+func (pc phaseChain) Takeover(id int64, ident gateway.Ident, brokerID int64, reason string, at time.Time) {
+	for _, ph := range pc {
+		ph.Takeover(id, ident, brokerID, reason, at)
+	}
+}
+
+func (pc phaseChain) Connected(lnk Linker, ident gateway.Ident, issue gateway.Issue, at time.Time) {
+	for _, ph := range pc {
+		ph.Connected(lnk, ident, issue, at)
+	}
+}
+
+func (pc phaseChain) Disconnected(lnk Linker, ident gateway.Ident, issue gateway.Issue, at time.Time, du time.Duration) {
+	for _, ph := range pc {
+		ph.Disconnected(lnk, ident, issue, at, du)
+	}
+}
+
+// HookPhaser 将节点生命周期事件发布到事件总线。
+func HookPhaser(bus hookbus.Bus, brokerID int64) NodePhaser {
+	return &hookPhaser{bus: bus, bid: brokerID}
+}
+
+type hookPhaser struct {
+	bus hookbus.Bus
+	bid int64
+}
+
+func (hp *hookPhaser) Created(id int64, inet string, at time.Time) {
+	hp.bus.Publish(&hookbus.Event{Kind: hookbus.KindCreated, MinionID: id, Inet: inet, BrokerID: hp.bid, OccurAt: at})
+}
+
+func (hp *hookPhaser) Repeated(id int64, ident gateway.Ident, at time.Time) {
+	hp.bus.Publish(hp.event(hookbus.KindRepeated, id, ident, at))
+}
+
+func (hp *hookPhaser) Quarantined(id int64, ident gateway.Ident, reason string, at time.Time) {
+	evt := hp.event(hookbus.KindQuarantined, id, ident, at)
+	evt.Reason = reason
+	hp.bus.Publish(evt)
+}
+
+func (hp *hookPhaser) Collided(id int64, ident gateway.Ident, reason string, at time.Time) {
+	evt := hp.event(hookbus.KindCollided, id, ident, at)
+	evt.Reason = reason
+	hp.bus.Publish(evt)
+}
+
+func (hp *hookPhaser) Takeover(id int64, ident gateway.Ident, brokerID int64, reason string, at time.Time) {
+	evt := hp.event(hookbus.KindTakeover, id, ident, at)
+	evt.Reason = fmt.Sprintf("原会话（broker: %d）已失效：%s", brokerID, reason)
+	hp.bus.Publish(evt)
+}
+
+func (hp *hookPhaser) Connected(_ Linker, ident gateway.Ident, issue gateway.Issue, at time.Time) {
+	hp.bus.Publish(hp.event(hookbus.KindConnected, issue.ID, ident, at))
+}
+
+func (hp *hookPhaser) Disconnected(_ Linker, ident gateway.Ident, issue gateway.Issue, at time.Time, du time.Duration) {
+	evt := hp.event(hookbus.KindDisconnected, issue.ID, ident, at)
+	evt.Duration = du
+	hp.bus.Publish(evt)
+}
+
+func (hp *hookPhaser) event(kind hookbus.Kind, id int64, ident gateway.Ident, at time.Time) *hookbus.Event {
+	return &hookbus.Event{
+		Kind:      kind,
+		MinionID:  id,
+		Inet:      inetPair(ident).Primary(),
+		MachineID: ident.MachineID,
+		Semver:    ident.Semver,
+		BrokerID:  hp.bid,
+		OccurAt:   at,
+	}
+}
@@ -0,0 +1,209 @@
+package mlink
+
+import (
+	"crypto/rand"
+	"encoding/hex"
+	"sync"
+	"time"
+
+	"github.com/vela-ssoc/ssoc-broker/bridge/gateway"
+)
+
+// resumeGrace 节点断开后会话恢复凭证的有效期，在此期间重连的节点无需重新走完整的上线流程，
+// 下线告警也会延迟到有效期结束后才发出。
+const resumeGrace = 90 * time.Second
+
+// identKey 节点身份中影响上线流程的字段，任一字段变化都不能走会话恢复。
+type identKey struct {
+	MachineID  string
+	Inet       string // 主地址，IPv6-only 节点为 IPv6 地址
+	Inet6      string // 双栈节点的 IPv6 地址
+	MAC        string
+	Goos       string
+	Arch       string
+	Hostname   string
+	Semver     string
+	Unstable   bool
+	Customized string
+}
+
+func newIdentKey(ident gateway.Ident) identKey {
+	return identKey{
+		MachineID:  ident.MachineID,
+		Inet:       inetPair(ident).Primary(),
+		Inet6:      inetPair(ident).Inet6(),
+		MAC:        ident.MAC,
+		Goos:       ident.Goos,
+		Arch:       ident.Arch,
+		Hostname:   ident.Hostname,
+		Semver:     ident.Semver,
+		Unstable:   ident.Unstable,
+		Customized: ident.Customized,
+	}
+}
+
+type resumeTicket struct {
+	id        int64
+	key       identKey
+	expiredAt time.Time // 为零值说明会话仍在线
+}
+
+// pendingOffline 延迟执行的下线回调。
+type pendingOffline struct {
+	timer *time.Timer
+	fn    func()
+}
+
+func newResumption(grace time.Duration) *resumption {
+	return &resumption{
+		grace:   grace,
+		tickets: make(map[string]*resumeTicket, 1024),
+		pending: make(map[int64]*pendingOffline, 64),
+	}
+}
+
+// resumption 会话恢复凭证管理。
+//
+// 网络抖动时节点会频繁断开重连，每次重连都会重新初始化标签、下发配置并产生上下线告警，
+// 对数据库和告警通道造成很大压力。broker 在认证成功时随 gateway.Issue 下发一个一次性凭证，
+// 节点断开后在有效期内携带该凭证重连，且身份信息未发生变化，即视为会话恢复。
+type resumption struct {
+	grace   time.Duration
+	mutex   sync.Mutex
+	tickets map[string]*resumeTicket
+	pending map[int64]*pendingOffline
+	closed  bool // 排空或退出期间不再延迟下线回调
+}
+
+// issue 签发凭证，在节点上线（attach）之前同样按照有效期过期。
+func (rs *resumption) issue(id int64, ident gateway.Ident) string {
+	buf := make([]byte, 24)
+	_, _ = rand.Read(buf)
+	ticket := hex.EncodeToString(buf)
+
+	now := time.Now()
+	rs.mutex.Lock()
+	defer rs.mutex.Unlock()
+
+	// 顺便清理过期的凭证
+	for k, v := range rs.tickets {
+		if !v.expiredAt.IsZero() && now.After(v.expiredAt) {
+			delete(rs.tickets, k)
+		}
+	}
+	rs.tickets[ticket] = &resumeTicket{id: id, key: newIdentKey(ident), expiredAt: now.Add(rs.grace)}
+
+	return ticket
+}
+
+// resume 消费节点携带的凭证，返回是否可以恢复会话。
+//
+// 可以恢复时下线回调保持不变，等到 claim 时才取消，防止认证之后上线失败导致下线回调丢失；
+// 不能恢复时该节点尚未执行的下线回调立即执行，保证上下线事件的先后顺序。
+func (rs *resumption) resume(ticket string, id int64, ident gateway.Ident) bool {
+	now := time.Now()
+	rs.mutex.Lock()
+	ent := rs.tickets[ticket]
+	if ent != nil && ticket != "" {
+		delete(rs.tickets, ticket)
+	}
+	ok := ent != nil && ticket != "" && ent.id == id &&
+		!ent.expiredAt.IsZero() && now.Before(ent.expiredAt) &&
+		ent.key == newIdentKey(ident)
+
+	var flush func()
+	pend := rs.pending[id]
+	if pend == nil {
+		ok = false // 没有待执行的下线回调，说明原会话的下线流程已经完整执行
+	} else if !ok {
+		delete(rs.pending, id)
+		if pend.timer.Stop() {
+			flush = pend.fn
+		}
+	}
+	rs.mutex.Unlock()
+
+	if flush != nil {
+		flush()
+	}
+
+	return ok
+}
+
+// claim 恢复的会话上线成功，取消尚未执行的下线回调。
+//
+// 返回 false 说明下线回调已经执行（认证到上线之间超过了有效期），只能走完整的上线流程。
+func (rs *resumption) claim(id int64) bool {
+	rs.mutex.Lock()
+	defer rs.mutex.Unlock()
+
+	pend := rs.pending[id]
+	if pend == nil {
+		return false
+	}
+	delete(rs.pending, id)
+
+	return pend.timer.Stop()
+}
+
+// flush 立即执行所有延迟的下线回调，之后下线的节点也不再延迟，用于排空与退出。
+func (rs *resumption) flush() {
+	rs.mutex.Lock()
+	rs.closed = true
+	fns := make([]func(), 0, len(rs.pending))
+	for id, pend := range rs.pending {
+		delete(rs.pending, id)
+		if pend.timer.Stop() {
+			fns = append(fns, pend.fn)
+		}
+	}
+	rs.mutex.Unlock()
+
+	for _, fn := range fns {
+		fn()
+	}
+}
+
+// reopen 退出排空模式，恢复延迟下线回调。
+func (rs *resumption) reopen() {
+	rs.mutex.Lock()
+	rs.closed = false
+	rs.mutex.Unlock()
+}
+
+// attach 节点上线，凭证在会话存续期间一直有效。
+func (rs *resumption) attach(ticket string) {
+	rs.mutex.Lock()
+	defer rs.mutex.Unlock()
+
+	if ent := rs.tickets[ticket]; ent != nil {
+		ent.expiredAt = time.Time{}
+	}
+}
+
+// detach 节点下线，凭证开始计算有效期，下线回调延迟到有效期结束后执行。
+func (rs *resumption) detach(ticket string, id int64, fn func()) {
+	rs.mutex.Lock()
+	if rs.closed {
+		delete(rs.tickets, ticket)
+		rs.mutex.Unlock()
+		fn()
+		return
+	}
+	defer rs.mutex.Unlock()
+
+	if ent := rs.tickets[ticket]; ent != nil {
+		ent.expiredAt = time.Now().Add(rs.grace)
+	}
+
+	pend := &pendingOffline{fn: fn}
+	pend.timer = time.AfterFunc(rs.grace, func() {
+		rs.mutex.Lock()
+		if rs.pending[id] == pend {
+			delete(rs.pending, id)
+		}
+		rs.mutex.Unlock()
+		fn()
+	})
+	rs.pending[id] = pend
+}
@@ -0,0 +1,129 @@
+package mlink
+
+import (
+	"net"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/vela-ssoc/ssoc-broker/library/capability"
+	"github.com/vela-ssoc/ssoc-broker/library/drain"
+)
+
+// Session 节点连接会话的统计信息快照。
+type Session struct {
+	ID          int64                 `json:"id,string"`
+	Inet        string                `json:"inet"`
+	Inet6       string                `json:"inet6"`
+	MachineID   string                `json:"machine_id"`
+	Hostname    string                `json:"hostname"`
+	Semver      string                `json:"semver"`
+	Protocol    int                   `json:"protocol"`     // 协商的握手协议版本
+	Features    capability.Set        `json:"features"`     // 协商的特性
+	RemoteAddr  string                `json:"remote_addr"`  // 节点连接的源地址
+	Interval    drain.Duration        `json:"interval"`     // 协商的心跳间隔，序列化为 "30s" 这样的字符串
+	ConnectedAt time.Time             `json:"connected_at"` // 连接建立时间
+	ActiveAt    time.Time             `json:"active_at"`    // 最近一次收到数据的时间
+	BytesIn     uint64                `json:"bytes_in"`     // 接收的字节数
+	BytesOut    uint64                `json:"bytes_out"`    // 发送的字节数
+	Streams     int                   `json:"streams"`      // 当前打开的 smux 虚拟流个数
+	Requests    uint64                `json:"requests"`     // 节点发来的请求总数
+	Errors      uint64                `json:"errors"`       // 节点发来的请求出错总数
+	Routes      map[string]*RouteStat `json:"routes"`       // 按路由统计的请求数
+	Breaches    map[string]uint64     `json:"breaches"`     // 按类型统计的资源配额违规次数
+}
+
+// RouteStat 单个路由的请求统计。
+type RouteStat struct {
+	Requests uint64 `json:"requests"`
+	Errors   uint64 `json:"errors"`
+}
+
+func newSessionStat(remote net.Addr, at time.Time) *sessionStat {
+	st := &sessionStat{
+		connectedAt: at,
+		routes:      make(map[string]*RouteStat, 16),
+	}
+	if remote != nil {
+		st.remoteAddr = remote.String()
+	}
+	st.activeAt.Store(at.UnixNano())
+
+	return st
+}
+
+// sessionStat 节点连接会话的统计计数器。
+type sessionStat struct {
+	connectedAt time.Time
+	remoteAddr  string
+	activeAt    atomic.Int64 // UnixNano
+	bytesIn     atomic.Uint64
+	bytesOut    atomic.Uint64
+	mutex       sync.Mutex
+	routes      map[string]*RouteStat
+}
+
+func (st *sessionStat) received(n int) {
+	if n > 0 {
+		st.bytesIn.Add(uint64(n))
+		st.activeAt.Store(time.Now().UnixNano())
+	}
+}
+
+func (st *sessionStat) sent(n int) {
+	if n > 0 {
+		st.bytesOut.Add(uint64(n))
+	}
+}
+
+func (st *sessionStat) record(route string, failed bool) {
+	st.mutex.Lock()
+	defer st.mutex.Unlock()
+
+	rs := st.routes[route]
+	if rs == nil {
+		rs = new(RouteStat)
+		st.routes[route] = rs
+	}
+	rs.Requests++
+	if failed {
+		rs.Errors++
+	}
+}
+
+// fill 将计数器填充到快照。
+func (st *sessionStat) fill(sess *Session) {
+	sess.RemoteAddr = st.remoteAddr
+	sess.ConnectedAt = st.connectedAt
+	sess.ActiveAt = time.Unix(0, st.activeAt.Load())
+	sess.BytesIn = st.bytesIn.Load()
+	sess.BytesOut = st.bytesOut.Load()
+
+	st.mutex.Lock()
+	defer st.mutex.Unlock()
+
+	sess.Routes = make(map[string]*RouteStat, len(st.routes))
+	for name, rs := range st.routes {
+		sess.Requests += rs.Requests
+		sess.Errors += rs.Errors
+		sess.Routes[name] = &RouteStat{Requests: rs.Requests, Errors: rs.Errors}
+	}
+}
+
+// statConn 统计收发字节数的 net.Conn
+type statConn struct {
+	net.Conn
+	stat *sessionStat
+}
+
+func (sc *statConn) Read(p []byte) (int, error) {
+	n, err := sc.Conn.Read(p)
+	sc.stat.received(n)
+	return n, err
+}
+
+func (sc *statConn) Write(p []byte) (int, error) {
+	n, err := sc.Conn.Write(p)
+	sc.stat.sent(n)
+	return n, err
+}
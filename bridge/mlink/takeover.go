@@ -0,0 +1,95 @@
+package mlink
+
+import (
+	"context"
+	"errors"
+	"log/slog"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/vela-ssoc/ssoc-broker/bridge/gateway"
+	"github.com/vela-ssoc/ssoc-broker/library/inetx"
+	"github.com/vela-ssoc/ssoc-broker/library/takeover"
+	"github.com/vela-ssoc/ssoc-common-mb/dal/model"
+	"github.com/vela-ssoc/vela-common-mba/netutil"
+)
+
+// managerLiveness 通过中心端询问节点在其它 broker 上是否存活。
+type managerLiveness struct {
+	cli netutil.HTTPClient
+}
+
+func (ml managerLiveness) AgentLiveness(ctx context.Context, brokerID, agentID int64) (bool, error) {
+	const addr = "http://vtun" + takeover.LivenessPath
+	req := &takeover.LivenessRequest{BrokerID: brokerID, AgentID: agentID}
+	res := new(takeover.LivenessResponse)
+	if err := ml.cli.JSON(ctx, http.MethodPost, addr, req, res, nil); err != nil {
+		return false, err
+	}
+
+	return res.Alive, nil
+}
+
+// takeover 数据库中节点状态为在线时，检查原会话是否已经失效。
+// 如果原会话已失效则将其驱逐并修改数据库状态为离线，由新会话接管。
+func (hub *minionHub) takeover(ctx context.Context, mon *model.Minion, ident gateway.Ident) (bool, error) {
+	var alive bool
+	var reason string
+	if mon.BrokerID == hub.bid || mon.BrokerID == 0 {
+		alive, reason = hub.probeLocal(ctx, mon.ID)
+	} else {
+		var err error
+		chk := managerLiveness{cli: hub.mgt}
+		if alive, reason, err = takeover.Remote(ctx, hub.qry, chk, mon, hub.log); err != nil {
+			return false, err
+		}
+	}
+	if alive {
+		return false, nil
+	}
+	if ok, err := takeover.Evict(ctx, hub.qry, mon); err != nil || !ok {
+		return false, err
+	}
+
+	attrs := []any{
+		slog.Int64("minion_id", mon.ID), slog.String("inet", inetx.Display(mon.Inet, mon.Inet6)),
+		slog.Int64("broker_id", mon.BrokerID), slog.String("reason", reason),
+	}
+	hub.log.Warn("原会话已失效，由新会话接管", attrs...)
+	hub.phase.Takeover(mon.ID, ident, mon.BrokerID, reason, time.Now())
+
+	return true, nil
+}
+
+// probeLocal 探测本 broker 上的会话是否存活，如果已失效则断开并等待其清理完毕。
+func (hub *minionHub) probeLocal(parent context.Context, id int64) (bool, string) {
+	conn := hub.section.Get(strconv.FormatInt(id, 10))
+	if conn == nil {
+		return false, "本地不存在该节点会话"
+	}
+
+	ctx, cancel := context.WithTimeout(parent, takeover.ProbeTimeout)
+	defer cancel()
+
+	// 只要节点有 HTTP 响应（即便是 404）就说明通道是通的。
+	addr := hub.httpURL(id, "/api/v1/agent/ping")
+	res, err := hub.client.DoJSON(ctx, http.MethodGet, addr, nil, nil)
+	if err == nil {
+		_ = res.Body.Close()
+		return true, ""
+	}
+	var he *netutil.HTTPError
+	if errors.As(err, &he) {
+		return true, ""
+	}
+
+	// 旧会话已死，断开并等待其下线流程执行完毕，防止旧会话的清理动作覆盖新会话。
+	hub.Knockout(id)
+	select {
+	case <-conn.done:
+	case <-parent.Done():
+	}
+
+	return false, "本地会话探测失败：" + err.Error()
+}
@@ -14,8 +14,11 @@ import (
 	"os"
 	"os/user"
 	"runtime"
+	"sync"
 	"time"
 
+	"github.com/vela-ssoc/ssoc-broker/library/heartbeat"
+	"github.com/vela-ssoc/ssoc-broker/library/tlstrust"
 	"github.com/vela-ssoc/ssoc-common-mb/param/negotiate"
 	"github.com/vela-ssoc/ssoc-common-mb/problem"
 	"github.com/vela-ssoc/vela-common-mba/netutil"
@@ -29,6 +32,8 @@ type brokerClient struct {
 	client netutil.HTTPClient
 	log    *slog.Logger
 	dialer *iterDial
+	beat   heartbeat.Monitor
+	mutex  sync.RWMutex // 保护 mux，心跳协程会并发读取
 	mux    *smux.Session
 	joinAt time.Time
 	parent context.Context
@@ -42,7 +47,13 @@ func (bc *brokerClient) DialContext(ctx context.Context, network, addr string) (
 func (bc *brokerClient) Hide() negotiate.Hide   { return bc.hide }
 func (bc *brokerClient) Ident() negotiate.Ident { return bc.ident }
 func (bc *brokerClient) Issue() negotiate.Issue { return bc.issue }
-func (bc *brokerClient) Listen() net.Listener   { return bc.mux }
+func (bc *brokerClient) Listen() net.Listener   { return bc.session() }
+
+func (bc *brokerClient) Health() []*AddrHealth { return bc.dialer.health() }
+
+func (bc *brokerClient) Heartbeat() heartbeat.Stats { return bc.beat.Stats() }
+
+func (bc *brokerClient) Trust() tlstrust.Trust { return bc.dialer.trust }
 
 func (bc *brokerClient) JoinAt() time.Time {
 	return bc.joinAt
@@ -59,7 +70,14 @@ func (bc *brokerClient) Reconnect(parent context.Context) error {
 
 func (bc *brokerClient) close() error {
 	bc.cancel()
-	return bc.mux.Close()
+	return bc.session().Close()
+}
+
+func (bc *brokerClient) session() *smux.Session {
+	bc.mutex.RLock()
+	defer bc.mutex.RUnlock()
+
+	return bc.mux
 }
 
 func (bc *brokerClient) dial(parent context.Context) error {
@@ -80,12 +98,15 @@ func (bc *brokerClient) dial(parent context.Context) error {
 
 		bc.log.Info("连接成功，准备握手协商...", slog.Any("addr", addr))
 		ident, issue, err := bc.consult(bc.ctx, conn, addr)
+		bc.dialer.report(addr, err)
 		if err == nil {
 			cfg := smux.DefaultConfig()
 			cfg.KeepAliveDisabled = true
 			mux := smux.Client(conn, cfg)
 			// mux := spdy.Client(conn, spdy.WithEncrypt(issue.Passwd))
+			bc.mutex.Lock()
 			bc.ident, bc.issue, bc.mux, bc.joinAt = ident, issue, mux, time.Now()
+			bc.mutex.Unlock()
 			return nil
 		}
 
@@ -110,6 +131,9 @@ func (bc *brokerClient) dial(parent context.Context) error {
 // consult 当建立好 TCP 连接后进行应用层协商
 func (bc *brokerClient) consult(parent context.Context, conn net.Conn, addr *netutil.Address) (negotiate.Ident, negotiate.Issue, error) {
 	ip := conn.LocalAddr().(*net.TCPAddr).IP
+	if ip4 := ip.To4(); ip4 != nil { // 双栈 socket 上的 IPv4-mapped 地址
+		ip = ip4
+	}
 	mac := bc.dialer.lookupMAC(ip)
 
 	ident := negotiate.Ident{
@@ -205,7 +229,7 @@ func (bc *brokerClient) dialSleep(ctx context.Context, start time.Time) {
 }
 
 func (bc *brokerClient) dialContext(_ context.Context, _, _ string) (net.Conn, error) {
-	mux := bc.mux
+	mux := bc.session()
 	if mux == nil {
 		return nil, io.ErrNoProgress
 	}
@@ -217,16 +241,14 @@ func (bc *brokerClient) dialContext(_ context.Context, _, _ string) (net.Conn, e
 	}
 }
 
-func (bc *brokerClient) heartbeat(du time.Duration) {
-	ticker := time.NewTicker(du)
-	defer ticker.Stop()
+// openStream 开启一个到中心端的虚拟子流，用于心跳。
+func (bc *brokerClient) openStream(ctx context.Context) (net.Conn, error) {
+	return bc.dialContext(ctx, "tcp", "vtun:80")
+}
 
-	var over bool
-	for !over {
-		select {
-		case <-ticker.C:
-		case <-bc.parent.Done():
-			over = true
-		}
+// forceReconnect 心跳丢失时关闭底层连接，由 daemonClient 感知断开后重连。
+func (bc *brokerClient) forceReconnect(string) {
+	if mux := bc.session(); mux != nil {
+		_ = mux.Close()
 	}
 }
@@ -3,52 +3,212 @@ package telecom
 import (
 	"context"
 	"crypto/tls"
+	"errors"
 	"net"
+	"sync"
 	"time"
 
+	"github.com/vela-ssoc/ssoc-broker/library/tlstrust"
 	"github.com/vela-ssoc/vela-common-mba/netutil"
 )
 
-func newIterDial(addrs netutil.Addresses) *iterDial {
+// ErrPlaintextAddress 未开启 TLS 的地址无法校验中心端身份。
+var ErrPlaintextAddress = errors.New("未开启 TLS 的中心端地址仅允许在关闭证书校验（insecure）时使用")
+
+const (
+	// blockBase 地址连续失败时的基础拉黑时长，每多失败一次翻倍。
+	blockBase = 5 * time.Second
+
+	// blockMax 地址最长拉黑时长。
+	blockMax = 5 * time.Minute
+
+	// rttWeight 握手耗时滑动平均的权重。
+	rttWeight = 0.3
+)
+
+// AddrHealth 中心端地址的健康状态。
+type AddrHealth struct {
+	Addr         string        `json:"addr"`
+	Name         string        `json:"name"`
+	TLS          bool          `json:"tls"`
+	Current      bool          `json:"current"`       // 是否为当前（最近一次成功）使用的地址
+	Failures     int           `json:"failures"`      // 连续失败次数
+	RTT          time.Duration `json:"rtt"`           // 建立连接（含 TLS 握手）耗时的滑动平均值
+	LastSuccess  time.Time     `json:"last_success"`  // 最近一次成功时间
+	LastFailure  time.Time     `json:"last_failure"`  // 最近一次失败时间
+	LastError    string        `json:"last_error"`    // 最近一次失败原因
+	BlockedUntil time.Time     `json:"blocked_until"` // 拉黑截止时间
+}
+
+func newIterDial(addrs netutil.Addresses, trust tlstrust.Trust) *iterDial {
 	dialer := &tls.Dialer{NetDialer: new(net.Dialer)}
 	macs := make(map[string]net.HardwareAddr, 4)
 	length := len(addrs)
+	healths := make([]*AddrHealth, 0, length)
+	for _, addr := range addrs {
+		healths = append(healths, &AddrHealth{Addr: addr.Addr, Name: addr.Name, TLS: addr.TLS})
+	}
 
 	return &iterDial{
-		dial:   dialer,
-		macs:   macs,
-		addrs:  addrs,
-		length: length,
+		dial:    dialer,
+		trust:   trust,
+		macs:    macs,
+		addrs:   addrs,
+		length:  length,
+		healths: healths,
+		current: -1,
 	}
 }
 
+// iterDial 中心端地址拨号器：优先使用当前正在工作的地址，其次选择健康且延迟低的地址，
+// 连续失败的地址会被短暂拉黑。
 type iterDial struct {
-	dial   *tls.Dialer
-	macs   map[string]net.HardwareAddr
-	addrs  netutil.Addresses
-	length int
-	index  int
+	dial    *tls.Dialer
+	trust   tlstrust.Trust
+	macs    map[string]net.HardwareAddr
+	addrs   netutil.Addresses
+	length  int
+	mutex   sync.Mutex
+	healths []*AddrHealth
+	current int // 当前使用的地址下标，-1 代表还没有成功过
 }
 
 func (dl *iterDial) iterDial(parent context.Context, timeout time.Duration) (net.Conn, *netutil.Address, error) {
-	idx := dl.index
+	idx := dl.pick(time.Now())
 	addr := dl.addrs[idx]
-	dl.index = (idx + 1) % dl.length
 
 	ctx, cancel := context.WithTimeout(parent, timeout)
 	defer cancel()
 
-	if addr.TLS {
-		dial := dl.dial
-		dial.Config = &tls.Config{ServerName: addr.Name}
-		conn, err := dial.DialContext(ctx, "tcp", addr.Addr)
-		return conn, addr, err
+	var conn net.Conn
+	var err error
+	start := time.Now()
+	if !addr.TLS && !dl.trust.Insecure() {
+		err = ErrPlaintextAddress
+	} else if addr.TLS {
+		dial := *dl.dial
+		dial.Config = dl.trust.TLSConfig(addr.Name)
+		conn, err = dial.DialContext(ctx, "tcp", addr.Addr)
+	} else {
+		conn, err = dl.dial.NetDialer.DialContext(ctx, "tcp", addr.Addr)
+	}
+	if err != nil {
+		dl.report(addr, err)
+	} else {
+		dl.observeRTT(idx, time.Since(start))
+	}
+
+	return conn, addr, err
+}
+
+// pick 选择本次拨号的地址。
+func (dl *iterDial) pick(now time.Time) int {
+	dl.mutex.Lock()
+	defer dl.mutex.Unlock()
+
+	if cur := dl.current; cur >= 0 && now.After(dl.healths[cur].BlockedUntil) {
+		return cur
+	}
+
+	best := -1
+	for i, h := range dl.healths {
+		if now.Before(h.BlockedUntil) {
+			continue
+		}
+		if best < 0 || dl.better(h, dl.healths[best]) {
+			best = i
+		}
+	}
+	if best >= 0 {
+		return best
+	}
+
+	// 全部被拉黑，选择最先解除拉黑的
+	best = 0
+	for i, h := range dl.healths {
+		if h.BlockedUntil.Before(dl.healths[best].BlockedUntil) {
+			best = i
+		}
+	}
+
+	return best
+}
+
+// better a 是否比 b 更优：连续失败次数少的优先，其次是有过成功记录且延迟低的。
+func (*iterDial) better(a, b *AddrHealth) bool {
+	if a.Failures != b.Failures {
+		return a.Failures < b.Failures
+	}
+	if (a.RTT > 0) != (b.RTT > 0) {
+		return a.RTT > 0
+	}
+
+	return a.RTT < b.RTT
+}
+
+func (dl *iterDial) observeRTT(idx int, rtt time.Duration) {
+	dl.mutex.Lock()
+	defer dl.mutex.Unlock()
+
+	h := dl.healths[idx]
+	if h.RTT <= 0 {
+		h.RTT = rtt
 	} else {
-		conn, err := dl.dial.NetDialer.DialContext(ctx, "tcp", addr.Addr)
-		return conn, addr, err
+		h.RTT = time.Duration(rttWeight*float64(rtt) + (1-rttWeight)*float64(h.RTT))
 	}
 }
 
+// report 反馈地址的连接与握手结果，err 为空代表成功。
+func (dl *iterDial) report(addr *netutil.Address, err error) {
+	dl.mutex.Lock()
+	defer dl.mutex.Unlock()
+
+	idx := -1
+	for i, a := range dl.addrs {
+		if a == addr {
+			idx = i
+			break
+		}
+	}
+	if idx < 0 {
+		return
+	}
+
+	now := time.Now()
+	h := dl.healths[idx]
+	if err == nil {
+		h.Failures = 0
+		h.LastSuccess = now
+		h.BlockedUntil = time.Time{}
+		dl.current = idx
+		return
+	}
+
+	h.Failures++
+	h.LastFailure = now
+	h.LastError = err.Error()
+	block := blockBase << min(h.Failures-1, 10)
+	h.BlockedUntil = now.Add(min(block, blockMax))
+	if dl.current == idx {
+		dl.current = -1
+	}
+}
+
+// health 所有地址的健康状态快照。
+func (dl *iterDial) health() []*AddrHealth {
+	dl.mutex.Lock()
+	defer dl.mutex.Unlock()
+
+	ret := make([]*AddrHealth, 0, len(dl.healths))
+	for i, h := range dl.healths {
+		hh := *h
+		hh.Current = i == dl.current
+		ret = append(ret, &hh)
+	}
+
+	return ret
+}
+
 func (dl *iterDial) lookupMAC(ip net.IP) net.HardwareAddr {
 	sip := ip.String()
 	if hw, ok := dl.macs[sip]; ok {
@@ -8,6 +8,9 @@ import (
 	"net/http"
 	"time"
 
+	"github.com/vela-ssoc/ssoc-broker/library/heartbeat"
+	"github.com/vela-ssoc/ssoc-broker/library/inetx"
+	"github.com/vela-ssoc/ssoc-broker/library/tlstrust"
 	"github.com/vela-ssoc/ssoc-common-mb/param/negotiate"
 	"github.com/vela-ssoc/vela-common-mba/netutil"
 )
@@ -20,6 +23,12 @@ type Linker interface {
 	Issue() negotiate.Issue
 	Name() string
 	JoinAt() time.Time
+	// Health 中心端各个地址的健康状态。
+	Health() []*AddrHealth
+	// Trust 中心端证书校验器。
+	Trust() tlstrust.Trust
+	// Heartbeat 与中心端的应用层心跳统计。
+	Heartbeat() heartbeat.Stats
 	Listen() net.Listener
 	Reconnect(context.Context) error
 	DialContext(ctx context.Context, network, addr string) (net.Conn, error)
@@ -29,13 +38,13 @@ type Linker interface {
 	// OnewayJSON(context.Context, opcode.URLer, any) error
 }
 
-func Dial(parent context.Context, hide *negotiate.Hide, log *slog.Logger) (Linker, error) {
-	addrs := hide.Servers.Preformat()
+func Dial(parent context.Context, hide *negotiate.Hide, trust tlstrust.Trust, log *slog.Logger) (Linker, error) {
+	addrs := unbracket(hide.Servers).Preformat()
 	if len(addrs) == 0 {
 		return nil, ErrEmptyAddress
 	}
 
-	dialer := newIterDial(addrs)
+	dialer := newIterDial(addrs, trust)
 	bc := &brokerClient{
 		hide:   *hide,
 		log:    log,
@@ -48,7 +57,24 @@ func Dial(parent context.Context, hide *negotiate.Hide, log *slog.Logger) (Linke
 		return nil, err
 	}
 
-	// go bc.heartbeat(time.Minute)
+	bc.beat = heartbeat.New(bc.openStream, heartbeat.Option{Host: "vtun"}, bc.forceReconnect, log)
+	go bc.beat.Run(parent)
 
 	return bc, nil
 }
+
+// unbracket 去掉未携带端口号的 IPv6 地址两侧的方括号（如：[::1]），
+// 否则 Preformat 补全端口号时会得到 [[::1]]:443 这样的错误地址。
+func unbracket(ads netutil.Addresses) netutil.Addresses {
+	ret := make(netutil.Addresses, 0, len(ads))
+	for _, ad := range ads {
+		if ad == nil {
+			continue
+		}
+		cp := *ad
+		cp.Addr = inetx.Unbracket(ad.Addr)
+		ret = append(ret, &cp)
+	}
+
+	return ret
+}
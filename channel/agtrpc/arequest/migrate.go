@@ -0,0 +1,13 @@
+package arequest
+
+type Migrate struct {
+	Brokers []*MigrateBroker `json:"brokers,omitzero"`
+}
+
+type MigrateBroker struct {
+	ID         int64    `json:"id,string"`
+	Name       string   `json:"name,omitzero"`
+	Servername string   `json:"servername,omitzero"`
+	LAN        []string `json:"lan,omitzero"`
+	VIP        []string `json:"vip,omitzero"`
+}
@@ -5,6 +5,7 @@ import (
 	"net/http"
 
 	"github.com/vela-ssoc/ssoc-broker/channel/agtrpc/arequest"
+	"github.com/vela-ssoc/ssoc-common-mb/dal/model"
 	"github.com/vela-ssoc/ssoc-common/httpkit"
 	"github.com/vela-ssoc/ssoc-common/linkhub"
 )
@@ -21,3 +22,23 @@ func (ac *agentOperate) Command(ctx context.Context, cmd string) error {
 
 	return ac.cli.SendJSON(ctx, http.MethodPost, reqURL.String(), nil, req, nil)
 }
+
+func (ac *agentOperate) Migrate(ctx context.Context, req *arequest.Migrate) error {
+	const path = "/api/v1/agent/notice/migrate"
+	reqURL := linkhub.NewBrokerToAgentIDURL(ac.agentID, path)
+
+	return ac.cli.SendJSON(ctx, http.MethodPost, reqURL.String(), nil, req, nil)
+}
+
+func (ac *agentOperate) Startup(ctx context.Context, req *model.StartupFallback) error {
+	const path = "/api/v1/agent/startup"
+	reqURL := linkhub.NewBrokerToAgentIDURL(ac.agentID, path)
+
+	return ac.cli.SendJSON(ctx, http.MethodPost, reqURL.String(), nil, req, nil)
+}
+
+func (ac *agentOperate) Call(ctx context.Context, path string, req, resp any) error {
+	reqURL := linkhub.NewBrokerToAgentIDURL(ac.agentID, path)
+
+	return ac.cli.SendJSON(ctx, http.MethodPost, reqURL.String(), nil, req, resp)
+}
@@ -1,8 +1,22 @@
 package agtrpc
 
-import "context"
+import (
+	"context"
+
+	"github.com/vela-ssoc/ssoc-broker/channel/agtrpc/arequest"
+	"github.com/vela-ssoc/ssoc-common-mb/dal/model"
+)
 
 type Operator interface {
 	// Command 向 agent 发送简单指令。
 	Command(ctx context.Context, cmd string) error
+
+	// Migrate 通知 agent 迁移到其它 broker。
+	Migrate(ctx context.Context, req *arequest.Migrate) error
+
+	// Startup 向 agent 推送 startup 配置。
+	Startup(ctx context.Context, req *model.StartupFallback) error
+
+	// Call 以 JSON 方式调用 agent 的任意接口，用于复用旧通道的业务逻辑（如：配置同步）。
+	Call(ctx context.Context, path string, req, resp any) error
 }
@@ -3,14 +3,23 @@ package clientd
 import (
 	"errors"
 	"fmt"
-	"net"
 	"net/http"
+	"strings"
+
+	"github.com/vela-ssoc/ssoc-broker/library/inetx"
+	"github.com/vela-ssoc/ssoc-broker/library/tlstrust"
 )
 
+// ErrPlaintextAddress 明文（ws）地址无法校验中心端身份。
+var ErrPlaintextAddress = errors.New("明文（ws）中心端地址仅允许在关闭证书校验（insecure）时使用")
+
 type Config struct {
-	Secret    string
-	Semver    string
+	Secret string
+	Semver string
+	// Addresses 中心端地址，可以带上 ws:// 或 wss:// 前缀，不带前缀时默认 wss，
+	// 关闭证书校验（insecure）时默认 ws 以兼容明文部署。
 	Addresses []string
+	TLS       tlstrust.Config // 中心端证书校验配置
 }
 
 func (c *Config) preparse() error {
@@ -24,9 +33,30 @@ func (c *Config) preparse() error {
 	uniq := make(map[string]struct{}, 16)
 	addrs := make([]string, 0, len(c.Addresses))
 	for _, addr := range c.Addresses {
-		if _, _, err := net.SplitHostPort(addr); err != nil {
-			addr = net.JoinHostPort(addr, "443")
+		scheme, host, found := strings.Cut(addr, "://")
+		if !found {
+			scheme, host = "wss", addr
+			if c.TLS.Insecure {
+				scheme = "ws"
+			}
+		}
+		switch scheme {
+		case "wss":
+			host = inetx.JoinDefaultPort(host, "443")
+		case "ws":
+			if !c.TLS.Insecure {
+				return fmt.Errorf("%w：%s", ErrPlaintextAddress, addr)
+			}
+			// 不带前缀的地址沿用以前的默认端口
+			port := "80"
+			if !found {
+				port = "443"
+			}
+			host = inetx.JoinDefaultPort(host, port)
+		default:
+			return fmt.Errorf("不支持的连接地址协议：%s", addr)
 		}
+		addr = scheme + "://" + host
 		if _, exists := uniq[addr]; !exists {
 			uniq[addr] = struct{}{}
 			addrs = append(addrs, addr)
@@ -3,9 +3,11 @@ package clientd
 import (
 	"context"
 	"net"
+	"slices"
 	"sync"
 	"time"
 
+	"github.com/vela-ssoc/ssoc-broker/library/heartbeat"
 	"github.com/xtaci/smux"
 )
 
@@ -15,20 +17,53 @@ type BootConfig struct {
 	MaxIdleConn int           `json:"max_idle_conn"`
 	MaxLifeTime time.Duration `json:"max_life_time"`
 	MaxIdleTime time.Duration `json:"max_idle_time"`
+	TLSPins     []string      `json:"tls_pins"` // 轮换中心端证书公钥指纹，为空代表不轮换
 }
 
+// Equal 判断两份启动配置是否一致。
+func (b BootConfig) Equal(o BootConfig) bool {
+	return b.DSN == o.DSN &&
+		b.MaxOpenConn == o.MaxOpenConn &&
+		b.MaxIdleConn == o.MaxIdleConn &&
+		b.MaxLifeTime == o.MaxLifeTime &&
+		b.MaxIdleTime == o.MaxIdleTime &&
+		slices.Equal(b.TLSPins, o.TLSPins)
+}
+
+// BootWatcher 启动配置变更回调，old 为变更前的配置，cur 为变更后的配置。
+type BootWatcher func(old, cur BootConfig)
+
 type Muxer interface {
 	// OpenConn 开启一个虚拟子流。
 	OpenConn(ctx context.Context) (net.Conn, error)
 
 	// BootConfig 连接中心端成功后，中心端给 broker 下发启动配置。
 	BootConfig() BootConfig
+
+	// Heartbeat 与中心端的应用层心跳统计。
+	Heartbeat() heartbeat.Stats
+
+	// Watch 订阅启动配置变更，重连或中心端主动推送的配置与当前配置不一致时回调。
+	Watch(fn BootWatcher)
+
+	// Reload 中心端主动推送新的启动配置。
+	Reload(cfg BootConfig)
+
+	// State 与中心端通道的连接状态。
+	State() State
+
+	// WatchState 订阅连接状态变更，如：掉线时暂停依赖中心端的业务，恢复后继续。
+	WatchState(fn StateWatcher)
 }
 
 type safeMuxer struct {
-	mtx sync.RWMutex
-	ses *smux.Session
-	cfg BootConfig
+	mtx      sync.RWMutex
+	ses      *smux.Session
+	cfg      BootConfig
+	beat     heartbeat.Monitor
+	watchers []BootWatcher
+	state    State
+	stateFns []StateWatcher
 }
 
 func (sm *safeMuxer) OpenConn(context.Context) (net.Conn, error) {
@@ -49,6 +84,17 @@ func (sm *safeMuxer) BootConfig() BootConfig {
 	return cfg
 }
 
+func (sm *safeMuxer) Heartbeat() heartbeat.Stats {
+	return sm.beat.Stats()
+}
+
+// forceReconnect 心跳丢失时关闭底层连接，由 serve 感知断开后重连。
+func (sm *safeMuxer) forceReconnect(string) {
+	if ses := sm.session(); ses != nil {
+		_ = ses.Close()
+	}
+}
+
 func (sm *safeMuxer) session() *smux.Session {
 	sm.mtx.RLock()
 	ses := sm.ses
@@ -57,11 +103,78 @@ func (sm *safeMuxer) session() *smux.Session {
 	return ses
 }
 
+func (sm *safeMuxer) Watch(fn BootWatcher) {
+	sm.mtx.Lock()
+	sm.watchers = append(sm.watchers, fn)
+	sm.mtx.Unlock()
+}
+
+func (sm *safeMuxer) Reload(cfg BootConfig) {
+	sm.mtx.Lock()
+	old := sm.cfg
+	sm.cfg = cfg
+	watchers := sm.watchers
+	sm.mtx.Unlock()
+
+	sm.notify(watchers, old, cfg)
+}
+
 func (sm *safeMuxer) replace(ses *smux.Session, cfg BootConfig) {
 	sm.mtx.Lock()
+	old := sm.cfg
 	sm.ses = ses
 	sm.cfg = cfg
+	watchers := sm.watchers
 	sm.mtx.Unlock()
+
+	sm.notify(watchers, old, cfg)
+}
+
+func (*safeMuxer) notify(watchers []BootWatcher, old, cur BootConfig) {
+	if old.Equal(cur) {
+		return
+	}
+	for _, fn := range watchers {
+		fn(old, cur)
+	}
+}
+
+func (sm *safeMuxer) State() State {
+	sm.mtx.RLock()
+	st := sm.state
+	sm.mtx.RUnlock()
+
+	return st
+}
+
+func (sm *safeMuxer) WatchState(fn StateWatcher) {
+	sm.mtx.Lock()
+	sm.stateFns = append(sm.stateFns, fn)
+	sm.mtx.Unlock()
+}
+
+// setState 修改连接状态，阶段变化时刷新 Since 并通知订阅者。
+func (sm *safeMuxer) setState(fn func(*State)) {
+	sm.mtx.Lock()
+	old := sm.state
+	cur := old
+	fn(&cur)
+	if cur.Phase != old.Phase {
+		cur.Since = time.Now()
+	}
+	if cur.Phase != PhaseBackoff {
+		cur.RetryAt = time.Time{}
+	}
+	sm.state = cur
+	watchers := sm.stateFns
+	sm.mtx.Unlock()
+
+	if cur.Phase == old.Phase && cur.Addr == old.Addr {
+		return
+	}
+	for _, w := range watchers {
+		w(old, cur)
+	}
 }
 
 type muxerListener struct {
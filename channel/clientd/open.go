@@ -8,6 +8,8 @@ import (
 	"net/url"
 	"time"
 
+	"github.com/vela-ssoc/ssoc-broker/library/heartbeat"
+	"github.com/vela-ssoc/ssoc-broker/library/tlstrust"
 	"github.com/vela-ssoc/ssoc-common/linkhub"
 	"github.com/xtaci/smux"
 )
@@ -16,53 +18,74 @@ func Open(ctx context.Context, cfg Config, opt Options) (Muxer, error) {
 	if err := cfg.preparse(); err != nil {
 		return nil, err
 	}
+	trust, err := tlstrust.New(cfg.TLS)
+	if err != nil {
+		return nil, err
+	}
+	if trust.Insecure() {
+		opt.logger().Warn("已跳过中心端证书校验，仅限实验环境使用")
+	}
 
 	mux := new(safeMuxer)
 	bc := &brokerClient{
-		cfg: cfg,
-		opt: opt,
-		mux: mux,
-		ctx: ctx,
+		cfg:   cfg,
+		opt:   opt,
+		mux:   mux,
+		ctx:   ctx,
+		trust: trust,
 	}
+	mux.Watch(bc.rotatePins)
 	if err := bc.open(); err != nil {
 		return nil, err
 	}
+	beatOpt := heartbeat.Option{Host: linkhub.ServerHost}
+	mux.beat = heartbeat.New(mux.OpenConn, beatOpt, mux.forceReconnect, opt.logger())
+	go mux.beat.Run(ctx)
 	go bc.serve()
 
 	return mux, nil
 }
 
 type brokerClient struct {
-	cfg Config
-	opt Options
-	mux *safeMuxer
-	ctx context.Context
+	cfg   Config
+	opt   Options
+	mux   *safeMuxer
+	ctx   context.Context
+	trust tlstrust.Trust
 }
 
 // open 持续尝试连接 manager 直至成功或遇到不可重试错误。
 func (bc *brokerClient) open() error {
 	addrs := bc.cfg.Addresses
 	timeout := bc.opt.timeout()
+	backoff := bc.opt.backoff()
 	attrs := []any{slog.Any("addresses", addrs), slog.Duration("timeout", timeout)}
 	bc.opt.logger().Info("开始连接认证", attrs...)
 
 	beginAt := time.Now()
-	var fails int
+	fails := bc.mux.State().Fails
 	for {
-		sess, resp, err := bc.connects(addrs, timeout)
+		sess, resp, addr, err := bc.connects(addrs, timeout)
 		if err == nil {
 			bc.mux.replace(sess, resp.BootConfig)
-			bc.opt.logger().Info("通道连接认证成功", attrs...)
+			bc.mux.setState(func(st *State) {
+				st.Phase, st.Addr, st.Fails = PhaseConnected, addr, 0
+			})
+			bc.opt.logger().Info("通道连接认证成功", append(attrs, slog.String("addr", addr))...)
 			return nil
 		}
 
 		fails++
-		du := bc.waitN(fails, beginAt)
+		du := backoff.next(fails)
+		bc.mux.setState(func(st *State) {
+			st.Phase, st.Fails, st.LastError = PhaseBackoff, fails, err.Error()
+			st.RetryAt = time.Now().Add(du)
+		})
 		msgs := append(attrs, slog.Time("begin_at", beginAt))
 		msgs = append(msgs, slog.Int("fails", fails))
 		msgs = append(msgs, slog.Duration("sleep", du))
 		msgs = append(msgs, slog.Any("error", err))
-		bc.opt.logger().Warn("通道连接或认证失败，稍后重试", attrs...)
+		bc.opt.logger().Warn("通道连接或认证失败，稍后重试", msgs...)
 		if err = bc.sleep(du); err != nil {
 			attrs = append(attrs, slog.Any("sleep_error", err))
 			bc.opt.logger().Error("context 取消，退出重连机制", attrs...)
@@ -71,20 +94,31 @@ func (bc *brokerClient) open() error {
 	}
 }
 
-func (bc *brokerClient) connects(addrs []string, timeout time.Duration) (*smux.Session, *authResponse, error) {
+// rotatePins 轮换中心端下发的证书公钥指纹。
+func (bc *brokerClient) rotatePins(_, cur BootConfig) {
+	if len(cur.TLSPins) == 0 { // 未下发指纹说明不轮换，不能清除本地配置的指纹
+		return
+	}
+	if err := bc.trust.SetPins(cur.TLSPins); err != nil {
+		bc.opt.logger().Warn("中心端下发的证书指纹无效，忽略轮换", "error", err)
+	}
+}
+
+func (bc *brokerClient) connects(addrs []string, timeout time.Duration) (*smux.Session, *authResponse, string, error) {
 	var errs []error
 	for _, addr := range addrs {
 		sess, resp, err := bc.connect(addr, timeout)
 		if err == nil {
-			return sess, resp, nil
+			return sess, resp, addr, nil
 		}
 		errs = append(errs, err)
 	}
 
-	return nil, nil, errors.Join(errs...)
+	return nil, nil, "", errors.Join(errs...)
 }
 
 func (bc *brokerClient) connect(addr string, timeout time.Duration) (*smux.Session, *authResponse, error) {
+	bc.mux.setState(func(st *State) { st.Phase, st.Addr = PhaseConnecting, addr })
 	sess, err := bc.openSMUX(addr, timeout)
 	if err != nil {
 		bc.opt.logger().Warn("基础 TCP 网络连接错误", "addr", addr, "error", err)
@@ -92,6 +126,7 @@ func (bc *brokerClient) connect(addr string, timeout time.Duration) (*smux.Sessi
 	}
 
 	bc.opt.logger().Info("基础 TCP 网络连接成功", "addr", addr)
+	bc.mux.setState(func(st *State) { st.Phase = PhaseAuthenticating })
 
 	resp, err := bc.authentication(sess, timeout)
 	if err != nil {
@@ -109,12 +144,16 @@ func (bc *brokerClient) connect(addr string, timeout time.Duration) (*smux.Sessi
 }
 
 func (bc *brokerClient) openSMUX(addr string, timeout time.Duration) (*smux.Session, error) {
-	dialer := bc.opt.dialer()
+	destURL, err := url.Parse(addr)
+	if err != nil {
+		return nil, err
+	}
+	destURL.Path = "/api/v1/tunnel"
+	dialer := bc.opt.dialer(bc.trust.TLSConfig(destURL.Hostname()))
 
 	ctx, cancel := context.WithTimeout(bc.ctx, timeout)
 	defer cancel()
 
-	destURL := &url.URL{Scheme: "ws", Host: addr, Path: "/api/v1/tunnel"}
 	ws, _, err := dialer.DialContext(ctx, destURL.String(), nil)
 	if err != nil {
 		return nil, err
@@ -168,7 +207,12 @@ func (bc *brokerClient) serve() {
 		err := srv.Serve(lis)
 		_ = lis.Close()
 
-		const sleep = 3 * time.Second
+		// 掉线后也要随机等待一段时间，防止中心端重启后所有 broker 同时重连。
+		sleep := bc.opt.backoff().next(1)
+		bc.mux.setState(func(st *State) {
+			st.Phase, st.Fails, st.LastError = PhaseBackoff, 1, errString(err)
+			st.RetryAt = time.Now().Add(sleep)
+		})
 		bc.opt.logger().Warn("broker 掉线了", "error", err, "sleep", sleep)
 		_ = bc.sleep(sleep)
 		if err = bc.open(); err != nil {
@@ -187,18 +231,10 @@ func (bc *brokerClient) sleep(d time.Duration) error {
 	}
 }
 
-func (*brokerClient) waitN(fails int, startAt time.Time) time.Duration {
-	if fails <= 30 {
-		return 2 * time.Second
-	} else if fails <= 100 {
-		return 5 * time.Second
-	} else if fails <= 200 {
-		return 10 * time.Second
-	}
-
-	if du := time.Since(startAt); du <= 24*time.Hour {
-		return 30 * time.Second
+func errString(err error) string {
+	if err == nil {
+		return ""
 	}
 
-	return time.Minute
+	return err.Error()
 }
@@ -18,6 +18,12 @@ type Options struct {
 
 	// Timeout 分别控制连接的超时和握手读写超时时间。
 	Timeout time.Duration
+
+	// MinBackoff 重连退避的初始时长，默认 1 秒。
+	MinBackoff time.Duration
+
+	// MaxBackoff 重连退避的最长时长，默认 2 分钟。
+	MaxBackoff time.Duration
 }
 
 func (opt Options) logger() *slog.Logger {
@@ -45,11 +51,22 @@ func (opt Options) timeout() time.Duration {
 	return 30 * time.Second
 }
 
-func (opt Options) dialer() *websocket.Dialer {
+func (opt Options) backoff() backoff {
+	b := backoff{min: opt.MinBackoff, max: opt.MaxBackoff}
+	if b.min <= 0 {
+		b.min = time.Second
+	}
+	if b.max <= 0 {
+		b.max = 2 * time.Minute
+	}
+	b.max = max(b.max, b.min)
+
+	return b
+}
+
+func (opt Options) dialer(tlsCfg *tls.Config) *websocket.Dialer {
 	return &websocket.Dialer{
 		HandshakeTimeout: opt.timeout(),
-		TLSClientConfig: &tls.Config{
-			InsecureSkipVerify: true,
-		},
+		TLSClientConfig:  tlsCfg,
 	}
 }
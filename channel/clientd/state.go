@@ -0,0 +1,52 @@
+package clientd
+
+import (
+	"math/rand/v2"
+	"time"
+)
+
+// Phase 与中心端通道的连接阶段。
+type Phase string
+
+const (
+	PhaseConnecting     Phase = "connecting"     // 正在建立网络连接
+	PhaseAuthenticating Phase = "authenticating" // 网络已连通，正在上线认证
+	PhaseConnected      Phase = "connected"      // 认证成功，通道可用
+	PhaseBackoff        Phase = "backing-off"    // 连接或认证失败（或掉线），等待重试
+)
+
+// State 与中心端通道的连接状态。
+type State struct {
+	Phase     Phase     `json:"phase"`
+	Addr      string    `json:"addr"`                 // 当前（或最近一次）连接的中心端地址
+	LastError string    `json:"last_error,omitempty"` // 最近一次连接失败或掉线的原因
+	Fails     int       `json:"fails"`                // 连续失败次数，连接成功后清零
+	Since     time.Time `json:"since"`                // 进入当前阶段的时间
+	RetryAt   time.Time `json:"retry_at,omitzero"`    // 退避结束、下次重试的时间
+}
+
+// Connected 通道是否可用。
+func (s State) Connected() bool {
+	return s.Phase == PhaseConnected
+}
+
+// StateWatcher 连接状态变更回调，在状态变更的协程中同步执行，不要在回调中阻塞。
+type StateWatcher func(old, cur State)
+
+// backoff 带随机抖动的指数退避：基准时长每失败一次翻倍直至上限，
+// 实际等待时长在 [基准/2, 基准] 之间随机，避免中心端重启后所有 broker 同时重连。
+type backoff struct {
+	min time.Duration
+	max time.Duration
+}
+
+func (b backoff) next(fails int) time.Duration {
+	base := b.min
+	for i := 1; i < fails && base < b.max; i++ {
+		base *= 2
+	}
+	base = min(base, b.max)
+	half := base / 2
+
+	return half + rand.N(half+1)
+}
@@ -1,21 +1,55 @@
 package serverd
 
+import (
+	"github.com/vela-ssoc/ssoc-broker/library/capability"
+	"github.com/vela-ssoc/ssoc-broker/library/collision"
+	"github.com/vela-ssoc/ssoc-broker/library/inetx"
+)
+
 type authRequest struct {
-	MachineID  string `json:"machine_id" validate:"required"`    // 机器码
-	Inet       string `json:"inet"       validate:"required,ip"` // 出口 IP
-	PID        int    `json:"pid"`                               // 进程 PID
-	Workdir    string `json:"workdir"`                           // 工作目录
-	Executable string `json:"executable"`                        // 执行路径
-	Hostname   string `json:"hostname"`                          // 主机名
-	Goos       string `json:"goos"`                              // runtime.GOOS
-	Goarch     string `json:"goarch"`                            // runtime.GOARCH
-	Semver     string `json:"semver"`                            // 节点版本
-	Unload     bool   `json:"unload"`                            // 是否开启静默模式，仅在新注册节点时有效
-	Unstable   bool   `json:"unstable"`                          // 不稳定版本
-	Customized string `json:"customized"`                        // 定制版本
+	MachineID  string `json:"machine_id" validate:"required"`     // 机器码
+	Inet       string `json:"inet"       validate:"required,ip"`  // 出口 IP，IPv6-only 节点为 IPv6 地址
+	Inet6      string `json:"inet6"      validate:"omitempty,ip"` // 双栈节点的 IPv6 地址
+	PID        int    `json:"pid"`                                // 进程 PID
+	Workdir    string `json:"workdir"`                            // 工作目录
+	Executable string `json:"executable"`                         // 执行路径
+	Hostname   string `json:"hostname"`                           // 主机名
+	MAC        string `json:"mac"`                                // 出口 IP 所在网卡的 MAC 地址，用于机器码冲突检测
+	Goos       string `json:"goos"`                               // runtime.GOOS
+	Goarch     string `json:"goarch"`                             // runtime.GOARCH
+	Semver     string `json:"semver"`                             // 节点版本
+	Unload     bool   `json:"unload"`                             // 是否开启静默模式，仅在新注册节点时有效
+	Unstable   bool   `json:"unstable"`                           // 不稳定版本
+	Customized string `json:"customized"`                         // 定制版本
+	PublicKey  []byte `json:"public_key"`                         // agent ed25519 公钥，首次上线时登记
+	Nonce      string `json:"nonce"`                              // broker 下发的一次性随机数
+	Signature  []byte `json:"signature"`                          // 使用私钥对 nonce 的签名
+
+	Protocol int                  `json:"protocol"` // 握手协议版本，老版本 agent 为 0
+	Features []capability.Feature `json:"features"` // agent 支持的特性
 }
 
 type authResponse struct {
-	Code    int    `json:"code"`
-	Message string `json:"message"`
+	Code       int    `json:"code"`
+	Message    string `json:"message"`
+	RetryAfter int    `json:"retry_after,omitempty"` // 被限流时建议多少秒后重试（已加随机抖动）
+	Nonce      string `json:"nonce,omitempty"`       // 身份校验的一次性随机数，agent 签名后在同一个流上重新发送认证报文
+
+	Protocol int            `json:"protocol,omitempty"` // 上线成功时返回协商后的握手协议版本
+	Features capability.Set `json:"features,omitempty"` // 上线成功时返回协商后双方都支持的特性
+}
+
+// inetPair agent 上报的双栈地址。
+func (r authRequest) inetPair() inetx.Pair {
+	return inetx.FromString(r.Inet, r.Inet6)
+}
+
+// collisionClaim 冲突检测使用的主机特征。
+func (r authRequest) collisionClaim() collision.Claim {
+	return collision.Claim{
+		MachineID: r.MachineID,
+		Inet:      r.inetPair().Primary(),
+		Hostname:  r.Hostname,
+		MAC:       r.MAC,
+	}
 }
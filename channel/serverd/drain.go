@@ -0,0 +1,93 @@
+package serverd
+
+import (
+	"context"
+	"time"
+
+	"github.com/vela-ssoc/ssoc-broker/library/drain"
+	"github.com/vela-ssoc/ssoc-common-mb/dal/model"
+	"github.com/vela-ssoc/ssoc-common/linkhub"
+)
+
+var ErrDraining = drain.ErrDraining
+
+// DrainOption 排空参数。
+type DrainOption struct {
+	drain.Option
+
+	// Notify 断开连接前通知 agent 迁移到其它 broker，可以为空。
+	Notify func(ctx context.Context, peer linkhub.Peer) error
+}
+
+func (as *agentServer) Draining() bool {
+	return as.drain.Draining()
+}
+
+func (as *agentServer) Undrain() error {
+	if err := as.drain.Resume(); err != nil {
+		return err
+	}
+	as.log().Warn("broker 退出排空模式")
+
+	return nil
+}
+
+func (as *agentServer) Drain(parent context.Context, opt DrainOption) error {
+	done, err := as.DrainAsync(parent, opt)
+	if err != nil {
+		return err
+	}
+
+	return <-done
+}
+
+func (as *agentServer) DrainAsync(parent context.Context, opt DrainOption) (<-chan error, error) {
+	ctx, err := as.drain.Begin(parent)
+	if err != nil {
+		return nil, err
+	}
+
+	done := make(chan error, 1)
+	go func() { done <- as.drainWaves(ctx, opt) }()
+
+	return done, nil
+}
+
+// drainWaves 分批释放已连接的 agent，调用方需已进入排空模式。
+func (as *agentServer) drainWaves(ctx context.Context, opt DrainOption) error {
+	wave := opt.Format()
+	peers := as.opt.huber.All()
+	as.log().Warn("broker 进入排空模式", "agents", len(peers), "wave", wave.Wave, "interval", wave.Interval)
+
+	release := func(ctx context.Context, p linkhub.Peer) {
+		if opt.Notify != nil {
+			cctx, cancel := context.WithTimeout(ctx, 5*time.Second)
+			if exx := opt.Notify(cctx, p); exx != nil {
+				as.log().Debug("通知 agent 迁移失败", "agent_id", p.Info().ID, "error", exx)
+			}
+			cancel()
+		}
+		_ = p.Muxer().Close()
+	}
+	abort := func(p linkhub.Peer) { _ = p.Muxer().Close() }
+	if !drain.Waves(ctx, peers, wave, release, abort) {
+		as.log().Warn("排空已取消，剩余 agent 保持连接")
+		return nil
+	}
+	as.log().Warn("agent 已全部释放，准备重置节点状态")
+
+	return as.resetAgents()
+}
+
+// resetAgents 将当前 broker 下仍标记为在线的节点置为离线。
+func (as *agentServer) resetAgents() error {
+	ctx, cancel := context.WithTimeout(context.Background(), time.Minute)
+	defer cancel()
+
+	tbl := as.qry.Minion
+	dao := tbl.WithContext(ctx)
+	_, err := dao.Where(tbl.Status.Eq(uint8(model.MSOnline)), tbl.BrokerID.Eq(as.cur.ID)).
+		UpdateColumn(tbl.Status, model.MSOffline)
+
+	return err
+}
@@ -2,13 +2,16 @@ package serverd
 
 import "github.com/vela-ssoc/ssoc-common/linkhub"
 
+// AgentNotifier agent 上下线通知，在 agent 连接的协程中同步调用，耗时操作需要自行异步处理。
+//
+// peer 可以通过 AgentOf 获取 agent 上线认证时上报的信息。
 type AgentNotifier interface {
 	AgentConnected(peer linkhub.Peer)
-	AgentDisconnected(agentID int64)
+	AgentDisconnected(peer linkhub.Peer)
 }
 
 type agentNotifier struct{}
 
 func (agentNotifier) AgentConnected(linkhub.Peer) {}
 
-func (agentNotifier) AgentDisconnected(int64) {}
+func (agentNotifier) AgentDisconnected(linkhub.Peer) {}
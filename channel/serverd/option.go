@@ -5,9 +5,13 @@ import (
 	"fmt"
 	"log/slog"
 	"net/http"
-	"net/netip"
 	"time"
 
+	"github.com/vela-ssoc/ssoc-broker/library/admission"
+	"github.com/vela-ssoc/ssoc-broker/library/collision"
+	"github.com/vela-ssoc/ssoc-broker/library/hookbus"
+	"github.com/vela-ssoc/ssoc-broker/library/identity"
+	"github.com/vela-ssoc/ssoc-broker/library/quota"
 	"github.com/vela-ssoc/ssoc-common/linkhub"
 )
 
@@ -19,29 +23,26 @@ func defaultValid(v any) error {
 	if req.MachineID == "" {
 		return errors.New("machine_id 必须填写")
 	}
-	if _, err := netip.ParseAddr(req.Inet); err != nil {
+	if !req.inetPair().Valid() {
 		return errors.New("IP 填写错误")
 	}
 
 	return nil
 }
 
-type Limiter interface {
-	Allowed() bool
-}
-
-type unlimited struct{}
-
-func (*unlimited) Allowed() bool { return true }
-
 type option struct {
 	logger   *slog.Logger
 	valid    func(any) error
 	server   *http.Server
-	limit    Limiter
+	admit    admission.Controller
 	huber    linkhub.Huber
 	timeout  time.Duration
 	notifier AgentNotifier
+	liveness LivenessChecker
+	verify   identity.Verifier
+	collide  collision.Detector
+	quota    *quota.Config
+	hooks    hookbus.Bus
 }
 
 func NewOption() OptionBuilder {
@@ -92,9 +93,10 @@ func (ob OptionBuilder) Handler(v http.Handler) OptionBuilder {
 	return ob
 }
 
-func (ob OptionBuilder) Limit(v Limiter) OptionBuilder {
+// Admission agent 接入准入控制，默认使用 admission.New 的默认参数。
+func (ob OptionBuilder) Admission(v admission.Controller) OptionBuilder {
 	ob.opts = append(ob.opts, func(o option) option {
-		o.limit = v
+		o.admit = v
 		return o
 	})
 	return ob
@@ -124,6 +126,51 @@ func (ob OptionBuilder) AgentNotifier(v AgentNotifier) OptionBuilder {
 	return ob
 }
 
+// Liveness 询问 agent 在其它 broker 上的会话是否存活，为空时仅根据租约判断。
+func (ob OptionBuilder) Liveness(v LivenessChecker) OptionBuilder {
+	ob.opts = append(ob.opts, func(o option) option {
+		o.liveness = v
+		return o
+	})
+	return ob
+}
+
+// Identity agent 身份校验，为空时使用 identity.New 创建。
+func (ob OptionBuilder) Identity(v identity.Verifier) OptionBuilder {
+	ob.opts = append(ob.opts, func(o option) option {
+		o.verify = v
+		return o
+	})
+	return ob
+}
+
+// Collision agent 机器码冲突检测，为空时使用 collision.New 的默认参数创建。
+func (ob OptionBuilder) Collision(v collision.Detector) OptionBuilder {
+	ob.opts = append(ob.opts, func(o option) option {
+		o.collide = v
+		return o
+	})
+	return ob
+}
+
+// Quota 单个 agent 的资源配额，为空时使用 quota.DefaultConfig。
+func (ob OptionBuilder) Quota(v quota.Config) OptionBuilder {
+	ob.opts = append(ob.opts, func(o option) option {
+		o.quota = &v
+		return o
+	})
+	return ob
+}
+
+// Hooks agent 生命周期事件总线，为空时不发布事件。
+func (ob OptionBuilder) Hooks(v hookbus.Bus) OptionBuilder {
+	ob.opts = append(ob.opts, func(o option) option {
+		o.hooks = v
+		return o
+	})
+	return ob
+}
+
 func fallbackOption() OptionBuilder {
 	return OptionBuilder{
 		opts: []func(option) option{
@@ -137,8 +184,8 @@ func fallbackOption() OptionBuilder {
 				if o.server.Handler == nil {
 					o.server.Handler = http.NotFoundHandler()
 				}
-				if o.limit == nil {
-					o.limit = new(unlimited)
+				if o.admit == nil {
+					o.admit = admission.New(admission.Option{})
 				}
 				if o.huber == nil {
 					o.huber = linkhub.NewSafeMap()
@@ -149,6 +196,10 @@ func fallbackOption() OptionBuilder {
 				if o.notifier == nil {
 					o.notifier = new(agentNotifier)
 				}
+				if o.quota == nil {
+					cfg := quota.DefaultConfig()
+					o.quota = &cfg
+				}
 
 				return o
 			},
@@ -0,0 +1,128 @@
+package serverd
+
+import (
+	"sync"
+	"time"
+
+	"github.com/vela-ssoc/ssoc-broker/library/capability"
+	"github.com/vela-ssoc/ssoc-common/linkhub"
+)
+
+// Agent agent 上线认证时上报的信息。
+type Agent struct {
+	ID          int64     `json:"id,string"`
+	MachineID   string    `json:"machine_id"`
+	Inet        string    `json:"inet"`  // 主地址，IPv6-only 节点为 IPv6 地址
+	Inet6       string    `json:"inet6"` // 双栈节点的 IPv6 地址
+	PID         int       `json:"pid"`
+	Workdir     string    `json:"workdir"`
+	Executable  string    `json:"executable"`
+	Hostname    string    `json:"hostname"`
+	Goos        string    `json:"goos"`
+	Goarch      string    `json:"goarch"`
+	Semver      string    `json:"semver"`
+	Unload      bool      `json:"unload"`
+	Unstable    bool      `json:"unstable"`
+	Customized  string    `json:"customized"`
+	Created     bool      `json:"created"` // 是否为本次上线时自动新增的节点
+	ConnectedAt time.Time `json:"connected_at"`
+
+	Protocol int            `json:"protocol"` // 协商的握手协议版本
+	Features capability.Set `json:"features"` // 协商的特性
+
+	Routes map[string]RouteStat `json:"routes"` // 按路由统计的请求数
+}
+
+// RouteStat 单个路由的请求统计。
+type RouteStat struct {
+	Requests uint64 `json:"requests"`
+	Errors   uint64 `json:"errors"`
+}
+
+// AgentOf 获取 agent 上线认证时上报的信息，peer 不是由 serverd 创建的返回 false。
+//
+// 业务接口可以通过 linkhub.FromContext 拿到 peer。
+func AgentOf(peer linkhub.Peer) (Agent, bool) {
+	ap, ok := peer.(*agentPeer)
+	if !ok {
+		return Agent{}, false
+	}
+
+	agt := ap.agent
+	ap.mutex.Lock()
+	agt.Routes = make(map[string]RouteStat, len(ap.routes))
+	for name, rs := range ap.routes {
+		agt.Routes[name] = *rs
+	}
+	ap.mutex.Unlock()
+
+	return agt, true
+}
+
+// Record 记录 agent 发来的一次请求，用于会话统计，peer 不是由 serverd 创建的忽略。
+func Record(peer linkhub.Peer, route string, failed bool) {
+	ap, ok := peer.(*agentPeer)
+	if !ok {
+		return
+	}
+
+	ap.mutex.Lock()
+	defer ap.mutex.Unlock()
+
+	rs := ap.routes[route]
+	if rs == nil {
+		rs = new(RouteStat)
+		ap.routes[route] = rs
+	}
+	rs.Requests++
+	if failed {
+		rs.Errors++
+	}
+}
+
+// FeaturesOf 查询在线 agent 协商的特性，agent 不在线或不是由 serverd 接入的返回 false。
+func FeaturesOf(huber linkhub.Huber, id int64) (capability.Set, bool) {
+	agt, ok := AgentOf(huber.GetByID(id))
+	if !ok {
+		return nil, false
+	}
+
+	return agt.Features, true
+}
+
+type agentPeer struct {
+	linkhub.Peer
+	agent  Agent
+	mutex  sync.Mutex
+	routes map[string]*RouteStat
+}
+
+func newAgentPeer(peer linkhub.Peer, req *authRequest, created bool) *agentPeer {
+	pair := req.inetPair()
+	protocol, features := capability.Negotiate(req.Protocol, req.Features, req.Semver)
+
+	return &agentPeer{
+		Peer:   peer,
+		routes: make(map[string]*RouteStat, 16),
+		agent: Agent{
+			ID:          peer.Info().ID,
+			MachineID:   req.MachineID,
+			Inet:        pair.Primary(),
+			Inet6:       pair.Inet6(),
+			PID:         req.PID,
+			Workdir:     req.Workdir,
+			Executable:  req.Executable,
+			Hostname:    req.Hostname,
+			Goos:        req.Goos,
+			Goarch:      req.Goarch,
+			Semver:      req.Semver,
+			Unload:      req.Unload,
+			Unstable:    req.Unstable,
+			Customized:  req.Customized,
+			Created:     created,
+			ConnectedAt: time.Now(),
+			Protocol:    protocol,
+			Features:    features,
+		},
+	}
+}
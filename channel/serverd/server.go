@@ -10,8 +10,19 @@ import (
 	"log/slog"
 	"net"
 	"net/http"
+	"sync"
 	"time"
 
+	"github.com/vela-ssoc/ssoc-broker/library/admission"
+	"github.com/vela-ssoc/ssoc-broker/library/capability"
+	"github.com/vela-ssoc/ssoc-broker/library/collision"
+	"github.com/vela-ssoc/ssoc-broker/library/drain"
+	"github.com/vela-ssoc/ssoc-broker/library/handshake"
+	"github.com/vela-ssoc/ssoc-broker/library/hookbus"
+	"github.com/vela-ssoc/ssoc-broker/library/identity"
+	"github.com/vela-ssoc/ssoc-broker/library/inetx"
+	"github.com/vela-ssoc/ssoc-broker/library/metrics"
+	"github.com/vela-ssoc/ssoc-broker/library/quota"
 	"github.com/vela-ssoc/ssoc-common-mb/dal/model"
 	"github.com/vela-ssoc/ssoc-common-mb/dal/query"
 	"github.com/vela-ssoc/ssoc-common-mb/options"
@@ -25,59 +36,120 @@ type Handler interface {
 	Handle(sess *smux.Session)
 }
 
-func New(qry *query.Query, cur *model.Broker, opts ...options.Lister[option]) Handler {
+// Server agent 接入服务。
+type Server interface {
+	Handler
+
+	// Drain 进入排空模式，不再接入新的 agent 并分批释放已连接的 agent，阻塞直至排空结束。
+	Drain(ctx context.Context, opt DrainOption) error
+
+	// DrainAsync 进入排空模式后立即返回，已经处于排空模式返回 ErrDraining，
+	// 排空的结果从返回的通道中读取。
+	DrainAsync(ctx context.Context, opt DrainOption) (<-chan error, error)
+
+	// Undrain 退出排空模式，重新接入新的 agent，正在进行的排空会停止。
+	Undrain() error
+
+	// Draining 是否处于排空模式。
+	Draining() bool
+}
+
+func New(qry *query.Query, cur *model.Broker, opts ...options.Lister[option]) Server {
 	opts = append(opts, fallbackOption())
 	opt := options.Eval(opts...)
 
-	return &agentServer{
+	as := &agentServer{
 		qry: qry,
 		cur: cur,
 		opt: opt,
 	}
+	if as.opt.verify == nil {
+		as.opt.verify = identity.New(qry, as.log())
+	}
+	if as.opt.collide == nil {
+		as.opt.collide = collision.New(qry, collision.Option{}, as.log())
+	}
+	// 新通道唯一的配额限制点，挂载到 server.Handler 上的路由不要再重复限制。
+	as.opt.server.Handler = quota.Handler(as.opt.server.Handler)
+
+	return as
 }
 
 type agentServer struct {
-	qry *query.Query
-	cur *model.Broker
-	opt option
+	qry   *query.Query
+	cur   *model.Broker
+	opt   option
+	drain drain.Gate
+	done  sync.Map       // linkhub.Peer -> chan struct{}，会话下线清理完毕后关闭
+	slots [64]sync.Mutex // 按 agent ID 分段的锁，保证检查会话归属与释放会话是原子的，见 release
 }
 
 func (as *agentServer) Handle(sess *smux.Session) {
 	defer sess.Close()
 
-	// 如果 broker 重启，会导致 agent 同时下线，那么重启上线时间也基本一致，
-	// 为了防止 agent 蜂拥上线对 broker 造成压力，可以通过限流器分批上线。
-	if !as.opt.limit.Allowed() {
-		as.log().Error("限流器阻止 agent 建立连接")
-		return
-	}
-
 	timeout := as.opt.timeout
-	peer, _, err := as.authentication(sess, timeout)
+	peer, mon, err := as.authentication(sess, timeout)
 	if err != nil {
 		as.log().Warn("节点上线认证失败", "error", err)
 		return
 	}
+	connectedAt := time.Now()
+	as.publish(hookbus.KindConnected, mon, "", 0)
+	defer func() {
+		as.publish(hookbus.KindDisconnected, mon, "", time.Since(connectedAt))
+	}()
+	done := make(chan struct{})
+	as.done.Store(peer, done)
+	defer func() {
+		as.done.Delete(peer)
+		close(done)
+	}()
 	defer as.disconnect(peer, timeout)
 
 	as.opt.notifier.AgentConnected(peer)
 
-	srv := as.opt.server
-	base := srv.BaseContext
-	srv.BaseContext = func(ln net.Listener) context.Context {
-		parent := context.Background()
-		if base != nil {
-			parent = base(ln)
-		}
-		return linkhub.WithContext(parent, peer)
-	}
+	// 资源超限先限流，一段时间内违规次数过多则断开连接。
+	guard := quota.NewGuard(*as.opt.quota, func(reason string) {
+		as.log().Warn("agent 资源使用多次超限，断开连接", "agent_id", peer.Info().ID, "reason", reason)
+		_ = sess.Close()
+	})
 
-	lis := &smuxListener{sess: sess}
+	srv := as.sessionServer(func(parent context.Context) context.Context {
+		return quota.WithContext(linkhub.WithContext(parent, peer), guard)
+	})
+	lis := &smuxListener{sess: sess, guard: guard}
 	err = srv.Serve(lis)
 
 	as.log().Warn("agent 节点下线了", "error", err)
 }
 
+// sessionServer 以 opt.server 为模板为每个会话创建单独的 http.Server，
+// 各个会话的 BaseContext 携带各自的 peer 与配额，不能修改共享的 opt.server。
+func (as *agentServer) sessionServer(wrap func(context.Context) context.Context) *http.Server {
+	tpl := as.opt.server
+	base := tpl.BaseContext
+
+	return &http.Server{
+		Handler:                      tpl.Handler,
+		DisableGeneralOptionsHandler: tpl.DisableGeneralOptionsHandler,
+		ReadTimeout:                  tpl.ReadTimeout,
+		ReadHeaderTimeout:            tpl.ReadHeaderTimeout,
+		WriteTimeout:                 tpl.WriteTimeout,
+		IdleTimeout:                  tpl.IdleTimeout,
+		MaxHeaderBytes:               tpl.MaxHeaderBytes,
+		ConnState:                    tpl.ConnState,
+		ErrorLog:                     tpl.ErrorLog,
+		ConnContext:                  tpl.ConnContext,
+		BaseContext: func(ln net.Listener) context.Context {
+			parent := context.Background()
+			if base != nil {
+				parent = base(ln)
+			}
+			return wrap(parent)
+		},
+	}
+}
+
 func (as *agentServer) authentication(sess *smux.Session, timeout time.Duration) (linkhub.Peer, *model.Minion, error) {
 	timer := time.AfterFunc(timeout, func() { _ = sess.Close() })
 	sig, err := sess.AcceptStream()
@@ -88,39 +160,126 @@ func (as *agentServer) authentication(sess *smux.Session, timeout time.Duration)
 	defer sig.Close()
 
 	_ = sig.SetDeadline(time.Now().Add(timeout))
-	req, err := as.readRequest(sig)
-	if err != nil {
-		return nil, nil, err
-	}
-	if err = as.opt.valid(req); err != nil {
-		return nil, nil, err
-	}
 
-	mon, peer, code, err1 := as.join(sess, req, timeout)
-	err2 := as.writeResponse(sig, code, err1)
-	if err1 != nil {
-		return nil, mon, err1
-	} else if err2 != nil {
-		return nil, mon, err2
+	// 需要身份校验时，broker 在同一个流上下发 nonce，agent 签名后重新发送认证报文，最多两轮。
+	// 第二轮复用第一轮冲突检测与查找节点的结果。
+	lk := new(lookup)
+	for round := 0; ; round++ {
+		req, err := as.readRequest(sig, timeout)
+		if err != nil {
+			as.reject(sess, err)
+			return nil, nil, err
+		}
+		if err = as.opt.valid(req); err != nil {
+			as.reject(sess, handshake.Reject(handshake.ReasonInvalid, err))
+			return nil, nil, err
+		}
+
+		// 如果 broker 重启，会导致 agent 同时下线，那么重启上线时间也基本一致，
+		// 为了防止 agent 蜂拥上线对数据库造成压力，通过准入控制让 agent 错峰上线。
+		tkt, err := as.opt.admit.Admit()
+		if err != nil {
+			as.log().Warn("准入控制阻止 agent 建立连接", "error", err)
+			authResult(http.StatusTooManyRequests)
+			_ = as.writeResponse(sig, http.StatusTooManyRequests, err)
+			return nil, nil, err
+		}
+		mon, peer, code, err1 := as.join(sess, req, lk, timeout)
+		tkt.Done()
+		if round == 0 && errors.Is(err1, identity.ErrChallenge) {
+			metrics.AgentAuth.With("serverd", "challenge").Inc()
+			resp := &authResponse{Code: code, Message: err1.Error(), Nonce: as.opt.verify.Challenge(req.MachineID)}
+			if err = as.writeAuthResponse(sig, resp); err != nil {
+				return nil, mon, err
+			}
+			continue
+		}
+
+		authResult(code)
+		resp := newAuthResponse(code, err1)
+		if agt, ok := AgentOf(peer); ok {
+			resp.Protocol, resp.Features = agt.Protocol, agt.Features
+		}
+		err2 := as.writeAuthResponse(sig, resp)
+		if err1 != nil {
+			return nil, mon, err1
+		} else if err2 != nil {
+			// 已经上线成功但没能通知 agent，释放占用的会话，否则 agent 重试时会一直重复上线。
+			as.release(peer, timeout)
+			return nil, mon, err2
+		}
+
+		return peer, mon, nil
 	}
+}
 
-	return peer, mon, nil
+// lookup 冲突检测与查找节点的结果。
+type lookup struct {
+	claim   collision.Claim
+	verdict collision.Verdict
+	mon     *model.Minion
+	created bool
 }
 
-func (as *agentServer) join(sess *smux.Session, req *authRequest, timeout time.Duration) (*model.Minion, linkhub.Peer, int, error) {
+func (as *agentServer) join(sess *smux.Session, req *authRequest, lk *lookup, timeout time.Duration) (*model.Minion, linkhub.Peer, int, error) {
 	attrs := []any{slog.Any("agent_auth_request", req), slog.Duration("timeout", timeout)}
-	mon, err := as.findOrCreate(req, timeout)
-	if err != nil {
-		attrs = append(attrs, slog.Any("error", err))
-		as.log().Error("查找或自动新增 agent 节点发生错误", attrs...)
-		return nil, nil, http.StatusInternalServerError, err
+	if as.drain.Draining() {
+		as.log().Warn("broker 正在排空，拒绝 agent 上线", attrs...)
+		return nil, nil, http.StatusServiceUnavailable, ErrDraining
 	}
+
+	// challenge 第二轮的主机特征与第一轮相同时不再重复检测和查找。
+	claim := req.collisionClaim()
+	if lk.mon == nil || lk.claim != claim {
+		verdict, code, err := as.collide(req, claim, timeout)
+		if err != nil {
+			attrs = append(attrs, slog.Any("error", err))
+			as.log().Warn("agent 机器码冲突检测未通过", attrs...)
+			return nil, nil, code, err
+		}
+		mon, created, err := as.findOrCreate(req, verdict.MachineID, timeout)
+		if err != nil {
+			attrs = append(attrs, slog.Any("error", err))
+			as.log().Error("查找或自动新增 agent 节点发生错误", attrs...)
+			return nil, nil, http.StatusInternalServerError, err
+		}
+		*lk = lookup{claim: claim, verdict: verdict, mon: mon, created: created}
+	}
+	mon, created := lk.mon, lk.created
+
+	// 校验 agent 身份，必须在接管旧会话之前，防止冒充的 agent 挤掉正常的 agent。
+	if code, exx := as.verify(mon, req, timeout); exx != nil {
+		attrs = append(attrs, slog.Any("error", exx))
+		as.log().Warn("agent 身份校验未通过", attrs...)
+		if errors.Is(exx, identity.ErrQuarantined) {
+			as.publish(hookbus.KindQuarantined, mon, exx.Error(), 0)
+		}
+		return mon, nil, code, exx
+	}
+	// 身份校验通过后才记录冲突，防止伪造的认证报文污染冲突记录。
+	if lk.verdict.Collided {
+		if code, exx := as.commitCollision(claim, mon, timeout); exx != nil {
+			attrs = append(attrs, slog.Any("error", exx))
+			as.log().Warn("agent 机器码冲突检测未通过", attrs...)
+			return mon, nil, code, exx
+		}
+	}
+
 	// 检查状态是否允许上线
 	status := mon.Status
 	switch status {
-	case model.MSOnline: // 已经在线的不能上线
-		as.log().Warn("agent 节点已经在线了（数据库检查）", attrs...)
-		return mon, nil, http.StatusConflict, errors.New("节点重复上线")
+	case model.MSOnline: // 已经在线的不能上线，除非原会话已经失效
+		ok, exx := as.takeover(mon, timeout)
+		if exx != nil {
+			attrs = append(attrs, slog.Any("error", exx))
+			as.log().Error("检查 agent 原会话是否失效发生错误", attrs...)
+			return mon, nil, http.StatusInternalServerError, exx
+		}
+		if !ok {
+			as.publish(hookbus.KindRepeated, mon, "", 0)
+			as.log().Warn("agent 节点已经在线了（数据库检查）", attrs...)
+			return mon, nil, http.StatusConflict, errors.New("节点重复上线")
+		}
 	case model.MSDelete: // 标记为已删除的不允许上线
 		as.log().Info("agent 节点已标记为删除", attrs...)
 		return mon, nil, http.StatusForbidden, errors.New("节点已标记为删除")
@@ -133,8 +292,10 @@ func (as *agentServer) join(sess *smux.Session, req *authRequest, timeout time.D
 	}
 
 	minionID := mon.ID
-	peer := linkhub.NewPeer(minionID, req.Inet, sess)
+	pair := req.inetPair()
+	peer := newAgentPeer(linkhub.NewPeer(minionID, pair.Primary(), sess), req, created)
 	if !as.opt.huber.Put(peer) {
+		as.publish(hookbus.KindRepeated, mon, "", 0)
 		as.log().Warn("agent 节点已经在线了（内存检查）", attrs...)
 		return mon, nil, http.StatusConflict, errors.New("节点重复上线")
 	}
@@ -144,7 +305,8 @@ func (as *agentServer) join(sess *smux.Session, req *authRequest, timeout time.D
 	tbl := as.qry.Minion
 	updates := []field.AssignExpr{
 		tbl.Status.Value(online),
-		tbl.Inet.Value(req.Inet),
+		tbl.Inet.Value(pair.Inet()),
+		tbl.Inet6.Value(pair.Inet6()),
 		tbl.Goos.Value(req.Goos),
 		tbl.Arch.Value(req.Goarch),
 		tbl.Edition.Value(req.Semver),
@@ -159,7 +321,7 @@ func (as *agentServer) join(sess *smux.Session, req *authRequest, timeout time.D
 	ctx, cancel := context.WithTimeout(context.Background(), timeout)
 	defer cancel()
 
-	if _, err = tbl.WithContext(ctx).
+	if _, err := tbl.WithContext(ctx).
 		Where(tbl.ID.Eq(minionID), tbl.Status.Eq(offline)).
 		UpdateSimple(updates...); err != nil {
 		as.opt.huber.DelByID(minionID)
@@ -169,10 +331,9 @@ func (as *agentServer) join(sess *smux.Session, req *authRequest, timeout time.D
 	}
 
 	// 修改持久化标签
-	tags := []*model.MinionTag{
-		{MinionID: minionID, Tag: req.Inet, Kind: model.TkLifelong},
-		{MinionID: minionID, Tag: req.Goos, Kind: model.TkLifelong},
-		{MinionID: minionID, Tag: req.Goarch, Kind: model.TkLifelong},
+	tags := make([]*model.MinionTag, 0, 4)
+	for _, tag := range append(pair.Tags(), req.Goos, req.Goarch) {
+		tags = append(tags, &model.MinionTag{MinionID: minionID, Tag: tag, Kind: model.TkLifelong})
 	}
 	_ = as.qry.Transaction(func(tx *query.Query) error {
 		ttbl := tx.MinionTag
@@ -188,33 +349,50 @@ func (as *agentServer) join(sess *smux.Session, req *authRequest, timeout time.D
 	return mon, peer, http.StatusOK, nil
 }
 
-func (as *agentServer) readRequest(stm *smux.Stream) (*authRequest, error) {
-	head := make([]byte, 4)
-	if n, err := io.ReadFull(stm, head); err != nil {
+// readRequest 读取认证报文，报文大小受 handshake.MaxFrame 限制，每轮读取都有超时。
+func (as *agentServer) readRequest(stm *smux.Stream, timeout time.Duration) (*authRequest, error) {
+	if err := stm.SetReadDeadline(time.Now().Add(timeout)); err != nil {
 		return nil, err
-	} else if n != 4 {
-		return nil, io.ErrShortBuffer
 	}
-
-	size := binary.BigEndian.Uint32(head)
-	data := make([]byte, size)
-	if n, err := io.ReadFull(stm, data); err != nil {
+	data, err := handshake.ReadFrame(stm, handshake.MaxFrame)
+	if err != nil {
 		return nil, err
-	} else if n != int(size) {
-		return nil, io.ErrShortBuffer
 	}
 
 	req := new(authRequest)
-	err := json.Unmarshal(data, req)
+	if err = json.Unmarshal(data, req); err != nil {
+		return nil, handshake.Reject(handshake.ReasonMalformed, err)
+	}
 
-	return req, err
+	return req, nil
+}
+
+// reject 记录认证报文被拒绝的原因。
+func (as *agentServer) reject(sess *smux.Session, err error) {
+	authResult(http.StatusBadRequest)
+	addr := sess.RemoteAddr().String()
+	reason := handshake.Record("serverd", addr, err)
+	as.log().Warn("agent 认证报文被拒绝", "remote_addr", addr, "reason", reason, "error", err)
 }
 
 func (as *agentServer) writeResponse(stm *smux.Stream, code int, err error) error {
+	return as.writeAuthResponse(stm, newAuthResponse(code, err))
+}
+
+func newAuthResponse(code int, err error) *authResponse {
 	resp := &authResponse{Code: code}
 	if err != nil {
 		resp.Message = err.Error()
 	}
+	var re *admission.RejectError
+	if errors.As(err, &re) {
+		resp.RetryAfter = re.Seconds()
+	}
+
+	return resp
+}
+
+func (as *agentServer) writeAuthResponse(stm *smux.Stream, resp *authResponse) error {
 	data, err := json.Marshal(resp)
 	if err != nil {
 		return err
@@ -234,24 +412,120 @@ func (as *agentServer) writeResponse(stm *smux.Stream, code int, err error) erro
 	return err
 }
 
-func (as *agentServer) findOrCreate(req *authRequest, timeout time.Duration) (*model.Minion, error) {
-	machineID := req.MachineID
+// verify 校验 agent 身份，返回错误时附带响应状态码。
+func (as *agentServer) verify(mon *model.Minion, req *authRequest, timeout time.Duration) (int, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), timeout)
+	defer cancel()
+
+	_, features := capability.Negotiate(req.Protocol, req.Features, req.Semver)
+	claim := identity.Claim{
+		MinionID:  mon.ID,
+		MachineID: req.MachineID,
+		Inet:      req.Inet,
+		PublicKey: req.PublicKey,
+		Nonce:     req.Nonce,
+		Signature: req.Signature,
+
+		KeyRequired: features.Has(capability.IdentityKey),
+	}
+	err := as.opt.verify.Verify(ctx, claim)
+	switch {
+	case err == nil:
+		return http.StatusOK, nil
+	case errors.Is(err, identity.ErrChallenge),
+		errors.Is(err, identity.ErrBadSignature),
+		errors.Is(err, identity.ErrBadPublicKey),
+		errors.Is(err, identity.ErrKeyRequired):
+		return http.StatusUnauthorized, err
+	case errors.Is(err, identity.ErrQuarantined):
+		return http.StatusForbidden, err
+	default:
+		return http.StatusInternalServerError, err
+	}
+}
+
+// collide 检测 agent 机器码是否与其它主机冲突，返回查找节点使用的机器码。
+//
+// 被隔离的新主机还没有对应的节点，无法校验身份，只有证明持有所声明公钥的私钥后才记录冲突。
+func (as *agentServer) collide(req *authRequest, claim collision.Claim, timeout time.Duration) (collision.Verdict, int, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), timeout)
+	defer cancel()
+
+	verdict, err := as.opt.collide.Observe(ctx, claim)
+	switch {
+	case err == nil:
+		return verdict, http.StatusOK, nil
+	case errors.Is(err, collision.ErrCollision):
+		_, features := capability.Negotiate(req.Protocol, req.Features, req.Semver)
+		if exx := as.opt.verify.Prove(identity.Claim{
+			MachineID: req.MachineID,
+			Inet:      claim.Inet,
+			PublicKey: req.PublicKey,
+			Nonce:     req.Nonce,
+			Signature: req.Signature,
+
+			KeyRequired: features.Has(capability.IdentityKey),
+		}); exx != nil {
+			return verdict, http.StatusUnauthorized, exx
+		}
+		// 被拒绝的主机还没有对应的节点，仅使用上报的信息发布事件。
+		pair := req.inetPair()
+		mon := &model.Minion{MachineID: req.MachineID, Inet: pair.Inet(), Inet6: pair.Inet6(), Edition: req.Semver}
+		if code, exx := as.commitCollision(claim, mon, timeout); exx != nil && !errors.Is(exx, collision.ErrCollision) {
+			return verdict, code, exx
+		}
+		return verdict, http.StatusForbidden, err
+	default:
+		return verdict, http.StatusInternalServerError, err
+	}
+}
 
+// commitCollision 记录已通过身份校验的冲突，同一台新主机只在首次冲突时发布事件。
+func (as *agentServer) commitCollision(claim collision.Claim, mon *model.Minion, timeout time.Duration) (int, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), timeout)
+	defer cancel()
+
+	verdict, err := as.opt.collide.Commit(ctx, claim)
+	if err != nil && !errors.Is(err, collision.ErrCollision) {
+		return http.StatusInternalServerError, err
+	}
+	if verdict.Collided && verdict.First {
+		reason := "机器码与其它主机冲突"
+		if err != nil {
+			reason = err.Error()
+		} else if verdict.Derived {
+			reason += "，使用派生的机器码：" + verdict.MachineID
+		}
+		as.publish(hookbus.KindCollided, mon, reason, 0)
+	}
+	if err != nil {
+		return http.StatusForbidden, err
+	}
+
+	return http.StatusOK, nil
+}
+
+// findOrCreate 根据机器码查找节点，不存在时自动创建，created 代表是否为本次新建。
+//
+// machineID 为冲突检测后的机器码，克隆主机派生了机器码时与 agent 上报的不同。
+func (as *agentServer) findOrCreate(req *authRequest, machineID string, timeout time.Duration) (mon *model.Minion, created bool, err error) {
 	ctx, cancel := context.WithTimeout(context.Background(), timeout)
 	defer cancel()
 
 	tbl := as.qry.Minion
 	dao := tbl.WithContext(ctx)
-	if data, err := dao.Where(tbl.MachineID.Eq(machineID)).First(); err == nil {
-		return data, nil
-	} else if !errors.Is(err, gorm.ErrRecordNotFound) {
-		return nil, err
+	if data, exx := dao.Where(tbl.MachineID.Eq(machineID)).First(); exx == nil {
+		return data, false, nil
+	} else if !errors.Is(exx, gorm.ErrRecordNotFound) {
+		return nil, false, exx
 	}
 
 	// 自动创建 agent 节点
+	pair := req.inetPair()
 	data := &model.Minion{
 		MachineID:  machineID,
-		Inet:       req.Inet,
+		Inet:       pair.Inet(),
+		Inet6:      pair.Inet6(),
 		Goos:       req.Goos,
 		Arch:       req.Goarch,
 		Edition:    req.Semver,
@@ -260,18 +534,36 @@ func (as *agentServer) findOrCreate(req *authRequest, timeout time.Duration) (*m
 		Unstable:   req.Unstable,
 		Customized: req.Customized,
 	}
-	if err := dao.Create(data); err != nil {
-		return nil, err
+	if err = dao.Create(data); err != nil {
+		return nil, false, err
 	}
+	as.publish(hookbus.KindCreated, data, "", 0)
 
-	return data, nil
+	return data, true, nil
 }
 
 func (as *agentServer) disconnect(peer linkhub.Peer, timeout time.Duration) {
+	as.release(peer, timeout)
+	as.opt.notifier.AgentDisconnected(peer)
+}
+
+// release 修改数据库中的节点状态为离线并将会话移出 huber。
+//
+// 只有会话仍占据 huber 中的位置时才会执行：会话被新会话接管时已经由 evict 移除，
+// 此时数据库中的在线状态属于新会话，不能被旧会话覆盖。
+func (as *agentServer) release(peer linkhub.Peer, timeout time.Duration) {
+	id := peer.Info().ID
+	mu := as.slot(id)
+	mu.Lock()
+	defer mu.Unlock()
+
+	if as.opt.huber.GetByID(id) != peer {
+		return
+	}
+
 	ctx, cancel := context.WithTimeout(context.Background(), timeout)
 	defer cancel()
 
-	id := peer.Info().ID
 	tbl := as.qry.Minion
 	dao := tbl.WithContext(ctx)
 	online, offline := uint8(model.MSOnline), uint8(model.MSOffline)
@@ -280,7 +572,41 @@ func (as *agentServer) disconnect(peer linkhub.Peer, timeout time.Duration) {
 		as.log().Warn("修改节点下线状态失败", "error", err)
 	}
 	as.opt.huber.DelByID(id)
-	as.opt.notifier.AgentDisconnected(id)
+}
+
+// evict 将已失效的会话移出 huber，不修改数据库状态，会话已被移除或不是该会话时不做处理。
+func (as *agentServer) evict(peer linkhub.Peer) {
+	id := peer.Info().ID
+	mu := as.slot(id)
+	mu.Lock()
+	defer mu.Unlock()
+
+	if as.opt.huber.GetByID(id) == peer {
+		as.opt.huber.DelByID(id)
+	}
+}
+
+func (as *agentServer) slot(id int64) *sync.Mutex {
+	return &as.slots[uint64(id)%uint64(len(as.slots))]
+}
+
+// publish 发布 agent 生命周期事件。
+func (as *agentServer) publish(kind hookbus.Kind, mon *model.Minion, reason string, du time.Duration) {
+	bus := as.opt.hooks
+	if bus == nil || mon == nil {
+		return
+	}
+
+	bus.Publish(&hookbus.Event{
+		Kind:      kind,
+		MinionID:  mon.ID,
+		Inet:      inetx.Display(mon.Inet, mon.Inet6),
+		MachineID: mon.MachineID,
+		Semver:    mon.Edition,
+		BrokerID:  as.cur.ID,
+		Duration:  du,
+		Reason:    reason,
+	})
 }
 
 func (as *agentServer) log() *slog.Logger {
@@ -292,16 +618,24 @@ func (as *agentServer) log() *slog.Logger {
 }
 
 type smuxListener struct {
-	sess *smux.Session
+	sess  *smux.Session
+	guard *quota.Guard
 }
 
 func (sl *smuxListener) Accept() (net.Conn, error) {
-	stm, err := sl.sess.AcceptStream()
-	if err != nil {
-		return nil, err
-	}
+	for {
+		stm, err := sl.sess.AcceptStream()
+		if err != nil {
+			return nil, err
+		}
+		// 并发虚拟流超限的直接关闭。
+		if !sl.guard.AllowStream(sl.sess.NumStreams()) {
+			_ = stm.Close()
+			continue
+		}
 
-	return stm, nil
+		return sl.guard.WrapConn(stm), nil
+	}
 }
 
 func (sl *smuxListener) Close() error {
@@ -311,3 +645,8 @@ func (sl *smuxListener) Close() error {
 func (sl *smuxListener) Addr() net.Addr {
 	return sl.sess.LocalAddr()
 }
+
+// authResult 统计认证结果。
+func authResult(code int) {
+	metrics.AgentAuth.With("serverd", metrics.AuthReason(code)).Inc()
+}
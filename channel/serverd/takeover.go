@@ -0,0 +1,125 @@
+package serverd
+
+import (
+	"bufio"
+	"context"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"time"
+
+	"github.com/vela-ssoc/ssoc-broker/library/hookbus"
+	"github.com/vela-ssoc/ssoc-broker/library/inetx"
+	"github.com/vela-ssoc/ssoc-broker/library/takeover"
+	"github.com/vela-ssoc/ssoc-common-mb/dal/model"
+	"github.com/vela-ssoc/ssoc-common/linkhub"
+)
+
+// LivenessChecker 询问 agent 在其它 broker 上的会话是否存活，一般通过中心端询问。
+type LivenessChecker = takeover.Checker
+
+// takeover 数据库中 agent 状态为在线时，检查原会话是否已经失效，
+// 如果已失效则将其驱逐并修改数据库状态为离线，由新会话接管。
+func (as *agentServer) takeover(mon *model.Minion, timeout time.Duration) (bool, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), timeout)
+	defer cancel()
+
+	var alive bool
+	var reason string
+	if mon.BrokerID == as.cur.ID || mon.BrokerID == 0 {
+		alive, reason = as.probeLocal(ctx, mon.ID)
+	} else {
+		var err error
+		if alive, reason, err = takeover.Remote(ctx, as.qry, as.opt.liveness, mon, as.log()); err != nil {
+			return false, err
+		}
+	}
+	if alive {
+		return false, nil
+	}
+	if ok, err := takeover.Evict(ctx, as.qry, mon); err != nil || !ok {
+		return false, err
+	}
+
+	attrs := []any{
+		slog.Int64("agent_id", mon.ID), slog.String("inet", inetx.Display(mon.Inet, mon.Inet6)),
+		slog.Int64("broker_id", mon.BrokerID), slog.String("reason", reason),
+	}
+	as.log().Warn("原会话已失效，由新会话接管", attrs...)
+
+	now := time.Now()
+	evt := &model.Event{
+		MinionID:  mon.ID,
+		Inet:      mon.Inet,
+		Subject:   "节点会话接管",
+		FromCode:  "minion.takeover",
+		Msg:       fmt.Sprintf("原会话（broker: %d）已失效，由新会话接管：%s", mon.BrokerID, reason),
+		Level:     model.ELvlNote,
+		OccurAt:   now,
+		CreatedAt: now,
+	}
+	if exx := as.qry.Event.WithContext(ctx).Create(evt); exx != nil {
+		as.log().Warn("保存会话接管事件出错", "error", exx)
+	}
+	as.publish(hookbus.KindTakeover, mon, evt.Msg, 0)
+
+	return true, nil
+}
+
+// probeLocal 探测本 broker 上的会话是否存活，如果已失效则断开并等待其清理完毕。
+func (as *agentServer) probeLocal(ctx context.Context, id int64) (bool, string) {
+	peer := as.opt.huber.GetByID(id)
+	if peer == nil {
+		return false, "本地不存在该节点会话"
+	}
+
+	err := as.probe(ctx, peer)
+	if err == nil {
+		return true, ""
+	}
+
+	// 旧会话已死，断开并等待其下线流程执行完毕，防止旧会话的清理动作覆盖新会话。
+	_ = peer.Muxer().Close()
+	if val, ok := as.done.Load(peer); ok {
+		select {
+		case <-val.(chan struct{}):
+		case <-ctx.Done():
+		}
+	}
+	// 没有下线流程（如：认证响应发送失败）或等待超时的，直接移出 huber，
+	// 旧会话稍后执行的清理动作发现自己已不在 huber 中就不会再覆盖新会话。
+	as.evict(peer)
+
+	return false, "本地会话探测失败：" + err.Error()
+}
+
+// probe 通过 smux 虚拟流向 agent 发送一个 HTTP 请求，只要能收到响应（即便是 404）就说明会话是通的。
+func (as *agentServer) probe(parent context.Context, peer linkhub.Peer) error {
+	ctx, cancel := context.WithTimeout(parent, takeover.ProbeTimeout)
+	defer cancel()
+
+	stm, err := peer.Muxer().OpenStream()
+	if err != nil {
+		return err
+	}
+	defer stm.Close()
+
+	deadline, _ := ctx.Deadline()
+	_ = stm.SetDeadline(deadline)
+
+	reqURL := linkhub.NewBrokerToAgentIDURL(peer.Info().ID, "/api/v1/agent/ping")
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, reqURL.String(), nil)
+	if err != nil {
+		return err
+	}
+	if err = req.Write(stm); err != nil {
+		return err
+	}
+	res, err := http.ReadResponse(bufio.NewReader(stm), req)
+	if err != nil {
+		return err
+	}
+	_ = res.Body.Close()
+
+	return nil
+}
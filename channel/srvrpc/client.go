@@ -0,0 +1,37 @@
+package srvrpc
+
+import (
+	"context"
+	"net/http"
+
+	"github.com/vela-ssoc/ssoc-broker/channel/srvrpc/srequest"
+	"github.com/vela-ssoc/ssoc-broker/channel/srvrpc/sresponse"
+	"github.com/vela-ssoc/ssoc-broker/library/takeover"
+	"github.com/vela-ssoc/ssoc-common/httpkit"
+	"github.com/vela-ssoc/ssoc-common/linkhub"
+)
+
+// Client broker 调用中心端的接口。
+type Client interface {
+	// AgentLiveness 询问中心端 agent 在指定 broker 上的会话是否存活。
+	AgentLiveness(ctx context.Context, brokerID, agentID int64) (bool, error)
+}
+
+func NewClient(cli httpkit.Client) Client {
+	return &serverClient{cli: cli}
+}
+
+type serverClient struct {
+	cli httpkit.Client
+}
+
+func (sc *serverClient) AgentLiveness(ctx context.Context, brokerID, agentID int64) (bool, error) {
+	reqURL := linkhub.NewBrokerToServerURL(takeover.LivenessPath)
+	req := &srequest.AgentLiveness{BrokerID: brokerID, AgentID: agentID}
+	ret := new(sresponse.AgentLiveness)
+	if err := sc.cli.SendJSON(ctx, http.MethodPost, reqURL.String(), nil, req, ret); err != nil {
+		return false, err
+	}
+
+	return ret.Alive, nil
+}
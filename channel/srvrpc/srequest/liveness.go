@@ -0,0 +1,6 @@
+package srequest
+
+type AgentLiveness struct {
+	BrokerID int64 `json:"broker_id,string"`
+	AgentID  int64 `json:"agent_id,string"`
+}
@@ -0,0 +1,5 @@
+package sresponse
+
+type AgentLiveness struct {
+	Alive bool `json:"alive"`
+}
@@ -1,7 +1,23 @@
 package config
 
+import (
+	"github.com/vela-ssoc/ssoc-broker/app/agtsvc"
+	"github.com/vela-ssoc/ssoc-broker/library/hookbus"
+	"github.com/vela-ssoc/ssoc-broker/library/quota"
+	"github.com/vela-ssoc/ssoc-broker/library/tlstrust"
+)
+
 type Config struct {
-	Secret    string   `json:"secret"    yaml:"secret"    validate:"required"`                    // broker 密钥
-	Semver    string   `json:"semver"    yaml:"semver"    validate:"required"`                    // 版本号，例如：1.2.3-beta
-	Addresses []string `json:"addresses" yaml:"addresses" validate:"gte=1,lte=100,dive,required"` // manager 地址
+	Secret    string                `json:"secret"    yaml:"secret"    validate:"required"`                    // broker 密钥
+	Semver    string                `json:"semver"    yaml:"semver"    validate:"required"`                    // 版本号，例如：1.2.3-beta
+	Addresses []string              `json:"addresses" yaml:"addresses" validate:"gte=1,lte=100,dive,required"` // manager 地址
+	TLS       tlstrust.Config       `json:"tls"       yaml:"tls"`                                              // manager 证书校验配置
+	Capacity  int                   `json:"capacity"  yaml:"capacity"`                                         // agent 容量，达到后就绪检查失败，为 0 代表不限制
+	Metrics   string                `json:"metrics"   yaml:"metrics"`                                          // 指标监听地址，例如：127.0.0.1:9180，为空代表不单独监听
+	Collision string                `json:"collision" yaml:"collision"`                                        // 机器码冲突处理策略：observe、derive、quarantine，默认 observe
+	Strict    bool                  `json:"strict"    yaml:"strict"`                                           // 身份校验严格模式，拒绝没有携带公钥的老版本 agent
+	Quota     *quota.Config         `json:"quota"     yaml:"quota"`                                            // 单个 agent 的资源配额，为空时使用默认配额
+	Scope     string                `json:"scope"     yaml:"scope"`                                            // 中心端调用令牌校验模式：off、audit、enforce，默认 audit（只记录不拒绝）
+	Journal   hookbus.JournalOption `json:"journal"   yaml:"journal"`                                          // 生命周期事件日志的路径、大小与落盘间隔
+	Flap      agtsvc.FlapOption     `json:"flap"      yaml:"flap"`                                             // 节点上下线抖动检测参数，为零的字段使用默认值
 }
@@ -5,18 +5,17 @@ package hideconf
 import (
 	"os"
 
-	"github.com/vela-ssoc/ssoc-common-mb/param/negotiate"
 	"github.com/vela-ssoc/vela-common-mba/ciphertext"
 )
 
 const DevMode = false
 
-func Read(file string) (*negotiate.Hide, error) {
+func Read(file string) (*Hide, error) {
 	if file == "" {
 		file = os.Args[0]
 	}
 
-	hide := new(negotiate.Hide)
+	hide := new(Hide)
 	if err := ciphertext.DecryptFile(file, hide); err != nil {
 		return nil, err
 	}
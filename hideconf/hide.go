@@ -0,0 +1,25 @@
+package hideconf
+
+import (
+	"github.com/vela-ssoc/ssoc-broker/app/agtsvc"
+	"github.com/vela-ssoc/ssoc-broker/bridge/mlink"
+	"github.com/vela-ssoc/ssoc-broker/library/hookbus"
+	"github.com/vela-ssoc/ssoc-broker/library/quota"
+	"github.com/vela-ssoc/ssoc-broker/library/tlstrust"
+	"github.com/vela-ssoc/ssoc-common-mb/param/negotiate"
+)
+
+// Hide 隐写配置，在 negotiate.Hide 的基础上增加了连接中心端的 TLS 校验配置、agent 容量、指标监听地址、机器码冲突处理策略、多播任务参数、身份校验模式、资源配额、中心端调用令牌校验模式、上下线抖动检测参数和生命周期事件日志参数。
+type Hide struct {
+	negotiate.Hide
+	TLS       tlstrust.Config       `json:"tls"`       // 中心端证书校验
+	Capacity  int                   `json:"capacity"`  // agent 容量，达到后就绪检查失败，为 0 代表不限制
+	Metrics   string                `json:"metrics"`   // 指标监听地址，例如：127.0.0.1:9180，为空代表不单独监听
+	Collision string                `json:"collision"` // 机器码冲突处理策略：observe、derive、quarantine，默认 observe
+	Multicast mlink.MulticastOption `json:"multicast"` // 多播任务的并发数、超时时间与保留时长
+	Strict    bool                  `json:"strict"`    // 身份校验严格模式，拒绝没有携带公钥的老版本 agent
+	Quota     *quota.Config         `json:"quota"`     // 单个节点的资源配额，为空时使用默认配额
+	Scope     string                `json:"scope"`     // 中心端调用令牌校验模式：off、audit、enforce，默认 audit（只记录不拒绝）
+	Flap      agtsvc.FlapOption     `json:"flap"`      // 节点上下线抖动检测参数，为零的字段使用默认值
+	Journal   hookbus.JournalOption `json:"journal"`   // 生命周期事件日志的路径、大小与落盘间隔
+}
@@ -7,13 +7,12 @@ import (
 	"os"
 
 	"github.com/vela-ssoc/ssoc-common-mb/jsonc"
-	"github.com/vela-ssoc/ssoc-common-mb/param/negotiate"
 )
 
 const DevMode = true
 
-func Read(file string) (*negotiate.Hide, error) {
-	hide := new(negotiate.Hide)
+func Read(file string) (*Hide, error) {
+	hide := new(Hide)
 	if file != "" {
 		if err := unmarshalJSONC(file, hide); err != nil {
 			return nil, err
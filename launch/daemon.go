@@ -6,8 +6,13 @@ import (
 	"log/slog"
 	"net"
 	"net/http"
+	"sync/atomic"
+	"time"
 
+	"github.com/vela-ssoc/ssoc-broker/app/mgtsvc"
 	"github.com/vela-ssoc/ssoc-broker/bridge/telecom"
+	"github.com/vela-ssoc/ssoc-broker/library/health"
+	"github.com/vela-ssoc/ssoc-broker/library/outbox"
 	"github.com/vela-ssoc/ssoc-common-mb/param/negotiate"
 	"github.com/vela-ssoc/ssoc-common-mb/prereadtls"
 )
@@ -18,6 +23,7 @@ type daemonServer struct {
 	handler http.Handler    // handler
 	server  *http.Server    // HTTP 服务
 	errCh   chan<- error    // 错误输出
+	serving atomic.Bool     // 是否正在监听服务
 }
 
 func (ds *daemonServer) Run() {
@@ -30,6 +36,8 @@ func (ds *daemonServer) Run() {
 	}
 	//goland:noinspection GoUnhandledErrorResult
 	defer lis.Close()
+	ds.serving.Store(true)
+	defer ds.serving.Store(false)
 
 	tcpSrv := &http.Server{Handler: ds.handler}
 
@@ -61,6 +69,11 @@ func (ds *daemonServer) Run() {
 	ds.errCh <- prereadtls.Serve(lis, tcpFunc, tlsFunc)
 }
 
+// Status 监听状态，用于健康检查。
+func (ds *daemonServer) Status() health.ListenerStatus {
+	return health.ListenerStatus{Serving: ds.serving.Load(), Addr: ds.issue.Server.Addr}
+}
+
 func (ds *daemonServer) Close() error {
 	if srv := ds.server; srv != nil {
 		return srv.Close()
@@ -71,6 +84,8 @@ func (ds *daemonServer) Close() error {
 type daemonClient struct {
 	link    telecom.Linker
 	handler http.Handler
+	boot    mgtsvc.BootConfigService
+	outbox  *outbox.Transport
 	server  *http.Server
 	errCh   chan<- error
 	log     *slog.Logger
@@ -93,6 +108,18 @@ func (dc *daemonClient) Run() {
 			break
 		}
 		dc.log.Info("重新连接中心端成功")
+		dc.reload()
+		go dc.outbox.Replay(dc.parent)
+	}
+}
+
+// reload 重连后中心端可能下发了新的日志与数据库配置。
+func (dc *daemonClient) reload() {
+	issue := dc.link.Issue()
+	ctx, cancel := context.WithTimeout(dc.parent, 30*time.Second)
+	defer cancel()
+	if err := dc.boot.Reload(ctx, issue.Logger, issue.Database); err != nil {
+		dc.log.Error("重连后热加载启动配置失败", slog.Any("error", err))
 	}
 }
 
@@ -113,6 +140,10 @@ func (od *onlyDeploy) ServeHTTP(w http.ResponseWriter, r *http.Request) {
 		"/api/v1/deploy/minion/":          {},
 		"/api/v1/deploy/minion/download":  {},
 		"/api/v1/deploy/minion/download/": {},
+		"/healthz":                        {},
+		"/readyz":                         {},
+		"/local/healthz":                  {},
+		"/local/readyz":                   {},
 	}
 	path := r.URL.Path
 	if _, allow := allows[path]; allow {
@@ -121,3 +152,26 @@ func (od *onlyDeploy) ServeHTTP(w http.ResponseWriter, r *http.Request) {
 		w.WriteHeader(http.StatusUpgradeRequired)
 	}
 }
+
+// managerStatus 根据心跳与拨号信息生成中心端通道状态，用于健康检查。
+func managerStatus(link telecom.Linker) health.ManagerStatus {
+	beat := link.Heartbeat()
+	st := health.ManagerStatus{
+		Connected: beat.Healthy(),
+		State:     "disconnected",
+		RTTMS:     health.Millis(beat.RTT),
+		SkewMS:    health.Millis(beat.Skew),
+		LastError: beat.LastError,
+	}
+	if st.Connected {
+		st.State = "connected"
+	}
+	for _, ah := range link.Health() {
+		if ah.Current {
+			st.Addr = ah.Addr
+			break
+		}
+	}
+
+	return st
+}
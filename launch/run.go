@@ -2,6 +2,7 @@ package launch
 
 import (
 	"context"
+	"errors"
 	"log/slog"
 	"net/http"
 	"os"
@@ -20,7 +21,18 @@ import (
 	"github.com/vela-ssoc/ssoc-broker/bridge/mlink"
 	"github.com/vela-ssoc/ssoc-broker/bridge/telecom"
 	"github.com/vela-ssoc/ssoc-broker/foreign/bytedance"
+	"github.com/vela-ssoc/ssoc-broker/hideconf"
+	"github.com/vela-ssoc/ssoc-broker/library/admission"
+	"github.com/vela-ssoc/ssoc-broker/library/collision"
+	"github.com/vela-ssoc/ssoc-broker/library/handshake"
+	"github.com/vela-ssoc/ssoc-broker/library/health"
+	"github.com/vela-ssoc/ssoc-broker/library/hookbus"
+	"github.com/vela-ssoc/ssoc-broker/library/hotdb"
+	"github.com/vela-ssoc/ssoc-broker/library/metrics"
+	"github.com/vela-ssoc/ssoc-broker/library/outbox"
 	"github.com/vela-ssoc/ssoc-broker/library/pipelog"
+	"github.com/vela-ssoc/ssoc-broker/library/scopeauth"
+	"github.com/vela-ssoc/ssoc-broker/library/tlstrust"
 	"github.com/vela-ssoc/ssoc-common-mb/accord"
 	"github.com/vela-ssoc/ssoc-common-mb/dal/gridfs"
 	"github.com/vela-ssoc/ssoc-common-mb/dal/query"
@@ -32,7 +44,6 @@ import (
 	"github.com/vela-ssoc/ssoc-common-mb/integration/ntfmatch"
 	"github.com/vela-ssoc/ssoc-common-mb/integration/sonatype"
 	"github.com/vela-ssoc/ssoc-common-mb/integration/vulnsync"
-	"github.com/vela-ssoc/ssoc-common-mb/param/negotiate"
 	"github.com/vela-ssoc/ssoc-common-mb/problem"
 	"github.com/vela-ssoc/ssoc-common-mb/shipx"
 	"github.com/vela-ssoc/ssoc-common-mb/sqldb"
@@ -48,7 +59,7 @@ import (
 // Run 运行服务
 //
 //goland:noinspection GoUnhandledErrorResult
-func Run(parent context.Context, hide *negotiate.Hide) error {
+func Run(parent context.Context, hide *hideconf.Hide) error {
 	// 项目启动时默认初始化一个日志输出，方便启动前调试。
 	logLevel := new(slog.LevelVar)
 	logLevel.Set(slog.LevelDebug)
@@ -58,7 +69,14 @@ func Run(parent context.Context, hide *negotiate.Hide) error {
 	log := slog.New(logHandler)
 	log.Info("日志组件初始化完毕")
 
-	link, err := telecom.Dial(parent, hide, log) // 与中心端建立连接
+	trust, err := tlstrust.New(hide.TLS)
+	if err != nil {
+		return err
+	}
+	if trust.Insecure() {
+		log.Warn("已关闭中心端 TLS 证书校验，仅限实验环境使用")
+	}
+	link, err := telecom.Dial(parent, &hide.Hide, trust, log) // 与中心端建立连接
 	if err != nil {
 		return err
 	}
@@ -67,30 +85,26 @@ func Run(parent context.Context, hide *negotiate.Hide) error {
 	issue := link.Issue()
 	log.Info("broker接入认证成功", slog.Any("ident", ident), slog.Any("issue", issue))
 
-	logCfg := issue.Logger
-	defer logCfg.Close()
-
-	log.Info("日志组件初始化完毕")
-
-	dbCfg := issue.Database
-
 	gormLog := logger.NewGorm(logHandler, gormlogger.Config{LogLevel: gormlogger.Info})
 	gormCfg := &gorm.Config{Logger: gormLog}
-	db, err := sqldb.Open(dbCfg.DSN, gormCfg)
+	dbOpener := func(dsn string) (*gorm.DB, error) { return sqldb.Open(dsn, gormCfg) }
+	hdb, err := hotdb.Open(mgtsvc.DatabaseConfig(issue.Database), dbOpener, log)
 	if err != nil {
 		return err
 	}
-	sdb, err := db.DB()
-	if err != nil {
+	//goland:noinspection GoUnhandledErrorResult
+	defer hdb.Close() // 程序结束时断开数据库连接。
+	// 热加载只替换底层的 *sql.DB，插件注册在不变的 *gorm.DB 上即可一直生效。
+	if err = hdb.Gorm().Use(metrics.GormPlugin{}); err != nil {
 		return err
 	}
-	//goland:noinspection GoUnhandledErrorResult
-	defer sdb.Close() // 程序结束时断开数据库连接。
 
-	sdb.SetMaxOpenConns(dbCfg.MaxOpenConn)
-	sdb.SetMaxIdleConns(dbCfg.MaxIdleConn)
-	sdb.SetConnMaxLifetime(dbCfg.MaxLifeTime.Duration())
-	sdb.SetConnMaxIdleTime(dbCfg.MaxIdleTime.Duration())
+	// 日志与数据库配置支持中心端推送或重连后热加载。
+	bootConfigService := mgtsvc.BootConfig(hdb, logHandler, issue.Logger, log)
+	defer bootConfigService.Close()
+	log.Info("日志组件初始化完毕")
+
+	db := hdb.Gorm()
 	log.Warn("当前数据库类型", slog.String("dialect", db.Dialector.Name()))
 
 	qry := query.Use(db)
@@ -100,11 +114,17 @@ func Run(parent context.Context, hide *negotiate.Hide) error {
 	match := ntfmatch.NewMatch(qry)
 	store := storage.NewStore(qry)
 
-	tunCli := &http.Client{
-		Transport: &http.Transport{
-			DialContext: link.DialContext,
-		},
+	// 发往中心端的单向消息（dong 推送）在断线期间写入 outbox，重连后或定期按序重放。
+	// 只有不关心响应内容的客户端才能使用 outboxTransport，SIEM 代理等需要响应的请求直连中心端。
+	box, err := outbox.Open("resources/outbox", outbox.Option{})
+	if err != nil {
+		return err
 	}
+	defer box.Close()
+	outboxTransport := outbox.NewTransport(box, link.DialContext, log)
+	go outboxTransport.Run(parent, time.Minute) // 重放上次运行时积压的消息，之后定期重试
+
+	tunCli := &http.Client{Transport: outboxTransport}
 	dongCli := dong.NewTunnel(tunCli, log)
 	devopsCfg := devops.NewConfig(store)
 	devCli := devops.NewClient(devopsCfg, cli)
@@ -130,8 +150,11 @@ func Run(parent context.Context, hide *negotiate.Hide) error {
 	agt.HandleError = pbh.HandleError
 	agt.Validator = valid
 
-	mv1 := mgt.Group(accord.PathPrefix).Use(middle.Oplog)
-	av1 := agt.Group(accord.PathPrefix).Use(middle.Oplog)
+	// 中心端调用需携带签名令牌，按路由声明的授权范围校验，校验模式见配置项 scope。
+	mgtAuth := scopeauth.New(hide.Secret, ident.ID, scopeauth.Option{})
+	mgtScope := middle.ManagerScope(mgtAuth, scopeauth.Mode(hide.Scope), log)
+	mv1 := mgt.Group(accord.PathPrefix).Use(middle.Metrics("manager"), middle.Oplog, mgtScope)
+	av1 := agt.Group(accord.PathPrefix).Use(middle.Metrics("agent"), middle.Oplog, middle.AgentStat, middle.AgentQuota)
 
 	esCfg := elastic.NewConfigure(qry, name)
 	esc := elastic.NewSearch(esCfg, cli)
@@ -143,9 +166,40 @@ func Run(parent context.Context, hide *negotiate.Hide) error {
 	vsync := vulnsync.New(db, sonaCli)
 	_ = vsync
 
-	nodeEventService := agtsvc.Phase(cmdbCli, alert, log)
-	hub := mlink.LinkHub(qry, link, agt, nodeEventService, log)
+	nodeEventService := agtsvc.Phase(cmdbCli, alert, hide.Flap, log)
+	hookBus := hookbus.New(log)
+	defer func() {
+		cctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+		defer cancel()
+		if exx := hookBus.Close(cctx); exx != nil {
+			log.Warn("关闭生命周期事件总线超时", slog.Any("error", exx))
+		}
+	}()
+	if journal, exx := hookbus.NewJournal(hide.Journal); exx != nil {
+		log.Warn("创建生命周期事件日志出错", slog.Any("error", exx))
+	} else {
+		hookBus.Subscribe(journal)
+	}
+	if webhooks, exx := hookbus.LoadWebhooks(parent, qry, &http.Client{Timeout: time.Minute}); exx != nil {
+		log.Warn("加载生命周期事件 webhook 出错", slog.Any("error", exx))
+	} else {
+		for _, wh := range webhooks {
+			hookBus.Subscribe(wh)
+		}
+	}
+	phaser := mlink.Phasers(nodeEventService, mlink.HookPhaser(hookBus, ident.ID))
+	hub := mlink.LinkHub(qry, link, agt, phaser, log)
 	_ = hub.ResetDB()
+	if err = hub.Collision().SetPolicy(collision.Policy(hide.Collision)); err != nil {
+		log.Warn("机器码冲突处理策略无效，使用默认策略", slog.String("collision", hide.Collision), slog.Any("error", err))
+	}
+	metrics.AgentsConnected.With("mlink").Func(func() float64 { return float64(len(hub.ConnectIDs())) })
+	hub.SetMulticast(hide.Multicast)
+	hub.Identity().SetStrict(hide.Strict)
+	if hide.Quota != nil {
+		hub.SetQuota(*hide.Quota)
+	}
+	metrics.AgentsCapacity.With().Set(float64(hide.Capacity))
 
 	const consoleDir = "resources/agent/console"
 	if err = os.MkdirAll(consoleDir, 0o777); err != nil {
@@ -170,10 +224,32 @@ func Run(parent context.Context, hide *negotiate.Hide) error {
 		resetREST := mgtapi.Reset(store, esCfg, match)
 		resetREST.Route(mv1)
 
-		pprofREST := mgtapi.Pprof(link)
+		pprofREST := mgtapi.Pprof(link, box)
 		pprofREST.Route(mv1)
 
-		systemSvc := mservice.NewSystem(link, qry, gfs, log)
+		tlsTrustREST := mgtapi.TLSTrust(trust)
+		tlsTrustREST.Route(mv1)
+
+		bootConfigREST := mgtapi.BootConfig(bootConfigService)
+		bootConfigREST.Route(mv1)
+
+		sessionService := mgtsvc.Session(hub)
+		sessionREST := mgtapi.Session(sessionService)
+		sessionREST.Route(mv1)
+
+		multicastREST := mgtapi.Multicast(hub)
+		multicastREST.Route(mv1)
+
+		quarantineREST := mgtapi.Quarantine(hub.Identity())
+		quarantineREST.Route(mv1)
+
+		collisionREST := mgtapi.Collision(hub.Collision())
+		collisionREST.Route(mv1)
+
+		handshakeREST := mgtapi.Handshake(handshake.Default)
+		handshakeREST.Route(mv1)
+
+		systemSvc := mservice.NewSystem(link, hub, qry, gfs, log)
 		taskSvc := mservice.NewTask(qry, hub, log)
 		routers := []shipx.RouteBinder{
 			mrestapi.NewSystem(systemSvc),
@@ -211,7 +287,7 @@ func Run(parent context.Context, hide *negotiate.Hide) error {
 		heartREST := agtapi.Heart(qry)
 		heartREST.Route(av1)
 
-		proxyAPI := agtapi.Proxy(link.DialContext)
+		proxyAPI := agtapi.Proxy(&http.Transport{DialContext: link.DialContext})
 		proxyAPI.Route(av1)
 
 		securityREST := agtapi.Security(qry)
@@ -242,11 +318,22 @@ func Run(parent context.Context, hide *negotiate.Hide) error {
 
 	oldHandler := linkhub.New(db, qry, link, log, gfs)
 	temp := temporary.REST(oldHandler, valid, log)
-	gw := gateway.New(hub, valid)
+	gw := gateway.New(hub, valid, admission.New(admission.Option{}))
 	deployService := agtsvc.Deploy(qry, store, gfs, ident.ID)
 	deployAPI := agtapi.Deploy(deployService)
 
+	errCh := make(chan error, 1)
 	mux := ship.Default()
+	// 监听本地端口用于 minion 节点连接
+	ds := &daemonServer{issue: issue, hide: &hide.Hide, handler: mux, errCh: errCh}
+	probe := health.New(health.Option{
+		Ping:     hdb.Ping,
+		Manager:  func() health.ManagerStatus { return managerStatus(link) },
+		Listener: ds.Status,
+		Online:   func() int { return len(hub.ConnectIDs()) },
+		Draining: hub.Draining,
+		Capacity: hide.Capacity,
+	})
 	api := mux.Group("/")
 	api.Route("/api/v1/minion").CONNECT(func(c *ship.Context) error {
 		gw.ServeHTTP(c.ResponseWriter(), c.Request())
@@ -256,6 +343,10 @@ func Run(parent context.Context, hide *negotiate.Hide) error {
 	api.Route("/v1/edition/upgrade").GET(oldHandler.Upgrade)
 	api.Route("/api/v1/deploy/minion").GET(deployAPI.Script)
 	api.Route("/api/v1/deploy/minion/download").GET(deployAPI.MinionDownload)
+	api.Route("/healthz").GET(ship.FromHTTPHandlerFunc(probe.Healthz))
+	api.Route("/readyz").GET(ship.FromHTTPHandlerFunc(probe.Readyz))
+	api.Route("/local/healthz").GET(ship.FromHTTPHandlerFunc(probe.LocalHealthz))
+	api.Route("/local/readyz").GET(ship.FromHTTPHandlerFunc(probe.LocalReadyz))
 	{
 		routes := []shipx.RouteBinder{}
 		baseAPI := mux.Group("/api/v1")
@@ -264,13 +355,19 @@ func Run(parent context.Context, hide *negotiate.Hide) error {
 		}
 	}
 
-	errCh := make(chan error, 1)
-	// 监听本地端口用于 minion 节点连接
-	ds := &daemonServer{issue: issue, hide: hide, handler: mux, errCh: errCh}
 	go ds.Run()
+	if addr := hide.Metrics; addr != "" {
+		msrv := metrics.NewServer(addr, metrics.Default)
+		defer msrv.Close()
+		go func() {
+			if exx := msrv.ListenAndServe(); exx != nil && !errors.Is(exx, http.ErrServerClosed) {
+				log.Warn("指标监听服务出错", slog.String("addr", addr), slog.Any("error", exx))
+			}
+		}()
+	}
 
 	// 连接 manager 的客户端，保持在线与接受指令
-	dc := &daemonClient{link: link, handler: mgt, errCh: errCh, log: log, parent: parent}
+	dc := &daemonClient{link: link, handler: mgt, boot: bootConfigService, outbox: outboxTransport, errCh: errCh, log: log, parent: parent}
 	go dc.Run()
 
 	select {
@@ -278,9 +375,20 @@ func Run(parent context.Context, hide *negotiate.Hide) error {
 	case <-parent.Done():
 	}
 
+	// 先分批释放节点再关闭监听，防止节点蜂拥重连到其它 broker。
+	dctx, dcancel := context.WithTimeout(context.Background(), 2*time.Minute)
+	exx := hub.Drain(dctx, mlink.DrainOption{})
+	dcancel()
+
 	_ = ds.Close()
 	_ = dc.Close()
-	_ = hub.ResetDB()
+	// 排空成功时已经重置过节点状态，已被 manager 排空过的直接重置即可。
+	if exx != nil {
+		if !errors.Is(exx, mlink.ErrBrokerDraining) {
+			log.Warn("排空节点出错", slog.Any("error", exx))
+		}
+		_ = hub.ResetDB()
+	}
 
 	return err
 }
@@ -0,0 +1,154 @@
+package launch2
+
+import (
+	"context"
+	"log/slog"
+	"net"
+	"net/http"
+	"os"
+	"time"
+
+	"github.com/vela-ssoc/ssoc-broker/app/agtapi"
+	"github.com/vela-ssoc/ssoc-broker/app/agtsvc"
+	"github.com/vela-ssoc/ssoc-broker/app/mgtsvc"
+	"github.com/vela-ssoc/ssoc-broker/app/middle"
+	agtrestapi "github.com/vela-ssoc/ssoc-broker/application/agent/restapi"
+	agtservice "github.com/vela-ssoc/ssoc-broker/application/agent/service"
+	"github.com/vela-ssoc/ssoc-broker/channel/agtrpc"
+	"github.com/vela-ssoc/ssoc-broker/channel/clientd"
+	"github.com/vela-ssoc/ssoc-broker/foreign/bytedance"
+	"github.com/vela-ssoc/ssoc-broker/library/metrics"
+	"github.com/vela-ssoc/ssoc-broker/library/pipelog"
+	"github.com/vela-ssoc/ssoc-common-mb/accord"
+	"github.com/vela-ssoc/ssoc-common-mb/dal/gridfs"
+	"github.com/vela-ssoc/ssoc-common-mb/dal/model"
+	"github.com/vela-ssoc/ssoc-common-mb/dal/query"
+	"github.com/vela-ssoc/ssoc-common-mb/gopool"
+	"github.com/vela-ssoc/ssoc-common-mb/integration/alarm"
+	"github.com/vela-ssoc/ssoc-common-mb/integration/cmdb"
+	"github.com/vela-ssoc/ssoc-common-mb/integration/devops"
+	"github.com/vela-ssoc/ssoc-common-mb/integration/dong/v2"
+	"github.com/vela-ssoc/ssoc-common-mb/integration/elastic"
+	"github.com/vela-ssoc/ssoc-common-mb/integration/ntfmatch"
+	"github.com/vela-ssoc/ssoc-common-mb/storage/v2"
+	"github.com/vela-ssoc/ssoc-common/shipx"
+	"github.com/vela-ssoc/vela-common-mba/netutil"
+	"github.com/xgfone/ship/v5"
+)
+
+const (
+	agentConsoleDir = "resources/agent/console"
+	agentCDNDir     = "resources/cdn"
+)
+
+// agentDeps agent 业务接口与上下线通知共用的依赖。
+type agentDeps struct {
+	qry              *query.Query
+	this             *model.Broker
+	cli              netutil.HTTPClient
+	gfs              gridfs.FS
+	alert            alarm.Alerter
+	cmdbc            cmdb.Client
+	esc              elastic.Searcher
+	managerTransport http.RoundTripper
+	taskRsync        mgtsvc.TaskRsyncer
+	log              *slog.Logger
+}
+
+func newAgentDeps(qry *query.Query, this *model.Broker, mux clientd.Muxer, agentClient agtrpc.Client, log *slog.Logger) *agentDeps {
+	cli := netutil.NewClient()
+	store := storage.NewStore(qry)
+	match := ntfmatch.NewMatch(qry)
+
+	// 发往中心端的请求（告警、SIEM 代理）都走 manager 通道，不区分 Host。
+	managerTransport := &http.Transport{
+		DialContext: func(ctx context.Context, _, _ string) (net.Conn, error) {
+			return mux.OpenConn(ctx)
+		},
+		MaxIdleConns:    10,
+		IdleConnTimeout: time.Minute,
+	}
+	dongCli := dong.NewTunnel(&http.Client{Transport: managerTransport}, log)
+	devCli := devops.NewClient(devops.NewConfig(store), cli)
+	esCfg := elastic.NewConfigure(qry, this.Name)
+	// 配置同步通过 agtrpc 调用 agent，同步逻辑与旧通道共用。
+	taskRsync := mgtsvc.TaskRsync(qry, agentUnicaster{cli: agentClient}, mgtsvc.Minion(qry))
+
+	return &agentDeps{
+		qry:              qry,
+		this:             this,
+		cli:              cli,
+		gfs:              gridfs.NewCache(qry, agentCDNDir),
+		alert:            alarm.UnifyAlerter(store, match, log, dongCli, devCli, qry),
+		cmdbc:            cmdb.NewClient(qry, cmdb.NewConfigure(store), cli),
+		esc:              elastic.NewSearch(esCfg, cli),
+		managerTransport: managerTransport,
+		taskRsync:        taskRsync,
+		log:              log,
+	}
+}
+
+// bindAgentAPI 在新通道上注册 agent 业务接口，与旧通道（launch.Run）注册的 app/agtapi 保持一致。
+//
+// app/agtapi 通过 mlink.Ctx 获取节点信息，由 serverdInfer 中间件将新通道的节点信息放入请求上下文。
+func bindAgentAPI(h *ship.Ship, deps *agentDeps) error {
+	if err := os.MkdirAll(agentConsoleDir, 0o777); err != nil {
+		return err
+	}
+	pipeFS := pipelog.NewFS(agentConsoleDir, 10*1024*1024, time.Minute)
+
+	qry, this, log := deps.qry, deps.this, deps.log
+	cli, gfs, esc := deps.cli, deps.gfs, deps.esc
+	rsyncPool := metrics.WrapPool("rsync", 64, gopool.New(64))
+	rsync := agtsvc.PoolRsync(deps.taskRsync, rsyncPool, log)
+
+	// 配额已由 serverd 在 HTTP 层统一限制（quota.Handler），这里不再重复挂载 middle.AgentQuota。
+	av1 := h.Group(accord.PathPrefix).Use(serverdInfer, middle.Metrics("agent"), middle.Oplog, middle.AgentStat)
+	{
+		// 控制台日志使用新通道原生的实现，不再注册 agtapi.NewAgentConsole。
+		routes := []shipx.RouteBinder{
+			agtrestapi.NewPing(),
+			agtrestapi.NewAgentConsole(pipeFS),
+		}
+		if err := shipx.BindRoutes(av1, routes); err != nil {
+			return err
+		}
+	}
+
+	agentEmergencySnapshotSvc := agtsvc.NewAgentEmergencySnapshot(qry, log)
+	agtapi.NewAgentEmergencySnapshot(agentEmergencySnapshotSvc).Route(av1)
+	agtapi.Audit(deps.alert).Route(av1)
+	agtapi.BPF().Route(av1)
+	agtapi.Collect(qry, agtsvc.NewCollect(qry)).Route(av1)
+	agtapi.Elastic(esc).Route(av1)
+	agtapi.Reverse(bytedance.ElkeidFS("resources/elkeid/", cli)).Route(av1)
+	agtapi.Heart(qry).Route(av1)
+	agtapi.Proxy(deps.managerTransport).Route(av1)
+	agtapi.Security(qry).Route(av1)
+	agtapi.Stream(this.Name, esc).Route(av1)
+	agtapi.Tag(agtsvc.Tag(qry, rsync)).Route(av1)
+	agtapi.Task(qry).Route(av1)
+	agtapi.Third(agtsvc.NewThird(qry, gfs)).Route(av1)
+	agtapi.Upgrade(qry, this.ID, gfs).Route(av1)
+	agtapi.Shared(agtsvc.SharedStrings(qry)).Route(av1)
+
+	return nil
+}
+
+// agentUnicaster 通过 agtrpc 调用单个 agent，实现 mgtsvc.Unicaster。
+type agentUnicaster struct {
+	cli agtrpc.Client
+}
+
+func (au agentUnicaster) Unicast(ctx context.Context, id int64, path string, body, resp any) error {
+	return au.cli.Operator(id).Call(ctx, path, body, resp)
+}
+
+// newAgentNotifier 新通道的 agent 上下线通知。
+func newAgentNotifier(deps *agentDeps, agentClient agtrpc.Client, flap agtsvc.FlapOption) *agtservice.AgentNotifier {
+	const workers = 256
+	pool := metrics.WrapPool("notifier", workers, gopool.New(workers))
+	events := agtsvc.NodeEvent(deps.alert, flap, deps.log)
+
+	return agtservice.NewAgentNotifier(deps.qry, agentClient, deps.taskRsync, events, deps.cmdbc, pool, workers, deps.log)
+}
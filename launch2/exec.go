@@ -3,17 +3,33 @@ package launch2
 import (
 	"context"
 	"crypto/tls"
+	"errors"
 	"log/slog"
 	"net"
 	"net/http"
 	"os"
+	"sync/atomic"
 	"time"
 
+	"github.com/vela-ssoc/ssoc-broker/app/middle"
 	"github.com/vela-ssoc/ssoc-broker/application/current"
 	expresetapi "github.com/vela-ssoc/ssoc-broker/application/expose/restapi"
+	mgtrestapi "github.com/vela-ssoc/ssoc-broker/application/manager/restapi"
+	mgtservice "github.com/vela-ssoc/ssoc-broker/application/manager/service"
+	"github.com/vela-ssoc/ssoc-broker/channel/agtrpc"
 	"github.com/vela-ssoc/ssoc-broker/channel/clientd"
 	"github.com/vela-ssoc/ssoc-broker/channel/serverd"
+	"github.com/vela-ssoc/ssoc-broker/channel/srvrpc"
 	"github.com/vela-ssoc/ssoc-broker/config"
+	"github.com/vela-ssoc/ssoc-broker/library/collision"
+	"github.com/vela-ssoc/ssoc-broker/library/drain"
+	"github.com/vela-ssoc/ssoc-broker/library/handshake"
+	"github.com/vela-ssoc/ssoc-broker/library/health"
+	"github.com/vela-ssoc/ssoc-broker/library/hookbus"
+	"github.com/vela-ssoc/ssoc-broker/library/hotdb"
+	"github.com/vela-ssoc/ssoc-broker/library/identity"
+	"github.com/vela-ssoc/ssoc-broker/library/metrics"
+	"github.com/vela-ssoc/ssoc-broker/library/scopeauth"
 	"github.com/vela-ssoc/ssoc-common-mb/dal/query"
 	"github.com/vela-ssoc/ssoc-common/httpkit"
 	"github.com/vela-ssoc/ssoc-common/linkhub"
@@ -84,7 +100,7 @@ func exec(ctx context.Context, pld profile.Reader[config.Config]) error {
 		managerHandler.HandleError = shipx.HandleError
 	}
 
-	clientdCfg := clientd.Config{Secret: cfg.Secret, Semver: cfg.Semver, Addresses: cfg.Addresses}
+	clientdCfg := clientd.Config{Secret: cfg.Secret, Semver: cfg.Semver, Addresses: cfg.Addresses, TLS: cfg.TLS}
 	clientdOpt := clientd.Options{Handler: managerHandler, Logger: log, Timeout: 10 * time.Second}
 	mux, err := clientd.Open(ctx, clientdCfg, clientdOpt)
 	if err != nil {
@@ -106,25 +122,28 @@ func exec(ctx context.Context, pld profile.Reader[config.Config]) error {
 		LogLevel:                  gormlogger.Info,
 	}
 	gormLog := logger.NewGorm(logh, gormLogCfg)
-	db, err := sqldb.Open(bootConfig.DSN, &gorm.Config{Logger: gormLog})
+	dbOpener := func(dsn string) (*gorm.DB, error) {
+		return sqldb.Open(dsn, &gorm.Config{Logger: gormLog})
+	}
+	hdb, err := hotdb.Open(mgtservice.DatabaseConfig(bootConfig), dbOpener, log)
 	if err != nil {
 		log.Error("连接数据错误", "error", err)
 		return err
 	}
-	if sdb, err1 := db.DB(); err1 != nil {
-		log.Error("获取数据库底层连接错误", "error", err1)
-		return err1
-	} else {
-		//goland:noinspection GoUnhandledErrorResult
-		defer sdb.Close()
-		sdb.SetConnMaxLifetime(bootConfig.MaxLifeTime)
-		sdb.SetMaxIdleConns(bootConfig.MaxIdleConn)
-		sdb.SetMaxOpenConns(bootConfig.MaxOpenConn)
-		sdb.SetConnMaxLifetime(bootConfig.MaxLifeTime)
+	//goland:noinspection GoUnhandledErrorResult
+	defer hdb.Close()
+	// 热加载只替换底层的 *sql.DB，插件注册在不变的 *gorm.DB 上即可一直生效。
+	if err = hdb.Gorm().Use(metrics.GormPlugin{}); err != nil {
+		return err
 	}
+	db := hdb.Gorm()
 	qry := query.Use(db)
 	log.Info("数据库连接成功", "dialect", db.Dialector.Name())
 
+	// 中心端下发的启动配置变化时（重连或主动推送）热加载，无需重启 broker。
+	bootConfigSvc := mgtservice.NewBootConfig(mux, hdb, log)
+	mux.Watch(bootConfigSvc.Watch)
+
 	currentBrokerSvc := current.NewBroker(cfg.Secret, qry, log)
 	this, err := currentBrokerSvc.Load(ctx)
 	if err != nil {
@@ -152,16 +171,93 @@ func exec(ctx context.Context, pld profile.Reader[config.Config]) error {
 		IdleConnTimeout:       time.Minute,
 		ResponseHeaderTimeout: time.Minute,
 	}}
-	_ = httpkit.NewClient(multiHTTP)
+	agentClient := agtrpc.NewClient(httpkit.NewClient(multiHTTP))
+	serverClient := srvrpc.NewClient(httpkit.NewClient(multiHTTP))
 
-	{
-		serverdOpt := serverd.NewOption().
-			Logger(log).
-			Handler(agentHandler).
-			Valid(valid.Validate).
-			Huber(huber)
-		agentTunnelServer := serverd.New(qry, this, serverdOpt)
+	agentDeps := newAgentDeps(qry, this, mux, agentClient, log)
+	if err = bindAgentAPI(agentHandler, agentDeps); err != nil {
+		log.Error("路由注册错误（agent）", "error", err)
+		return err
+	}
 
+	agentVerifier := identity.New(qry, log)
+	agentVerifier.SetStrict(cfg.Strict)
+	agentCollision := collision.New(qry, collision.Option{}, log)
+	if err = agentCollision.SetPolicy(collision.Policy(cfg.Collision)); err != nil {
+		log.Warn("机器码冲突处理策略无效，使用默认策略", "collision", cfg.Collision, "error", err)
+	}
+	hookBus := hookbus.New(log)
+	defer func() {
+		cctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+		defer cancel()
+		if exx := hookBus.Close(cctx); exx != nil {
+			log.Warn("关闭生命周期事件总线超时", "error", exx)
+		}
+	}()
+	if journal, exx := hookbus.NewJournal(cfg.Journal); exx != nil {
+		log.Warn("创建生命周期事件日志出错", "error", exx)
+	} else {
+		hookBus.Subscribe(journal)
+	}
+	if webhooks, exx := hookbus.LoadWebhooks(ctx, qry, &http.Client{Timeout: time.Minute}); exx != nil {
+		log.Warn("加载生命周期事件 webhook 出错", "error", exx)
+	} else {
+		for _, wh := range webhooks {
+			hookBus.Subscribe(wh)
+		}
+	}
+	agentNotifier := newAgentNotifier(agentDeps, agentClient, cfg.Flap)
+	go agentNotifier.Run(ctx, 30*time.Second) // 重试被丢弃的 startup 推送
+	serverdOpt := serverd.NewOption().
+		Logger(log).
+		Handler(agentHandler).
+		Valid(valid.Validate).
+		Huber(huber).
+		AgentNotifier(agentNotifier).
+		Liveness(newLivenessGate(mux, serverClient)).
+		Identity(agentVerifier).
+		Collision(agentCollision).
+		Hooks(hookBus)
+	if cfg.Quota != nil {
+		serverdOpt = serverdOpt.Quota(*cfg.Quota)
+	}
+	agentTunnelServer := serverd.New(qry, this, serverdOpt)
+	drainSvc := mgtservice.NewDrain(agentTunnelServer, currentBrokerSvc, agentClient, log)
+	var listening atomic.Bool
+	listenerStatus := func() health.ListenerStatus {
+		return health.ListenerStatus{Serving: listening.Load(), Addr: this.Bind}
+	}
+	probe := health.New(health.Option{
+		Ping:     hdb.Ping,
+		Manager:  func() health.ManagerStatus { return managerStatus(mux) },
+		Listener: listenerStatus,
+		Online:   func() int { return len(huber.All()) },
+		Draining: agentTunnelServer.Draining,
+		Capacity: cfg.Capacity,
+	})
+	healthAPI := expresetapi.NewHealth(probe)
+	metrics.AgentsConnected.With("serverd").Func(func() float64 { return float64(len(huber.All())) })
+	metrics.AgentsCapacity.With().Set(float64(cfg.Capacity))
+	{
+		routes := []shipx.RouteBinder{
+			mgtrestapi.NewDrain(drainSvc),
+			mgtrestapi.NewBootConfig(bootConfigSvc),
+			mgtrestapi.NewQuarantine(mgtservice.NewQuarantine(agentVerifier, log)),
+			mgtrestapi.NewCollision(mgtservice.NewCollision(agentCollision, log)),
+			mgtrestapi.NewSession(mgtservice.NewSession(huber)),
+			mgtrestapi.NewHandshake(handshake.Default),
+			mgtrestapi.NewMetrics(metrics.Default),
+		}
+		// 与旧通道一致，中心端调用 broker 的接口都要校验授权令牌与范围。
+		mgtAuth := scopeauth.New(cfg.Secret, this.ID, scopeauth.Option{})
+		mgtScope := middle.ManagerScope(mgtAuth, scopeauth.Mode(cfg.Scope), log)
+		baseAPI := managerHandler.Group("/api/v1").Use(metrics.Middleware("manager", nil), mgtScope)
+		if err = shipx.BindRoutes(baseAPI, routes); err != nil {
+			log.Error("路由注册错误（manager）", "error", err)
+			return err
+		}
+	}
+	{
 		routes := []shipx.RouteBinder{
 			expresetapi.NewTunnel(agentTunnelServer),
 		}
@@ -170,6 +266,10 @@ func exec(ctx context.Context, pld profile.Reader[config.Config]) error {
 			log.Error("路由注册错误（expose-tls）", "error", err)
 			return err
 		}
+		if err = shipx.BindRoutes(exposeTLSHandler.Group("/"), []shipx.RouteBinder{healthAPI}); err != nil {
+			log.Error("路由注册错误（expose-tls）", "error", err)
+			return err
+		}
 	}
 	{
 		routes := []shipx.RouteBinder{
@@ -180,6 +280,10 @@ func exec(ctx context.Context, pld profile.Reader[config.Config]) error {
 			log.Error("路由注册错误（expose-tcp）", "error", err)
 			return err
 		}
+		if err = shipx.BindRoutes(exposeTCPHandler.Group("/"), []shipx.RouteBinder{healthAPI}); err != nil {
+			log.Error("路由注册错误（expose-tcp）", "error", err)
+			return err
+		}
 	}
 
 	// TODO 业务程序
@@ -207,19 +311,42 @@ func exec(ctx context.Context, pld profile.Reader[config.Config]) error {
 	muxListen := preadtls.NewListener(lis, 10*time.Second)
 	go serveHTTP(errs, exposeTCPSrv, muxListen.TCPListener())
 	go serveHTTPS(errs, exposeSrv, muxListen.TLSListener())
+	listening.Store(true)
+	if addr := cfg.Metrics; addr != "" {
+		msrv := metrics.NewServer(addr, metrics.Default)
+		defer msrv.Close()
+		go func() {
+			if exx := msrv.ListenAndServe(); exx != nil && !errors.Is(exx, http.ErrServerClosed) {
+				log.Warn("指标监听服务出错", "addr", addr, "error", exx)
+			}
+		}()
+	}
 
 	select {
 	case err = <-errs:
 	case <-ctx.Done():
 		err = ctx.Err()
 	}
+	listening.Store(false)
 	log.Warn("程序运行结束", "error", err)
+	{
+		// 先分批释放 agent 再关闭监听，防止 agent 蜂拥重连。
+		dctx, dcancel := context.WithTimeout(context.Background(), 2*time.Minute)
+		err1 := drainSvc.Drain(dctx, drain.Option{})
+		dcancel()
+		// 排空成功时已经重置过节点状态，已被 manager 排空过的直接重置即可。
+		if err1 != nil {
+			if !errors.Is(err1, serverd.ErrDraining) {
+				log.Warn("排空 agent 出错", "error", err1)
+			}
+			if err2 := currentBrokerSvc.ResetAgents(nil); err2 != nil {
+				log.Warn("关闭程序前重置 agent 节点状态错误", "error", err2)
+			}
+		}
+	}
 	_ = exposeTCPSrv.Close()
 	_ = exposeSrv.Close()
 	_ = muxListen.Close()
-	if err1 := currentBrokerSvc.ResetAgents(nil); err1 != nil {
-		log.Warn("关闭程序前重置 agent 节点状态错误", "error", err1)
-	}
 
 	return err
 }
@@ -0,0 +1,37 @@
+package launch2
+
+import (
+	"context"
+	"errors"
+	"sync/atomic"
+
+	"github.com/vela-ssoc/ssoc-broker/channel/clientd"
+	"github.com/vela-ssoc/ssoc-broker/channel/serverd"
+)
+
+var errManagerDown = errors.New("与中心端的通道未连接")
+
+// newLivenessGate 与中心端的通道断开期间暂停通过中心端询问 agent 存活状态，
+// 直接返回错误让调用方使用租约判断，避免每次都要等到超时。
+func newLivenessGate(mux clientd.Muxer, next serverd.LivenessChecker) serverd.LivenessChecker {
+	gate := &livenessGate{next: next}
+	gate.up.Store(mux.State().Connected())
+	mux.WatchState(func(_, cur clientd.State) {
+		gate.up.Store(cur.Connected())
+	})
+
+	return gate
+}
+
+type livenessGate struct {
+	up   atomic.Bool
+	next serverd.LivenessChecker
+}
+
+func (lg *livenessGate) AgentLiveness(ctx context.Context, brokerID, agentID int64) (bool, error) {
+	if !lg.up.Load() {
+		return false, errManagerDown
+	}
+
+	return lg.next.AgentLiveness(ctx, brokerID, agentID)
+}
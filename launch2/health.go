@@ -0,0 +1,24 @@
+package launch2
+
+import (
+	"github.com/vela-ssoc/ssoc-broker/channel/clientd"
+	"github.com/vela-ssoc/ssoc-broker/library/health"
+)
+
+// managerStatus 根据通道状态与心跳信息生成中心端通道状态，用于健康检查。
+func managerStatus(mux clientd.Muxer) health.ManagerStatus {
+	st, beat := mux.State(), mux.Heartbeat()
+	lastErr := st.LastError
+	if beat.LastError != "" {
+		lastErr = beat.LastError
+	}
+
+	return health.ManagerStatus{
+		Connected: st.Connected(),
+		State:     string(st.Phase),
+		Addr:      st.Addr,
+		RTTMS:     health.Millis(beat.RTT),
+		SkewMS:    health.Millis(beat.Skew),
+		LastError: lastErr,
+	}
+}
@@ -0,0 +1,69 @@
+package launch2
+
+import (
+	"net"
+
+	"github.com/vela-ssoc/ssoc-broker/bridge/gateway"
+	"github.com/vela-ssoc/ssoc-broker/bridge/mlink"
+	"github.com/vela-ssoc/ssoc-broker/channel/serverd"
+	"github.com/vela-ssoc/ssoc-common/linkhub"
+	"github.com/xgfone/ship/v5"
+)
+
+// serverdInfer 将新通道（serverd）的节点信息适配为 mlink.Infer 放入请求上下文，
+// 这样通过 mlink.Ctx 获取节点信息的 app/agtapi 接口在新通道上也可以使用。
+func serverdInfer(h ship.Handler) ship.Handler {
+	return func(c *ship.Context) error {
+		r := c.Request()
+		if peer := linkhub.FromContext(r.Context()); peer != nil {
+			ctx := mlink.WithInfer(r.Context(), newPeerInfer(peer))
+			c.SetRequest(r.WithContext(ctx))
+		}
+
+		return h(c)
+	}
+}
+
+type peerInfer struct {
+	peer  linkhub.Peer
+	ident gateway.Ident
+	issue gateway.Issue
+}
+
+func newPeerInfer(peer linkhub.Peer) *peerInfer {
+	info := peer.Info()
+	ident := gateway.Ident{Inet: net.ParseIP(info.Inet)}
+	issue := gateway.Issue{ID: info.ID}
+	if agt, ok := serverd.AgentOf(peer); ok {
+		ident.MachineID = agt.MachineID
+		ident.Inet6 = net.ParseIP(agt.Inet6)
+		ident.Goos = agt.Goos
+		ident.Arch = agt.Goarch
+		ident.PID = agt.PID
+		ident.Workdir = agt.Workdir
+		ident.Executable = agt.Executable
+		ident.Hostname = agt.Hostname
+		ident.Semver = agt.Semver
+		ident.Unload = agt.Unload
+		ident.Unstable = agt.Unstable
+		ident.Customized = agt.Customized
+		ident.Protocol = agt.Protocol
+		ident.Features = agt.Features
+		issue.Protocol = agt.Protocol
+		issue.Features = agt.Features
+	}
+
+	return &peerInfer{
+		peer:  peer,
+		ident: ident,
+		issue: issue,
+	}
+}
+
+func (p *peerInfer) Ident() gateway.Ident { return p.ident }
+func (p *peerInfer) Issue() gateway.Issue { return p.issue }
+func (p *peerInfer) Inet() net.IP         { return p.ident.Inet }
+
+func (p *peerInfer) Record(route string, failed bool) {
+	serverd.Record(p.peer, route, failed)
+}
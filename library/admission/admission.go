@@ -0,0 +1,219 @@
+package admission
+
+import (
+	"fmt"
+	"math"
+	"math/rand/v2"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"golang.org/x/time/rate"
+)
+
+// Controller 节点接入准入控制器。
+//
+// broker 重启后大量 agent 会同时重连，认证阶段需要查询、修改数据库，
+// 为了防止拖垮数据库，准入控制器由三部分组成：
+//
+//  1. 令牌桶：限制每秒接入的个数。
+//  2. 并发上限：限制同时处理认证（含数据库操作）的个数。
+//  3. 延迟反馈：认证处理耗时（主要是数据库耗时）超过预期时，按比例降低令牌桶速率。
+//
+// 被拒绝的请求会得到一个带有随机抖动的重试时间，让 agent 错峰重连。
+type Controller interface {
+	// Admit 申请准入，成功后调用方处理完毕必须调用 Ticket.Done，
+	// 失败返回 *RejectError。
+	//
+	// 反馈的耗时从 Admit 返回时开始计算，调用方应当读完认证报文后再申请准入。
+	Admit() (Ticket, error)
+
+	// Stats 当前准入状态。
+	Stats() Stats
+}
+
+// Ticket 准入凭证。
+type Ticket interface {
+	// Done 认证处理完毕，释放并发名额并反馈处理耗时。
+	Done()
+}
+
+// RejectError 拒绝准入的错误。
+type RejectError struct {
+	Reason     string
+	RetryAfter time.Duration
+}
+
+func (e *RejectError) Error() string {
+	return fmt.Sprintf("%s，请 %s 后重试", e.Reason, e.RetryAfter)
+}
+
+// Seconds Retry-After 秒数，向上取整。
+func (e *RejectError) Seconds() int {
+	return int(math.Ceil(e.RetryAfter.Seconds()))
+}
+
+// Option 准入参数。
+type Option struct {
+	Rate          float64       // 每秒允许接入的个数
+	Burst         int           // 令牌桶容量
+	MaxInflight   int           // 同时处理认证的最大个数
+	TargetLatency time.Duration // 期望的认证处理耗时，超过该值会降低接入速率
+	MinRetryAfter time.Duration // 最小重试时间
+	MaxRetryAfter time.Duration // 最大重试时间
+}
+
+func (opt Option) format() Option {
+	if opt.Rate <= 0 {
+		opt.Rate = 150
+	}
+	if opt.Burst <= 0 {
+		opt.Burst = int(opt.Rate)
+	}
+	if opt.MaxInflight <= 0 {
+		opt.MaxInflight = 64
+	}
+	if opt.TargetLatency <= 0 {
+		opt.TargetLatency = 200 * time.Millisecond
+	}
+	if opt.MinRetryAfter <= 0 {
+		opt.MinRetryAfter = time.Second
+	}
+	if opt.MaxRetryAfter < opt.MinRetryAfter {
+		opt.MaxRetryAfter = 5 * time.Minute
+	}
+
+	return opt
+}
+
+// Stats 准入状态。
+type Stats struct {
+	Rate     float64       `json:"rate"`     // 当前生效的速率
+	Inflight int64         `json:"inflight"` // 正在处理的认证个数
+	Latency  time.Duration `json:"latency"`  // 认证耗时的滑动平均值
+	Backlog  float64       `json:"backlog"`  // 估算的排队等待重连的个数
+	Admitted uint64        `json:"admitted"` // 累计准入次数
+	Rejected uint64        `json:"rejected"` // 累计拒绝次数
+}
+
+func New(opt Option) Controller {
+	opt = opt.format()
+	return &controller{
+		opt:     opt,
+		limit:   rate.NewLimiter(rate.Limit(opt.Rate), opt.Burst),
+		current: opt.Rate,
+		checkAt: time.Now(),
+	}
+}
+
+// ewmaWeight 延迟滑动平均的权重。
+const ewmaWeight = 0.2
+
+type controller struct {
+	opt      Option
+	limit    *rate.Limiter
+	inflight atomic.Int64
+	admitted atomic.Uint64
+	rejected atomic.Uint64
+
+	mutex   sync.Mutex
+	latency float64   // 认证耗时滑动平均值（秒）
+	current float64   // 当前生效的速率
+	backlog float64   // 估算的排队个数，按照当前速率衰减
+	checkAt time.Time // 上次衰减 backlog 的时间
+}
+
+func (ctl *controller) Admit() (Ticket, error) {
+	if n := ctl.inflight.Add(1); n > int64(ctl.opt.MaxInflight) {
+		ctl.inflight.Add(-1)
+		return nil, ctl.reject("认证并发过高")
+	}
+	if !ctl.limit.Allow() {
+		ctl.inflight.Add(-1)
+		return nil, ctl.reject("接入速率过高")
+	}
+	ctl.admitted.Add(1)
+
+	return &ticket{ctl: ctl, start: time.Now()}, nil
+}
+
+func (ctl *controller) Stats() Stats {
+	ctl.mutex.Lock()
+	defer ctl.mutex.Unlock()
+
+	ctl.decay(time.Now())
+
+	return Stats{
+		Rate:     ctl.current,
+		Inflight: ctl.inflight.Load(),
+		Latency:  time.Duration(ctl.latency * float64(time.Second)),
+		Backlog:  ctl.backlog,
+		Admitted: ctl.admitted.Load(),
+		Rejected: ctl.rejected.Load(),
+	}
+}
+
+// reject 计算重试时间：按照当前速率估算排在前面的个数需要多久才能消化完，
+// 再加上 0-50% 的随机抖动，防止被拒绝的 agent 在同一时刻重连。
+func (ctl *controller) reject(reason string) *RejectError {
+	ctl.rejected.Add(1)
+
+	ctl.mutex.Lock()
+	ctl.decay(time.Now())
+	ctl.backlog++
+	wait := ctl.backlog / ctl.current
+	ctl.mutex.Unlock()
+
+	wait *= 1 + rand.Float64()/2
+	after := time.Duration(wait * float64(time.Second))
+	after = max(after, ctl.opt.MinRetryAfter)
+	after = min(after, ctl.opt.MaxRetryAfter)
+
+	return &RejectError{Reason: reason, RetryAfter: after}
+}
+
+// decay 按照当前速率消化排队个数，调用方需持有锁。
+func (ctl *controller) decay(now time.Time) {
+	elapsed := now.Sub(ctl.checkAt).Seconds()
+	ctl.checkAt = now
+	ctl.backlog = max(ctl.backlog-elapsed*ctl.current, 0)
+}
+
+// observe 反馈认证耗时，耗时超过期望值时按比例降低速率，恢复后逐步回升。
+func (ctl *controller) observe(du time.Duration) {
+	ctl.mutex.Lock()
+	defer ctl.mutex.Unlock()
+
+	sec := du.Seconds()
+	if ctl.latency == 0 {
+		ctl.latency = sec
+	} else {
+		ctl.latency = ewmaWeight*sec + (1-ewmaWeight)*ctl.latency
+	}
+
+	// 速率最低降到配置值的 10%
+	target := ctl.opt.TargetLatency.Seconds()
+	ratio := min(target/ctl.latency, 1)
+	ratio = max(ratio, 0.1)
+	next := ctl.opt.Rate * ratio
+	if math.Abs(next-ctl.current) < ctl.opt.Rate*0.01 {
+		return
+	}
+
+	ctl.decay(time.Now())
+	ctl.current = next
+	ctl.limit.SetLimit(rate.Limit(next))
+}
+
+type ticket struct {
+	ctl   *controller
+	start time.Time
+	once  sync.Once
+}
+
+func (t *ticket) Done() {
+	t.once.Do(func() {
+		t.ctl.inflight.Add(-1)
+		t.ctl.observe(time.Since(t.start))
+	})
+}
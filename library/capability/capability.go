@@ -0,0 +1,66 @@
+package capability
+
+import (
+	"slices"
+	"strings"
+)
+
+// Version broker 当前的握手协议版本。
+const Version = 1
+
+// Feature agent 与 broker 之间可协商的特性。
+type Feature string
+
+const (
+	MachineID         Feature = "machine-id"         // 上报稳定的机器码，上线时必须携带
+	Compression       Feature = "compression"        // 报文压缩
+	ResumableDownload Feature = "resumable-download" // 断点续传下载
+	TypedCommand      Feature = "typed-command"      // 类型化的下发命令
+	TaskCancel        Feature = "task-cancel"        // 取消正在运行的任务
+	IdentityKey       Feature = "identity-key"       // 上线时携带 ed25519 公钥并签名，见 identity.Verifier
+)
+
+// supported broker 支持的特性，需要保持有序。
+var supported = Set{Compression, IdentityKey, MachineID, ResumableDownload, TaskCancel, TypedCommand}
+
+// Supported broker 支持的特性。
+func Supported() Set {
+	return slices.Clone(supported)
+}
+
+// Set 特性集合，有序且不重复。
+type Set []Feature
+
+// Has 是否包含该特性。
+func (s Set) Has(f Feature) bool {
+	_, found := slices.BinarySearch(s, f)
+	return found
+}
+
+// Negotiate 根据 agent 上报的协议版本与特性，协商出双方共同使用的协议版本和特性。
+//
+// protocol 为 0 说明 agent 早于特性协商，此时只能根据版本号推断，见 Legacy。
+func Negotiate(protocol int, offered []Feature, semver string) (int, Set) {
+	if protocol <= 0 {
+		return 0, Legacy(semver)
+	}
+
+	ret := make(Set, 0, len(offered))
+	for _, f := range offered {
+		if supported.Has(f) && !slices.Contains(ret, f) {
+			ret = append(ret, f)
+		}
+	}
+	slices.Sort(ret)
+
+	return min(protocol, Version), ret
+}
+
+// Legacy 推断不支持特性协商的老版本 agent 具备的特性，这也是唯一根据版本号判断能力的地方。
+func Legacy(semver string) Set {
+	if strings.HasPrefix(semver, "4.") {
+		return Set{MachineID}
+	}
+
+	return Set{}
+}
@@ -0,0 +1,529 @@
+package collision
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log/slog"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/vela-ssoc/ssoc-common-mb/dal/model"
+	"github.com/vela-ssoc/ssoc-common-mb/dal/query"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// BucketCollision 机器码冲突记录存放的 kv_data 存储桶，key 为机器码。
+const BucketCollision = "agent-collision"
+
+var (
+	ErrCollision     = errors.New("机器码与其它主机冲突，已被隔离")
+	ErrBadPolicy     = errors.New("无效的机器码冲突处理策略")
+	ErrBadResolution = errors.New("无效的机器码冲突处理方式")
+)
+
+// Policy 发现机器码冲突后对新主机的处理策略。
+type Policy string
+
+const (
+	PolicyObserve    Policy = "observe"    // 仅记录事件，新主机仍使用原机器码上线（默认）
+	PolicyDerive     Policy = "derive"     // 新主机使用派生的机器码，作为独立的节点上线
+	PolicyQuarantine Policy = "quarantine" // 拒绝新主机上线，直到管理员处理
+)
+
+// Valid 策略是否有效，空字符串视为有效（使用默认策略）。
+func (p Policy) Valid() bool {
+	switch p {
+	case "", PolicyObserve, PolicyDerive, PolicyQuarantine:
+		return true
+	default:
+		return false
+	}
+}
+
+// Resolution 管理员对冲突的处理方式。
+type Resolution string
+
+const (
+	ResolveDismiss Resolution = "dismiss" // 误报（如：主机改了主机名或更换了网卡），删除冲突记录
+	ResolveSplit   Resolution = "split"   // 确认是克隆主机，新主机永久使用派生的机器码上线
+)
+
+// Option 冲突检测参数。
+type Option struct {
+	// Window 同一个机器码在该时间窗口内出现不同的主机特征视为冲突，默认 10 分钟。
+	Window time.Duration
+
+	// Expire 冲突记录超过该时长没有新主机上线即视为失效，默认 7 天。
+	// 管理员确认过的克隆主机（ResolveSplit）不会失效。
+	Expire time.Duration
+
+	// Policy 发现冲突后的处理策略，默认 PolicyObserve。
+	Policy Policy
+}
+
+func (opt Option) format() Option {
+	if opt.Window <= 0 {
+		opt.Window = 10 * time.Minute
+	}
+	if opt.Expire <= 0 {
+		opt.Expire = 7 * 24 * time.Hour
+	}
+	if opt.Policy == "" {
+		opt.Policy = PolicyObserve
+	}
+
+	return opt
+}
+
+// Detector 机器码冲突检测。
+//
+// 克隆的虚拟机或复制的镜像会携带相同的机器码，如果直接按机器码查找节点，
+// 多台主机会被合并到同一个节点上，相互挤占会话。Detector 记录每个机器码近期上线时的
+// 主机特征（主机名、MAC），短时间内出现多组不同的特征时认为发生了冲突。
+// inet 不参与比较，主机更换 IP（如：DHCP 重新分配）不会被误判为冲突。
+// 最早出现的特征视为原主机，其余为新主机，新主机按照 Policy 处理。
+//
+// 检测分为两步：Observe 只检测不记录，Commit 在身份校验通过后才记录，
+// 防止伪造的上线请求污染冲突记录、产生冲突事件。
+type Detector interface {
+	// Observe 检测一次上线，返回用于查找节点的机器码，不保存任何状态。
+	//
+	// 策略为 PolicyQuarantine 时新主机返回 ErrCollision。
+	Observe(ctx context.Context, claim Claim) (Verdict, error)
+
+	// Commit 记录一次已通过身份校验的上线，发生冲突时保存冲突记录，该新主机首次冲突时上报事件。
+	//
+	// 返回值与 Observe 相同，策略为 PolicyQuarantine 时新主机同样返回 ErrCollision。
+	Commit(ctx context.Context, claim Claim) (Verdict, error)
+
+	// SetPolicy 修改处理策略，仅对之后的上线生效。
+	SetPolicy(p Policy) error
+
+	// Collisions 查询所有的冲突记录。
+	Collisions(ctx context.Context) ([]*Collision, error)
+
+	// Resolve 处理冲突。
+	Resolve(ctx context.Context, machineID string, res Resolution) error
+}
+
+// Claim 节点上线时声明的主机特征。
+type Claim struct {
+	MachineID string
+	Inet      string
+	Hostname  string
+	MAC       string
+}
+
+// Fingerprint 主机特征指纹，由主机名和 MAC 计算，不包含 inet。
+func (c Claim) Fingerprint() string {
+	sum := sha256.Sum256([]byte(c.Hostname + "\n" + c.MAC))
+	return hex.EncodeToString(sum[:8])
+}
+
+// Verdict 冲突检测结果。
+type Verdict struct {
+	MachineID string // 用于查找节点的机器码，派生时与声明的机器码不同
+	Collided  bool   // 本次上线的主机是否与原主机冲突
+	Derived   bool   // 是否使用了派生的机器码
+	First     bool   // 是否是该新主机首次被发现冲突，调用方据此去重事件，防止新主机不断重连刷屏
+}
+
+// DeriveMachineID 根据主机特征派生机器码，同一台主机每次派生的结果一致。
+func DeriveMachineID(machineID, fingerprint string) string {
+	return machineID + "#" + fingerprint
+}
+
+// Sighting 某组主机特征的上线记录。
+type Sighting struct {
+	Fingerprint string    `json:"fingerprint"`
+	Inet        string    `json:"inet"`
+	Hostname    string    `json:"hostname"`
+	MAC         string    `json:"mac"`
+	SeenAt      time.Time `json:"seen_at"`
+}
+
+// Collision 冲突记录。
+type Collision struct {
+	MachineID string      `json:"machine_id"`
+	Incumbent Sighting    `json:"incumbent"` // 原主机
+	Newcomers []*Sighting `json:"newcomers"` // 新主机，按照指纹去重
+	Split     bool        `json:"split"`     // 管理员已确认是克隆主机
+	Attempts  int         `json:"attempts"`  // 新主机上线的次数
+	CreatedAt time.Time   `json:"created_at"`
+	UpdatedAt time.Time   `json:"updated_at"`
+}
+
+func (c *Collision) newcomer(fp string) *Sighting {
+	for _, s := range c.Newcomers {
+		if s.Fingerprint == fp {
+			return s
+		}
+	}
+	return nil
+}
+
+func New(qry *query.Query, opt Option, log *slog.Logger) Detector {
+	return newDetector(&kvStore{qry: qry}, opt, log)
+}
+
+func newDetector(st store, opt Option, log *slog.Logger) *detector {
+	opt = opt.format()
+	det := &detector{
+		store:     st,
+		window:    opt.Window,
+		expire:    opt.Expire,
+		log:       log,
+		sightings: make(map[string][]*Sighting, 64),
+	}
+	det.policy.Store(opt.Policy)
+
+	return det
+}
+
+type detector struct {
+	store     store
+	window    time.Duration
+	expire    time.Duration
+	log       *slog.Logger
+	policy    atomic.Value
+	mutex     sync.Mutex
+	sightings map[string][]*Sighting // 机器码 -> 时间窗口内出现过的主机特征
+	sweptAt   time.Time
+}
+
+func (det *detector) Observe(ctx context.Context, claim Claim) (Verdict, error) {
+	return det.observe(ctx, claim, false)
+}
+
+func (det *detector) Commit(ctx context.Context, claim Claim) (Verdict, error) {
+	return det.observe(ctx, claim, true)
+}
+
+// observe 检测冲突，commit 为 true 时记录主机特征、保存冲突记录并上报事件。
+func (det *detector) observe(ctx context.Context, claim Claim, commit bool) (Verdict, error) {
+	verdict := Verdict{MachineID: claim.MachineID}
+	if claim.MachineID == "" {
+		return verdict, nil
+	}
+
+	now := time.Now()
+	fp := claim.Fingerprint()
+	current := &Sighting{Fingerprint: fp, Inet: claim.Inet, Hostname: claim.Hostname, MAC: claim.MAC, SeenAt: now}
+
+	col, err := det.load(ctx, claim.MachineID, now)
+	if err != nil {
+		return verdict, err
+	}
+	if col == nil {
+		incumbent := det.sight(claim.MachineID, *current, commit)
+		if incumbent == nil {
+			return verdict, nil
+		}
+		col = &Collision{
+			MachineID: claim.MachineID,
+			Incumbent: *incumbent,
+			CreatedAt: now,
+		}
+	}
+	if col.Incumbent.Fingerprint == fp {
+		return verdict, nil
+	}
+
+	// 新主机
+	first := true
+	if last := col.newcomer(fp); last != nil {
+		*last = *current
+		first = false
+	} else {
+		col.Newcomers = append(col.Newcomers, current)
+	}
+	policy := det.policy.Load().(Policy)
+	if commit {
+		col.Attempts++
+		col.UpdatedAt = now
+		if err = det.store.save(ctx, col); err != nil {
+			return verdict, err
+		}
+
+		attrs := []any{
+			slog.String("machine_id", claim.MachineID), slog.String("policy", string(policy)),
+			slog.String("incumbent", col.Incumbent.Fingerprint), slog.String("fingerprint", fp),
+			slog.String("inet", claim.Inet), slog.String("hostname", claim.Hostname),
+		}
+		det.log.Warn("机器码与其它主机冲突", attrs...)
+
+		// 每个新主机只在首次冲突时产生事件，防止新主机不断重连刷屏。
+		if first {
+			det.store.report(ctx, col, current, policy)
+		}
+	}
+
+	verdict.Collided = true
+	verdict.First = first
+	switch {
+	case col.Split, policy == PolicyDerive:
+		verdict.MachineID = DeriveMachineID(claim.MachineID, fp)
+		verdict.Derived = true
+	case policy == PolicyQuarantine:
+		return verdict, ErrCollision
+	}
+
+	return verdict, nil
+}
+
+func (det *detector) SetPolicy(p Policy) error {
+	if !p.Valid() {
+		return ErrBadPolicy
+	}
+	if p == "" {
+		p = PolicyObserve
+	}
+	det.policy.Store(p)
+
+	return nil
+}
+
+func (det *detector) Collisions(ctx context.Context) ([]*Collision, error) {
+	cols, err := det.store.list(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	expired := time.Now().Add(-det.expire)
+	ret := make([]*Collision, 0, len(cols))
+	for _, col := range cols {
+		if !det.stale(col, expired) {
+			ret = append(ret, col)
+		}
+	}
+
+	return ret, nil
+}
+
+func (det *detector) Resolve(ctx context.Context, machineID string, res Resolution) error {
+	col, err := det.load(ctx, machineID, time.Now())
+	if err != nil {
+		return err
+	}
+	if col == nil {
+		return gorm.ErrRecordNotFound
+	}
+
+	switch res {
+	case ResolveDismiss:
+		if err = det.store.remove(ctx, machineID); err != nil {
+			return err
+		}
+		det.mutex.Lock()
+		delete(det.sightings, machineID)
+		det.mutex.Unlock()
+		return nil
+	case ResolveSplit:
+		col.Split = true
+		col.UpdatedAt = time.Now()
+		return det.store.save(ctx, col)
+	default:
+		return ErrBadResolution
+	}
+}
+
+// sight 时间窗口内出现了不同的主机特征时返回最早出现的特征（即原主机），否则返回 nil。
+// record 为 true 时记录本次的主机特征，否则只检测不修改。
+func (det *detector) sight(mid string, current Sighting, record bool) *Sighting {
+	det.mutex.Lock()
+	defer det.mutex.Unlock()
+
+	expired := current.SeenAt.Add(-det.window)
+	if !record {
+		var alive []*Sighting
+		for _, s := range det.sightings[mid] {
+			if s.SeenAt.After(expired) {
+				alive = append(alive, s)
+			}
+		}
+		// 加上本次的特征后至少有两组不同的特征才算冲突
+		if len(alive) == 0 || len(alive) == 1 && alive[0].Fingerprint == current.Fingerprint {
+			return nil
+		}
+		cp := *alive[0]
+		return &cp
+	}
+
+	// 每个时间窗口顺便清理一次过期的记录
+	if det.sweptAt.Before(expired) {
+		det.sweptAt = current.SeenAt
+		for key, ss := range det.sightings {
+			if alive := det.alive(ss, expired); len(alive) == 0 {
+				delete(det.sightings, key)
+			} else {
+				det.sightings[key] = alive
+			}
+		}
+	}
+
+	ss := det.alive(det.sightings[mid], expired)
+	var found bool
+	for _, s := range ss {
+		if s.Fingerprint == current.Fingerprint {
+			s.SeenAt = current.SeenAt
+			found = true
+		}
+	}
+	if !found {
+		ss = append(ss, &current)
+	}
+	det.sightings[mid] = ss
+	if len(ss) < 2 {
+		return nil
+	}
+
+	cp := *ss[0]
+
+	return &cp
+}
+
+// alive 过滤掉过期的记录。
+func (*detector) alive(ss []*Sighting, expired time.Time) []*Sighting {
+	ret := ss[:0]
+	for _, s := range ss {
+		if s.SeenAt.After(expired) {
+			ret = append(ret, s)
+		}
+	}
+	return ret
+}
+
+// load 查询冲突记录，失效的记录会被删除并返回 nil。
+func (det *detector) load(ctx context.Context, machineID string, now time.Time) (*Collision, error) {
+	col, err := det.store.load(ctx, machineID)
+	if err != nil || col == nil {
+		return nil, err
+	}
+	if !det.stale(col, now.Add(-det.expire)) {
+		return col, nil
+	}
+
+	det.log.Info("机器码冲突记录已失效", slog.String("machine_id", machineID), slog.Time("updated_at", col.UpdatedAt))
+	if err = det.store.remove(ctx, machineID); err != nil {
+		return nil, err
+	}
+
+	return nil, nil
+}
+
+// stale 冲突记录是否已失效，管理员确认过的克隆主机不会失效。
+func (*detector) stale(col *Collision, expired time.Time) bool {
+	return !col.Split && col.UpdatedAt.Before(expired)
+}
+
+// store 冲突记录的存储。
+type store interface {
+	load(ctx context.Context, machineID string) (*Collision, error)
+	list(ctx context.Context) ([]*Collision, error)
+	save(ctx context.Context, col *Collision) error
+	remove(ctx context.Context, machineID string) error
+
+	// report 记录冲突事件。
+	report(ctx context.Context, col *Collision, current *Sighting, policy Policy)
+}
+
+// kvStore 冲突记录保存在 kv_data 中，冲突事件保存在 event 表中。
+type kvStore struct {
+	qry *query.Query
+}
+
+func (ks *kvStore) load(ctx context.Context, machineID string) (*Collision, error) {
+	tbl := ks.qry.KVData
+	dat, err := tbl.WithContext(ctx).
+		Where(tbl.Bucket.Eq(BucketCollision), tbl.Key.Eq(machineID)).
+		First()
+	if err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, nil
+		}
+		return nil, err
+	}
+
+	col := new(Collision)
+	if err = json.Unmarshal(dat.Value, col); err != nil {
+		return nil, err
+	}
+
+	return col, nil
+}
+
+func (ks *kvStore) list(ctx context.Context) ([]*Collision, error) {
+	tbl := ks.qry.KVData
+	dats, err := tbl.WithContext(ctx).
+		Where(tbl.Bucket.Eq(BucketCollision)).
+		Order(tbl.UpdatedAt.Desc()).
+		Find()
+	if err != nil {
+		return nil, err
+	}
+
+	ret := make([]*Collision, 0, len(dats))
+	for _, dat := range dats {
+		col := new(Collision)
+		if exx := json.Unmarshal(dat.Value, col); exx == nil {
+			ret = append(ret, col)
+		}
+	}
+
+	return ret, nil
+}
+
+func (ks *kvStore) save(ctx context.Context, col *Collision) error {
+	val, _ := json.Marshal(col)
+	dat := &model.KVData{
+		Bucket:    BucketCollision,
+		Key:       col.MachineID,
+		Value:     val,
+		Version:   1,
+		CreatedAt: col.CreatedAt,
+		UpdatedAt: col.UpdatedAt,
+	}
+
+	return ks.qry.KVData.WithContext(ctx).
+		Clauses(clause.OnConflict{DoUpdates: clause.AssignmentColumns([]string{"value", "updated_at"})}).
+		Create(dat)
+}
+
+func (ks *kvStore) remove(ctx context.Context, machineID string) error {
+	tbl := ks.qry.KVData
+	_, err := tbl.WithContext(ctx).
+		Where(tbl.Bucket.Eq(BucketCollision), tbl.Key.Eq(machineID)).
+		Delete()
+
+	return err
+}
+
+// report 保存冲突事件，事件关联到原主机对应的节点上。
+func (ks *kvStore) report(ctx context.Context, col *Collision, current *Sighting, policy Policy) {
+	var minionID int64
+	tbl := ks.qry.Minion
+	if mon, err := tbl.WithContext(ctx).Where(tbl.MachineID.Eq(col.MachineID)).First(); err == nil {
+		minionID = mon.ID
+	}
+
+	now := time.Now()
+	inc := col.Incumbent
+	evt := &model.Event{
+		MinionID: minionID,
+		Inet:     current.Inet,
+		Subject:  "节点机器码冲突",
+		FromCode: "minion.collision",
+		Msg: fmt.Sprintf("机器码 %s 在短时间内出现在不同的主机上（原主机：%s/%s/%s，新主机：%s/%s/%s），疑似克隆主机，处理策略：%s",
+			col.MachineID, inc.Inet, inc.Hostname, inc.MAC, current.Inet, current.Hostname, current.MAC, policy),
+		Level:     model.ELvlMajor,
+		OccurAt:   now,
+		CreatedAt: now,
+	}
+	_ = ks.qry.Event.WithContext(ctx).Create(evt)
+}
@@ -0,0 +1,314 @@
+package collision
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"io"
+	"log/slog"
+	"sort"
+	"testing"
+	"time"
+
+	"gorm.io/gorm"
+)
+
+// memStore 内存中的冲突记录，读写都经过 JSON 编解码，与数据库存储的行为保持一致。
+type memStore struct {
+	data    map[string][]byte
+	reports []*Sighting
+}
+
+func newMemStore() *memStore {
+	return &memStore{data: make(map[string][]byte, 8)}
+}
+
+func (ms *memStore) load(_ context.Context, machineID string) (*Collision, error) {
+	val, ok := ms.data[machineID]
+	if !ok {
+		return nil, nil
+	}
+	col := new(Collision)
+	if err := json.Unmarshal(val, col); err != nil {
+		return nil, err
+	}
+
+	return col, nil
+}
+
+func (ms *memStore) list(ctx context.Context) ([]*Collision, error) {
+	keys := make([]string, 0, len(ms.data))
+	for key := range ms.data {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+
+	ret := make([]*Collision, 0, len(keys))
+	for _, key := range keys {
+		col, _ := ms.load(ctx, key)
+		ret = append(ret, col)
+	}
+
+	return ret, nil
+}
+
+func (ms *memStore) save(_ context.Context, col *Collision) error {
+	val, err := json.Marshal(col)
+	if err != nil {
+		return err
+	}
+	ms.data[col.MachineID] = val
+
+	return nil
+}
+
+func (ms *memStore) remove(_ context.Context, machineID string) error {
+	delete(ms.data, machineID)
+	return nil
+}
+
+func (ms *memStore) report(_ context.Context, _ *Collision, current *Sighting, _ Policy) {
+	ms.reports = append(ms.reports, current)
+}
+
+func testDetector(policy Policy) (*detector, *memStore) {
+	st := newMemStore()
+	log := slog.New(slog.NewTextHandler(io.Discard, nil))
+
+	return newDetector(st, Option{Policy: policy}, log), st
+}
+
+var (
+	incumbent = Claim{MachineID: "m1", Inet: "10.0.0.1", Hostname: "web-01", MAC: "00:00:00:00:00:01"}
+	newcomer  = Claim{MachineID: "m1", Inet: "10.0.0.2", Hostname: "web-02", MAC: "00:00:00:00:00:02"}
+	another   = Claim{MachineID: "m1", Inet: "10.0.0.3", Hostname: "web-03", MAC: "00:00:00:00:00:03"}
+)
+
+// observe 模拟一次通过身份校验的上线：先检测，再记录，两次的机器码必须一致。
+func observe(t *testing.T, det *detector, claim Claim) Verdict {
+	t.Helper()
+	ctx := context.Background()
+	peek, err := det.Observe(ctx, claim)
+	if err != nil {
+		t.Fatalf("Observe(%s) 出错：%v", claim.Hostname, err)
+	}
+	verdict, err := det.Commit(ctx, claim)
+	if err != nil {
+		t.Fatalf("Commit(%s) 出错：%v", claim.Hostname, err)
+	}
+	if peek.MachineID != verdict.MachineID || peek.Collided != verdict.Collided {
+		t.Fatalf("Observe 与 Commit 的结果不一致：%+v，%+v", peek, verdict)
+	}
+	return verdict
+}
+
+func TestObserveWithoutCommit(t *testing.T) {
+	ctx := context.Background()
+	det, st := testDetector(PolicyQuarantine)
+	observe(t, det, incumbent)
+
+	// 未通过身份校验的上线请求只检测，不保存记录也不产生事件
+	for i := 0; i < 3; i++ {
+		if _, err := det.Observe(ctx, newcomer); !errors.Is(err, ErrCollision) {
+			t.Fatalf("期望 ErrCollision，实际 %v", err)
+		}
+	}
+	if len(st.data) != 0 || len(st.reports) != 0 {
+		t.Fatalf("Observe 不应保存记录：%d 条记录，%d 个事件", len(st.data), len(st.reports))
+	}
+
+	// 只检测过的主机特征也不会让之后上线的主机被误判为冲突
+	det2, _ := testDetector(PolicyQuarantine)
+	if _, err := det2.Observe(ctx, newcomer); err != nil {
+		t.Fatal(err)
+	}
+	if v := observe(t, det2, incumbent); v.Collided {
+		t.Fatalf("未记录的主机特征不应参与冲突检测：%+v", v)
+	}
+
+	v, err := det.Commit(ctx, newcomer)
+	if !errors.Is(err, ErrCollision) || !v.First {
+		t.Fatalf("记录后应产生冲突：%+v，%v", v, err)
+	}
+	if len(st.data) != 1 || len(st.reports) != 1 {
+		t.Fatalf("Commit 应保存记录：%d 条记录，%d 个事件", len(st.data), len(st.reports))
+	}
+	if col, _ := st.load(ctx, "m1"); col.Attempts != 1 {
+		t.Fatalf("只有 Commit 计入上线次数：%+v", col)
+	}
+}
+
+func TestObserveSameHost(t *testing.T) {
+	det, st := testDetector(PolicyObserve)
+	for i := 0; i < 3; i++ {
+		if v := observe(t, det, incumbent); v.Collided || v.MachineID != "m1" {
+			t.Fatalf("同一台主机重连不应冲突：%+v", v)
+		}
+	}
+
+	// 只更换了 IP 仍是同一台主机
+	moved := incumbent
+	moved.Inet = "192.168.1.1"
+	if v := observe(t, det, moved); v.Collided {
+		t.Fatalf("主机更换 IP 不应冲突：%+v", v)
+	}
+	if len(st.data) != 0 || len(st.reports) != 0 {
+		t.Fatalf("没有冲突时不应保存记录：%d 条记录，%d 个事件", len(st.data), len(st.reports))
+	}
+}
+
+func TestObserveEmptyMachineID(t *testing.T) {
+	det, st := testDetector(PolicyQuarantine)
+	for _, claim := range []Claim{{Hostname: "a"}, {Hostname: "b"}} {
+		if v := observe(t, det, claim); v.Collided || v.MachineID != "" {
+			t.Fatalf("没有机器码时不检测冲突：%+v", v)
+		}
+	}
+	if len(st.data) != 0 {
+		t.Fatalf("没有机器码时不应保存记录")
+	}
+}
+
+func TestObserveDedupeEvents(t *testing.T) {
+	det, st := testDetector(PolicyObserve)
+	observe(t, det, incumbent)
+
+	v := observe(t, det, newcomer)
+	if !v.Collided || !v.First || v.Derived || v.MachineID != "m1" {
+		t.Fatalf("新主机首次上线：%+v", v)
+	}
+	for i := 0; i < 3; i++ {
+		if v = observe(t, det, newcomer); !v.Collided || v.First {
+			t.Fatalf("新主机重连不应重复产生事件：%+v", v)
+		}
+	}
+	if v = observe(t, det, incumbent); v.Collided {
+		t.Fatalf("原主机不应冲突：%+v", v)
+	}
+	if v = observe(t, det, another); !v.Collided || !v.First {
+		t.Fatalf("另一台新主机首次上线：%+v", v)
+	}
+	if len(st.reports) != 2 {
+		t.Fatalf("期望 2 个冲突事件，实际 %d 个", len(st.reports))
+	}
+
+	cols, err := det.Collisions(context.Background())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(cols) != 1 {
+		t.Fatalf("期望 1 条冲突记录，实际 %d 条", len(cols))
+	}
+	col := cols[0]
+	if col.Incumbent.Hostname != incumbent.Hostname || len(col.Newcomers) != 2 || col.Attempts != 5 {
+		t.Fatalf("冲突记录不正确：%+v", col)
+	}
+}
+
+func TestObservePolicy(t *testing.T) {
+	det, _ := testDetector(PolicyDerive)
+	observe(t, det, incumbent)
+
+	derived := DeriveMachineID("m1", newcomer.Fingerprint())
+	for i := 0; i < 2; i++ {
+		if v := observe(t, det, newcomer); !v.Derived || v.MachineID != derived {
+			t.Fatalf("派生策略下新主机应使用派生的机器码：%+v", v)
+		}
+	}
+
+	if err := det.SetPolicy(PolicyQuarantine); err != nil {
+		t.Fatal(err)
+	}
+	v, err := det.Commit(context.Background(), another)
+	if !errors.Is(err, ErrCollision) || !v.First {
+		t.Fatalf("隔离策略下新主机应被拒绝：%+v，%v", v, err)
+	}
+	if _, err = det.Commit(context.Background(), another); !errors.Is(err, ErrCollision) {
+		t.Fatalf("隔离策略下新主机重连仍应被拒绝：%v", err)
+	}
+	if v = observe(t, det, incumbent); v.Collided {
+		t.Fatalf("隔离策略下原主机不受影响：%+v", v)
+	}
+
+	if err = det.SetPolicy("unknown"); !errors.Is(err, ErrBadPolicy) {
+		t.Fatalf("期望 ErrBadPolicy，实际 %v", err)
+	}
+}
+
+func TestResolve(t *testing.T) {
+	ctx := context.Background()
+	det, st := testDetector(PolicyQuarantine)
+	observe(t, det, incumbent)
+	if _, err := det.Commit(ctx, newcomer); !errors.Is(err, ErrCollision) {
+		t.Fatalf("期望 ErrCollision，实际 %v", err)
+	}
+
+	if err := det.Resolve(ctx, "m1", "unknown"); !errors.Is(err, ErrBadResolution) {
+		t.Fatalf("期望 ErrBadResolution，实际 %v", err)
+	}
+	if err := det.Resolve(ctx, "m2", ResolveSplit); !errors.Is(err, gorm.ErrRecordNotFound) {
+		t.Fatalf("期望 ErrRecordNotFound，实际 %v", err)
+	}
+
+	// 确认是克隆主机后，不论策略如何都使用派生的机器码上线
+	if err := det.Resolve(ctx, "m1", ResolveSplit); err != nil {
+		t.Fatal(err)
+	}
+	derived := DeriveMachineID("m1", newcomer.Fingerprint())
+	if v := observe(t, det, newcomer); !v.Derived || v.MachineID != derived {
+		t.Fatalf("已确认的克隆主机应使用派生的机器码：%+v", v)
+	}
+
+	// 误报：删除记录后，只有新主机上线时不再冲突
+	if err := det.Resolve(ctx, "m1", ResolveDismiss); err != nil {
+		t.Fatal(err)
+	}
+	if len(st.data) != 0 {
+		t.Fatalf("误报处理后应删除冲突记录")
+	}
+	if v := observe(t, det, newcomer); v.Collided {
+		t.Fatalf("误报处理后不应冲突：%+v", v)
+	}
+}
+
+func TestExpire(t *testing.T) {
+	ctx := context.Background()
+	det, st := testDetector(PolicyQuarantine)
+	old := time.Now().Add(-det.expire - time.Hour)
+	stale := &Collision{
+		MachineID: "m1",
+		Incumbent: Sighting{Fingerprint: incumbent.Fingerprint(), Hostname: incumbent.Hostname, SeenAt: old},
+		Newcomers: []*Sighting{{Fingerprint: newcomer.Fingerprint(), Hostname: newcomer.Hostname, SeenAt: old}},
+		Attempts:  1,
+		CreatedAt: old,
+		UpdatedAt: old,
+	}
+	split := &Collision{
+		MachineID: "m2",
+		Incumbent: Sighting{Fingerprint: "inc"},
+		Split:     true,
+		CreatedAt: old,
+		UpdatedAt: old,
+	}
+	_ = st.save(ctx, stale)
+	_ = st.save(ctx, split)
+
+	cols, err := det.Collisions(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(cols) != 1 || cols[0].MachineID != "m2" {
+		t.Fatalf("失效的记录不应列出，已确认的克隆主机不会失效：%+v", cols)
+	}
+
+	// 失效的记录不再隔离新主机
+	if v := observe(t, det, newcomer); v.Collided {
+		t.Fatalf("冲突记录失效后不应冲突：%+v", v)
+	}
+	if _, ok := st.data["m1"]; ok {
+		t.Fatalf("失效的冲突记录应被删除")
+	}
+	if _, ok := st.data["m2"]; !ok {
+		t.Fatalf("已确认的克隆主机记录不应被删除")
+	}
+}
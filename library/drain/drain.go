@@ -0,0 +1,166 @@
+package drain
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"strconv"
+	"sync"
+	"time"
+)
+
+var (
+	ErrDraining    = errors.New("broker 正在排空下线")
+	ErrNotDraining = errors.New("broker 未处于排空模式")
+
+	// errResumed 排空被中心端取消，尚未释放的连接保持不变。
+	errResumed = errors.New("排空已取消")
+)
+
+// Option 排空参数。
+type Option struct {
+	Wave     int           // 每批次释放的连接个数，默认 200
+	Interval time.Duration // 每批次之间的间隔，默认 2s
+}
+
+func (opt Option) Format() Option {
+	if opt.Wave <= 0 {
+		opt.Wave = 200
+	}
+	if opt.Interval <= 0 {
+		opt.Interval = 2 * time.Second
+	}
+
+	return opt
+}
+
+// Gate 排空状态。
+//
+// 进入排空模式后不再接入新连接，直到 Resume 退出排空模式。
+type Gate struct {
+	mutex    sync.Mutex
+	draining bool
+	cancel   context.CancelCauseFunc
+}
+
+func (g *Gate) Draining() bool {
+	g.mutex.Lock()
+	defer g.mutex.Unlock()
+
+	return g.draining
+}
+
+// Begin 进入排空模式，返回的 ctx 会在 Resume 时取消。已经处于排空模式返回 ErrDraining。
+func (g *Gate) Begin(parent context.Context) (context.Context, error) {
+	g.mutex.Lock()
+	defer g.mutex.Unlock()
+
+	if g.draining {
+		return nil, ErrDraining
+	}
+	ctx, cancel := context.WithCancelCause(parent)
+	g.draining, g.cancel = true, cancel
+
+	return ctx, nil
+}
+
+// Resume 退出排空模式，正在进行的排空会停止，尚未释放的连接保持不变。
+func (g *Gate) Resume() error {
+	g.mutex.Lock()
+	defer g.mutex.Unlock()
+
+	if !g.draining {
+		return ErrNotDraining
+	}
+	g.cancel(errResumed)
+	g.draining, g.cancel = false, nil
+
+	return nil
+}
+
+// Resumed 排空是否因为 Resume 而停止。
+func Resumed(ctx context.Context) bool {
+	return errors.Is(context.Cause(ctx), errResumed)
+}
+
+// Waves 分批调用 release 释放连接，每批之间间隔 opt.Interval，阻塞直至全部释放。
+//
+// ctx 超时后剩余的连接调用 abort 直接断开；排空被 Resume 取消时剩余的连接保持不变并返回 false。
+func Waves[T any](ctx context.Context, items []T, opt Option, release func(context.Context, T), abort func(T)) bool {
+	opt = opt.Format()
+	for len(items) != 0 {
+		if Resumed(ctx) {
+			return false
+		}
+
+		n := min(opt.Wave, len(items))
+		wg := new(sync.WaitGroup)
+		for _, item := range items[:n] {
+			wg.Add(1)
+			go func(v T) {
+				defer wg.Done()
+				release(ctx, v)
+			}(item)
+		}
+		wg.Wait()
+
+		items = items[n:]
+		if len(items) == 0 {
+			break
+		}
+
+		select {
+		case <-ctx.Done():
+			if Resumed(ctx) {
+				return false
+			}
+			for _, item := range items {
+				abort(item)
+			}
+			items = nil
+		case <-time.After(opt.Interval):
+		}
+	}
+
+	return true
+}
+
+// Duration 面向用户的时长（如：排空间隔、心跳间隔），JSON 与查询参数既支持 "2s" 这样的字符串，也兼容纳秒数值。
+type Duration time.Duration
+
+func (d Duration) MarshalJSON() ([]byte, error) {
+	return json.Marshal(time.Duration(d).String())
+}
+
+func (d *Duration) UnmarshalJSON(b []byte) error {
+	var s string
+	if err := json.Unmarshal(b, &s); err != nil {
+		var n int64
+		if exx := json.Unmarshal(b, &n); exx != nil {
+			return err
+		}
+		*d = Duration(n)
+		return nil
+	}
+
+	return d.UnmarshalText([]byte(s))
+}
+
+func (d *Duration) UnmarshalText(b []byte) error {
+	s := string(b)
+	if s == "" {
+		*d = 0
+		return nil
+	}
+	if n, err := strconv.ParseInt(s, 10, 64); err == nil {
+		*d = Duration(n)
+		return nil
+	}
+	du, err := time.ParseDuration(s)
+	if err != nil {
+		return err
+	}
+	*d = Duration(du)
+
+	return nil
+}
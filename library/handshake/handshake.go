@@ -0,0 +1,97 @@
+package handshake
+
+import (
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"os"
+)
+
+// MaxFrame 握手报文默认的大小上限，正常的认证报文只有几百字节。
+const MaxFrame = 64 * 1024
+
+// Reason 握手报文被拒绝的原因。
+type Reason string
+
+const (
+	ReasonOversize  Reason = "oversize"  // 报文超过大小上限
+	ReasonTruncated Reason = "truncated" // 报文不完整
+	ReasonTimeout   Reason = "timeout"   // 读取超时
+	ReasonMalformed Reason = "malformed" // 报文无法解析（解密、反序列化失败）
+	ReasonInvalid   Reason = "invalid"   // 报文字段校验不通过
+)
+
+// RejectError 握手报文被拒绝的错误。
+type RejectError struct {
+	Reason Reason
+	Err    error
+}
+
+func (e *RejectError) Error() string {
+	if e.Err == nil {
+		return "握手报文被拒绝：" + string(e.Reason)
+	}
+	return fmt.Sprintf("握手报文被拒绝（%s）：%s", e.Reason, e.Err)
+}
+
+func (e *RejectError) Unwrap() error {
+	return e.Err
+}
+
+// Reject 包装错误并附带拒绝原因。
+func Reject(reason Reason, err error) error {
+	return &RejectError{Reason: reason, Err: err}
+}
+
+// Classify 判断错误对应的拒绝原因，无法归类的视为 ReasonMalformed。
+func Classify(err error) Reason {
+	var re *RejectError
+	if errors.As(err, &re) {
+		return re.Reason
+	}
+	var ne net.Error
+	if errors.Is(err, os.ErrDeadlineExceeded) || (errors.As(err, &ne) && ne.Timeout()) {
+		return ReasonTimeout
+	}
+	if errors.Is(err, io.EOF) || errors.Is(err, io.ErrUnexpectedEOF) {
+		return ReasonTruncated
+	}
+
+	return ReasonMalformed
+}
+
+// ReadFrame 读取 4 字节大端长度头 + 报文体格式的报文。
+//
+// 长度超过 limit 时不会分配内存，直接返回 ReasonOversize 错误。
+func ReadFrame(r io.Reader, limit int) ([]byte, error) {
+	head := make([]byte, 4)
+	if _, err := io.ReadFull(r, head); err != nil {
+		return nil, err
+	}
+
+	size := binary.BigEndian.Uint32(head)
+	if uint64(size) > uint64(limit) {
+		return nil, Reject(ReasonOversize, fmt.Errorf("报文长度 %d 超过上限 %d", size, limit))
+	}
+	data := make([]byte, size)
+	if _, err := io.ReadFull(r, data); err != nil {
+		return nil, err
+	}
+
+	return data, nil
+}
+
+// ReadLimit 读取全部报文，超过 limit 时返回 ReasonOversize 错误，不会截断。
+func ReadLimit(r io.Reader, limit int) ([]byte, error) {
+	data, err := io.ReadAll(io.LimitReader(r, int64(limit)+1))
+	if err != nil {
+		return nil, err
+	}
+	if len(data) > limit {
+		return nil, Reject(ReasonOversize, fmt.Errorf("报文长度超过上限 %d", limit))
+	}
+
+	return data, nil
+}
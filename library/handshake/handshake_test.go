@@ -0,0 +1,92 @@
+package handshake
+
+import (
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"testing"
+)
+
+func frame(body []byte) []byte {
+	buf := make([]byte, 4+len(body))
+	binary.BigEndian.PutUint32(buf, uint32(len(body)))
+	copy(buf[4:], body)
+	return buf
+}
+
+func FuzzReadFrame(f *testing.F) {
+	f.Add(frame([]byte(`{"machine_id":"abc"}`)), 64)
+	f.Add(frame(nil), 0)
+	f.Add([]byte{0xff, 0xff, 0xff, 0xff}, MaxFrame)
+	f.Add([]byte{0, 0, 0, 8, 'a'}, MaxFrame)
+	f.Add([]byte{0, 0}, MaxFrame)
+
+	f.Fuzz(func(t *testing.T, raw []byte, limit int) {
+		if limit < 0 || limit > MaxFrame {
+			limit = MaxFrame
+		}
+		data, err := ReadFrame(bytes.NewReader(raw), limit)
+		if err != nil {
+			if data != nil {
+				t.Fatalf("出错时不应返回数据：%v", err)
+			}
+			return
+		}
+		if len(data) > limit {
+			t.Fatalf("报文长度 %d 超过上限 %d", len(data), limit)
+		}
+		if !bytes.Equal(raw[4:4+len(data)], data) {
+			t.Fatalf("读取的报文与原始报文不一致")
+		}
+	})
+}
+
+func FuzzReadLimit(f *testing.F) {
+	f.Add([]byte(`{"machine_id":"abc"}`), 64)
+	f.Add([]byte{}, 0)
+	f.Add(bytes.Repeat([]byte{'a'}, 65), 64)
+
+	f.Fuzz(func(t *testing.T, raw []byte, limit int) {
+		if limit < 0 || limit > MaxFrame {
+			limit = MaxFrame
+		}
+		data, err := ReadLimit(bytes.NewReader(raw), limit)
+		if len(raw) > limit {
+			if Classify(err) != ReasonOversize {
+				t.Fatalf("超过上限时应返回 oversize，实际 %v", err)
+			}
+			return
+		}
+		if err != nil {
+			t.Fatalf("未超过上限时不应出错：%v", err)
+		}
+		if !bytes.Equal(raw, data) {
+			t.Fatalf("读取的报文与原始报文不一致")
+		}
+	})
+}
+
+func TestTrackerEvict(t *testing.T) {
+	tr := NewTracker(3)
+	err := Reject(ReasonInvalid, errors.New("test"))
+	for i := 1; i <= 3; i++ {
+		tr.Record("tunnel", fmt.Sprintf("10.0.0.%d:1234", i), err)
+	}
+	// 10.0.0.1 再次被拒绝，最久未被拒绝的变为 10.0.0.2
+	tr.Record("tunnel", "10.0.0.1:5678", err)
+	tr.Record("tunnel", "10.0.0.4:1234", err)
+
+	recs := tr.Rejections()
+	got := make([]string, 0, len(recs))
+	for _, rec := range recs {
+		got = append(got, rec.IP)
+	}
+	want := []string{"10.0.0.4", "10.0.0.1", "10.0.0.3"}
+	if fmt.Sprint(got) != fmt.Sprint(want) {
+		t.Fatalf("拒绝记录 %v，期望 %v", got, want)
+	}
+	if n := recs[1].Counts[ReasonInvalid]; n != 2 {
+		t.Fatalf("10.0.0.1 的拒绝次数 %d，期望 2", n)
+	}
+}
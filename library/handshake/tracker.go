@@ -0,0 +1,118 @@
+package handshake
+
+import (
+	"container/list"
+	"net"
+	"sync"
+	"time"
+
+	"github.com/vela-ssoc/ssoc-broker/library/metrics"
+)
+
+// Default 默认的拒绝记录，最多记录 4096 个来源 IP。
+var Default = NewTracker(4096)
+
+// Record 使用 Default 记录一次拒绝。
+func Record(transport, addr string, err error) Reason {
+	return Default.Record(transport, addr, err)
+}
+
+// Tracker 按照来源 IP 统计握手报文被拒绝的次数。
+type Tracker interface {
+	// Record 记录一次拒绝，返回拒绝原因。addr 可以是 IP 或 host:port。
+	Record(transport, addr string, err error) Reason
+
+	// Rejections 按照最近被拒绝的时间倒序返回所有记录。
+	Rejections() []*Rejection
+}
+
+// Rejection 单个来源 IP 的拒绝记录。
+type Rejection struct {
+	IP        string            `json:"ip"`
+	Counts    map[Reason]uint64 `json:"counts"`    // 按原因统计的拒绝次数
+	Transport string            `json:"transport"` // 最近一次被拒绝的通道
+	Reason    Reason            `json:"reason"`    // 最近一次被拒绝的原因
+	Error     string            `json:"error"`     // 最近一次被拒绝的错误信息
+	FirstAt   time.Time         `json:"first_at"`
+	LastAt    time.Time         `json:"last_at"`
+}
+
+// NewTracker 创建拒绝记录，超过 capacity 个来源 IP 时淘汰最久未被拒绝的记录。
+func NewTracker(capacity int) Tracker {
+	if capacity <= 0 {
+		capacity = 4096
+	}
+
+	return &ipTracker{
+		capacity: capacity,
+		records:  make(map[string]*list.Element, 64),
+		lru:      list.New(),
+	}
+}
+
+type ipTracker struct {
+	capacity int
+	mutex    sync.Mutex
+	records  map[string]*list.Element // IP -> lru 中的元素，元素的值为 *Rejection
+	lru      *list.List               // 按最近被拒绝的时间排序，最近的在前
+}
+
+func (it *ipTracker) Record(transport, addr string, err error) Reason {
+	reason := Classify(err)
+	metrics.HandshakeRejects.With(transport, string(reason)).Inc()
+
+	ip := addr
+	if host, _, exx := net.SplitHostPort(addr); exx == nil {
+		ip = host
+	}
+	var msg string
+	if err != nil {
+		msg = err.Error()
+	}
+	now := time.Now()
+
+	it.mutex.Lock()
+	defer it.mutex.Unlock()
+
+	var rec *Rejection
+	if elem := it.records[ip]; elem != nil {
+		it.lru.MoveToFront(elem)
+		rec = elem.Value.(*Rejection)
+	} else {
+		if len(it.records) >= it.capacity {
+			it.evict()
+		}
+		rec = &Rejection{IP: ip, Counts: make(map[Reason]uint64, 4), FirstAt: now}
+		it.records[ip] = it.lru.PushFront(rec)
+	}
+	rec.Counts[reason]++
+	rec.Transport, rec.Reason, rec.Error, rec.LastAt = transport, reason, msg, now
+
+	return reason
+}
+
+func (it *ipTracker) Rejections() []*Rejection {
+	it.mutex.Lock()
+	defer it.mutex.Unlock()
+
+	ret := make([]*Rejection, 0, it.lru.Len())
+	for elem := it.lru.Front(); elem != nil; elem = elem.Next() {
+		rec := elem.Value.(*Rejection)
+		cp := *rec
+		cp.Counts = make(map[Reason]uint64, len(rec.Counts))
+		for k, v := range rec.Counts {
+			cp.Counts[k] = v
+		}
+		ret = append(ret, &cp)
+	}
+
+	return ret
+}
+
+// evict 淘汰最久未被拒绝的记录，调用方需持有锁。
+func (it *ipTracker) evict() {
+	if elem := it.lru.Back(); elem != nil {
+		rec := it.lru.Remove(elem).(*Rejection)
+		delete(it.records, rec.IP)
+	}
+}
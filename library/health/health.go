@@ -0,0 +1,244 @@
+package health
+
+import (
+	"context"
+	"encoding/json"
+	"net"
+	"net/http"
+	"sync"
+	"time"
+)
+
+// Report 健康检查报告。
+type Report struct {
+	Live      bool           `json:"live"`              // 进程是否在正常服务（监听正常）
+	Ready     bool           `json:"ready"`             // 是否可以接收新的 agent 连接
+	Reasons   []string       `json:"reasons,omitempty"` // 未就绪的原因
+	Database  DatabaseStatus `json:"database"`
+	Manager   ManagerStatus  `json:"manager"`
+	Listener  ListenerStatus `json:"listener"`
+	Agents    AgentStatus    `json:"agents"`
+	Draining  bool           `json:"draining"`
+	CheckedAt time.Time      `json:"checked_at"`
+}
+
+// DatabaseStatus 数据库连接状态。
+type DatabaseStatus struct {
+	OK        bool    `json:"ok"`
+	LatencyMS float64 `json:"latency_ms"`      // ping 耗时（毫秒）
+	Error     string  `json:"error,omitempty"` // 仅本地接口返回
+}
+
+// ManagerStatus 与中心端通道的状态。
+type ManagerStatus struct {
+	Connected bool    `json:"connected"`
+	State     string  `json:"state,omitempty"`      // 连接阶段
+	Addr      string  `json:"addr,omitempty"`       // 当前连接的中心端地址，仅本地接口返回
+	RTTMS     float64 `json:"rtt_ms"`               // 心跳往返耗时（毫秒）
+	SkewMS    float64 `json:"skew_ms"`              // 中心端时钟减去本地时钟的差值（毫秒）
+	LastError string  `json:"last_error,omitempty"` // 仅本地接口返回
+}
+
+// ListenerStatus agent 接入端口的监听状态。
+type ListenerStatus struct {
+	Serving bool   `json:"serving"`
+	Addr    string `json:"addr,omitempty"` // 仅本地接口返回
+}
+
+// AgentStatus 在线 agent 个数与容量。
+type AgentStatus struct {
+	Online   int  `json:"online"`
+	Capacity int  `json:"capacity"` // 为 0 代表不限制
+	Full     bool `json:"full"`
+}
+
+// Option 健康检查的数据来源，为空的项不参与检查。
+type Option struct {
+	Ping     func(ctx context.Context) error // 数据库 ping
+	Manager  func() ManagerStatus            // 中心端通道状态
+	Listener func() ListenerStatus           // 监听状态
+	Online   func() int                      // 在线 agent 个数
+	Draining func() bool                     // 是否处于排空模式
+	Capacity int                             // agent 容量，为 0 代表不限制
+	Timeout  time.Duration                   // 数据库 ping 超时时间，默认 2 秒
+	CacheTTL time.Duration                   // 检查结果缓存时长，防止探针过于频繁打到数据库，默认 1 秒
+}
+
+// Probe 健康检查探针。
+//
+//   - /healthz 存活检查：监听正常即存活，用于 systemd watchdog、k8s liveness。
+//   - /readyz 就绪检查：排空中、数据库不可用、监听异常、agent 已满时未就绪，用于 k8s readiness 和负载均衡。
+//
+// 对外接口不返回错误详情和内部地址，Local 开头的接口只允许本机访问，返回完整报告。
+//
+// 注意：本机访问通过来源地址是否为回环地址判断，经过本机反向代理转发的请求来源地址同样是回环地址，
+// 所以带有转发请求头（X-Forwarded-For、X-Real-IP、Forwarded）的请求一律拒绝；
+// 对于不添加转发请求头的本机代理无法区分，部署时不要将 Local 接口通过本机代理对外暴露。
+type Probe struct {
+	opt     Option
+	mutex   sync.Mutex
+	last    Report
+	running chan struct{} // 正在执行的检查，执行完毕后关闭，为空说明没有检查在执行
+}
+
+func New(opt Option) *Probe {
+	if opt.Timeout <= 0 {
+		opt.Timeout = 2 * time.Second
+	}
+	if opt.CacheTTL <= 0 {
+		opt.CacheTTL = time.Second
+	}
+
+	return &Probe{opt: opt}
+}
+
+// Check 执行检查，CacheTTL 内重复调用返回缓存的结果。
+//
+// 检查（数据库 ping）在锁外执行，同一时刻只有一个检查在执行，并发的调用等待其结果，
+// 等待期间 ctx 结束则返回上一次的结果。
+func (p *Probe) Check(ctx context.Context) Report {
+	p.mutex.Lock()
+	if now := time.Now(); now.Sub(p.last.CheckedAt) < p.opt.CacheTTL {
+		rpt := p.last
+		p.mutex.Unlock()
+		return rpt
+	}
+	if running := p.running; running != nil {
+		p.mutex.Unlock()
+		select {
+		case <-running:
+		case <-ctx.Done():
+		}
+		p.mutex.Lock()
+		rpt := p.last
+		p.mutex.Unlock()
+		return rpt
+	}
+	running := make(chan struct{})
+	p.running = running
+	p.mutex.Unlock()
+
+	// 调用方断开不能影响检查结果，否则会缓存一份错误的结果，检查本身有超时时间。
+	rpt := p.check(context.WithoutCancel(ctx))
+
+	p.mutex.Lock()
+	p.last, p.running = rpt, nil
+	p.mutex.Unlock()
+	close(running)
+
+	return rpt
+}
+
+func (p *Probe) Healthz(w http.ResponseWriter, r *http.Request) {
+	rpt := p.Check(r.Context())
+	p.write(w, rpt.redact(), rpt.Live)
+}
+
+func (p *Probe) Readyz(w http.ResponseWriter, r *http.Request) {
+	rpt := p.Check(r.Context())
+	p.write(w, rpt.redact(), rpt.Ready)
+}
+
+func (p *Probe) LocalHealthz(w http.ResponseWriter, r *http.Request) {
+	if !loopback(r) {
+		w.WriteHeader(http.StatusForbidden)
+		return
+	}
+	rpt := p.Check(r.Context())
+	p.write(w, rpt, rpt.Live)
+}
+
+func (p *Probe) LocalReadyz(w http.ResponseWriter, r *http.Request) {
+	if !loopback(r) {
+		w.WriteHeader(http.StatusForbidden)
+		return
+	}
+	rpt := p.Check(r.Context())
+	p.write(w, rpt, rpt.Ready)
+}
+
+func (p *Probe) check(parent context.Context) Report {
+	rpt := Report{Live: true, Ready: true, CheckedAt: time.Now()}
+	notReady := func(reason string) {
+		rpt.Ready = false
+		rpt.Reasons = append(rpt.Reasons, reason)
+	}
+
+	if fn := p.opt.Listener; fn != nil {
+		rpt.Listener = fn()
+		if !rpt.Listener.Serving {
+			rpt.Live = false
+			notReady("监听未就绪")
+		}
+	}
+	if fn := p.opt.Ping; fn != nil {
+		ctx, cancel := context.WithTimeout(parent, p.opt.Timeout)
+		start := time.Now()
+		err := fn(ctx)
+		cancel()
+		rpt.Database.LatencyMS = Millis(time.Since(start))
+		if rpt.Database.OK = err == nil; !rpt.Database.OK {
+			rpt.Database.Error = err.Error()
+			notReady("数据库不可用")
+		}
+	}
+	if fn := p.opt.Manager; fn != nil {
+		rpt.Manager = fn()
+	}
+	if fn := p.opt.Draining; fn != nil {
+		if rpt.Draining = fn(); rpt.Draining {
+			notReady("正在排空")
+		}
+	}
+	rpt.Agents.Capacity = p.opt.Capacity
+	if fn := p.opt.Online; fn != nil {
+		rpt.Agents.Online = fn()
+		if capacity := p.opt.Capacity; capacity > 0 && rpt.Agents.Online >= capacity {
+			rpt.Agents.Full = true
+			notReady("agent 连接数已达上限")
+		}
+	}
+
+	return rpt
+}
+
+func (*Probe) write(w http.ResponseWriter, rpt Report, ok bool) {
+	code := http.StatusOK
+	if !ok {
+		code = http.StatusServiceUnavailable
+	}
+	w.Header().Set("Content-Type", "application/json; charset=utf-8")
+	w.Header().Set("Cache-Control", "no-store")
+	w.WriteHeader(code)
+	_ = json.NewEncoder(w).Encode(rpt)
+}
+
+// redact 去掉错误详情和内部地址。
+func (r Report) redact() Report {
+	r.Database.Error = ""
+	r.Manager.Addr, r.Manager.LastError = "", ""
+	r.Listener.Addr = ""
+
+	return r
+}
+
+// loopback 请求是否直接来自本机，经过代理转发的请求不算。
+func loopback(r *http.Request) bool {
+	for _, k := range []string{"X-Forwarded-For", "X-Real-IP", "Forwarded"} {
+		if r.Header.Get(k) != "" {
+			return false
+		}
+	}
+	host, _, err := net.SplitHostPort(r.RemoteAddr)
+	if err != nil {
+		return false
+	}
+	ip := net.ParseIP(host)
+
+	return ip != nil && ip.IsLoopback()
+}
+
+// Millis 将时长转为毫秒。
+func Millis(d time.Duration) float64 {
+	return float64(d.Microseconds()) / 1000
+}
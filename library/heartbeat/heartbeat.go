@@ -0,0 +1,291 @@
+package heartbeat
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log/slog"
+	"net"
+	"net/http"
+	"sync"
+	"time"
+)
+
+// Path 心跳接口，支持升级为专用心跳流。
+const Path = "/api/v1/broker/heartbeat"
+
+// Upgrade 心跳流的协议升级名。
+const Upgrade = "ssoc-heartbeat"
+
+// errUnsupported 中心端没有心跳接口。
+var errUnsupported = errors.New("中心端不支持心跳接口")
+
+// Dialer 向中心端开启一个虚拟子流。
+type Dialer func(ctx context.Context) (net.Conn, error)
+
+// Option 心跳参数。
+type Option struct {
+	Host      string        // 请求中心端时使用的 Host
+	Interval  time.Duration // 心跳间隔，默认 15s
+	Timeout   time.Duration // 单次心跳超时时间，默认 5s
+	MaxMissed int           // 连续丢失该次数的心跳后强制重连，默认 3 次
+}
+
+func (opt Option) format() Option {
+	if opt.Interval <= 0 {
+		opt.Interval = 15 * time.Second
+	}
+	if opt.Timeout <= 0 {
+		opt.Timeout = 5 * time.Second
+	}
+	if opt.MaxMissed <= 0 {
+		opt.MaxMissed = 3
+	}
+
+	return opt
+}
+
+// Stats 心跳统计信息。
+type Stats struct {
+	Mode       string        `json:"mode"`         // stream：专用心跳流，http：中心端不支持心跳流时降级为 HTTP 探测，unsupported：中心端没有心跳接口
+	RTT        time.Duration `json:"rtt"`          // 最近一次往返耗时
+	AvgRTT     time.Duration `json:"avg_rtt"`      // 往返耗时滑动平均值
+	Skew       time.Duration `json:"skew"`         // 中心端时钟减去本地时钟的差值
+	LastPongAt time.Time     `json:"last_pong_at"` // 最近一次收到响应的时间
+	Missed     int           `json:"missed"`       // 当前连续丢失的心跳次数
+	Sent       uint64        `json:"sent"`         // 累计发送心跳次数
+	Lost       uint64        `json:"lost"`         // 累计丢失心跳次数
+	Reconnects uint64        `json:"reconnects"`   // 因心跳丢失强制重连的次数
+	LastError  string        `json:"last_error"`   // 最近一次心跳错误
+}
+
+// Healthy 最近是否收到过心跳响应。
+func (s Stats) Healthy() bool {
+	return s.Missed == 0 && !s.LastPongAt.IsZero()
+}
+
+// Monitor 应用层心跳：通过专用虚拟子流与中心端 ping/pong，测量往返耗时与时钟偏差，
+// 连续丢失多次心跳后调用 dead 回调强制重连。
+type Monitor interface {
+	// Run 运行心跳，直至 ctx 取消。
+	Run(ctx context.Context)
+
+	// Stats 心跳统计信息。
+	Stats() Stats
+}
+
+// New 创建心跳，dead 在连续丢失心跳次数达到上限时调用（如：关闭底层连接触发重连）。
+func New(dial Dialer, opt Option, dead func(reason string), log *slog.Logger) Monitor {
+	return &monitor{
+		dial: dial,
+		opt:  opt.format(),
+		dead: dead,
+		log:  log,
+	}
+}
+
+type frame struct {
+	Seq      uint64    `json:"seq"`
+	SentAt   time.Time `json:"sent_at"`
+	ServerAt time.Time `json:"server_at,omitempty"`
+}
+
+type monitor struct {
+	dial Dialer
+	opt  Option
+	dead func(string)
+	log  *slog.Logger
+
+	mutex sync.Mutex
+	stats Stats
+
+	// 以下字段只在 Run 协程中使用
+	conn   net.Conn
+	reader *bufio.Reader
+	seq    uint64
+}
+
+func (m *monitor) Run(ctx context.Context) {
+	ticker := time.NewTicker(m.opt.Interval)
+	defer ticker.Stop()
+	defer m.closeStream()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+
+		rtt, serverAt, mode, err := m.beat(ctx)
+		m.observe(rtt, serverAt, mode, err)
+	}
+}
+
+func (m *monitor) Stats() Stats {
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+
+	return m.stats
+}
+
+// beat 发送一次心跳：优先使用专用心跳流，中心端不支持时降级为 HTTP 探测。
+func (m *monitor) beat(parent context.Context) (time.Duration, time.Time, string, error) {
+	ctx, cancel := context.WithTimeout(parent, m.opt.Timeout)
+	defer cancel()
+
+	if m.conn == nil {
+		upgraded, rtt, serverAt, err := m.openStream(ctx)
+		if errors.Is(err, errUnsupported) {
+			return rtt, serverAt, "unsupported", nil
+		}
+		if err != nil || !upgraded {
+			return rtt, serverAt, "http", err
+		}
+	}
+
+	rtt, serverAt, err := m.ping(ctx)
+	if err != nil {
+		m.closeStream()
+	}
+
+	return rtt, serverAt, "stream", err
+}
+
+// openStream 开启虚拟子流并请求升级为心跳流，中心端不支持升级时，
+// 该次 HTTP 往返（响应 2xx）即作为一次心跳（使用 Date 响应头估算时钟偏差）。
+func (m *monitor) openStream(ctx context.Context) (bool, time.Duration, time.Time, error) {
+	start := time.Now()
+	conn, err := m.dial(ctx)
+	if err != nil {
+		return false, 0, time.Time{}, err
+	}
+	if deadline, ok := ctx.Deadline(); ok {
+		_ = conn.SetDeadline(deadline)
+	}
+
+	reqURL := "http://" + m.opt.Host + Path
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, reqURL, nil)
+	if err != nil {
+		_ = conn.Close()
+		return false, 0, time.Time{}, err
+	}
+	req.Header.Set("Connection", "Upgrade")
+	req.Header.Set("Upgrade", Upgrade)
+	if err = req.Write(conn); err != nil {
+		_ = conn.Close()
+		return false, 0, time.Time{}, err
+	}
+
+	reader := bufio.NewReader(conn)
+	res, err := http.ReadResponse(reader, req)
+	if err != nil {
+		_ = conn.Close()
+		return false, 0, time.Time{}, err
+	}
+	rtt := time.Since(start)
+
+	if code := res.StatusCode; code != http.StatusSwitchingProtocols {
+		_ = res.Body.Close()
+		_ = conn.Close()
+		// 中心端还没有心跳接口（老版本），请求能得到响应说明通道是通的，不计为丢失，
+		// 否则每隔几次心跳就会强制重连一次，中断所有正在进行的调用。
+		if code == http.StatusNotFound || code == http.StatusMethodNotAllowed {
+			return false, rtt, time.Time{}, errUnsupported
+		}
+		// 其余非 2xx 说明请求并未被正确处理（如：代理异常）。
+		if code < 200 || code >= 300 {
+			return false, rtt, time.Time{}, fmt.Errorf("心跳探测响应状态码 %d", code)
+		}
+		serverAt, _ := http.ParseTime(res.Header.Get("Date"))
+		if !serverAt.IsZero() {
+			// Date 只精确到秒，按照往返中点对齐
+			serverAt = serverAt.Add(500 * time.Millisecond)
+		}
+		return false, rtt, serverAt, nil
+	}
+
+	m.conn, m.reader = conn, reader
+
+	return true, rtt, time.Time{}, nil
+}
+
+func (m *monitor) ping(ctx context.Context) (time.Duration, time.Time, error) {
+	if deadline, ok := ctx.Deadline(); ok {
+		_ = m.conn.SetDeadline(deadline)
+	}
+
+	m.seq++
+	start := time.Now()
+	ping := &frame{Seq: m.seq, SentAt: start}
+	data, _ := json.Marshal(ping)
+	if _, err := m.conn.Write(append(data, '\n')); err != nil {
+		return 0, time.Time{}, err
+	}
+
+	for {
+		line, err := m.reader.ReadBytes('\n')
+		if err != nil {
+			return 0, time.Time{}, err
+		}
+		pong := new(frame)
+		if err = json.Unmarshal(line, pong); err != nil {
+			return 0, time.Time{}, err
+		}
+		if pong.Seq == ping.Seq { // 丢弃迟到的旧响应
+			return time.Since(start), pong.ServerAt, nil
+		}
+	}
+}
+
+func (m *monitor) closeStream() {
+	if m.conn != nil {
+		_ = m.conn.Close()
+		m.conn, m.reader = nil, nil
+	}
+}
+
+// observe 记录心跳结果，连续丢失心跳次数达到上限则强制重连。
+func (m *monitor) observe(rtt time.Duration, serverAt time.Time, mode string, err error) {
+	now := time.Now()
+	m.mutex.Lock()
+	st := &m.stats
+	st.Sent++
+	st.Mode = mode
+	var reason string
+	if err != nil {
+		st.Lost++
+		st.Missed++
+		st.LastError = err.Error()
+		if st.Missed >= m.opt.MaxMissed {
+			st.Reconnects++
+			st.Missed = 0
+			reason = fmt.Sprintf("连续 %d 次心跳无响应：%s", m.opt.MaxMissed, err)
+		}
+	} else {
+		st.Missed = 0
+		st.RTT = rtt
+		st.LastPongAt = now
+		if st.AvgRTT <= 0 {
+			st.AvgRTT = rtt
+		} else {
+			st.AvgRTT = (st.AvgRTT*4 + rtt) / 5
+		}
+		if !serverAt.IsZero() {
+			// 中心端时间对应本地的往返中点
+			st.Skew = serverAt.Sub(now.Add(-rtt / 2))
+		}
+	}
+	m.mutex.Unlock()
+
+	if reason == "" {
+		return
+	}
+	m.closeStream()
+	m.log.Warn("与中心端的心跳丢失，强制重连", slog.String("reason", reason))
+	if m.dead != nil {
+		m.dead(reason)
+	}
+}
@@ -0,0 +1,199 @@
+package hookbus
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"io"
+	"log/slog"
+	"sync"
+	"time"
+)
+
+// Kind 节点生命周期事件类型。
+type Kind string
+
+const (
+	KindCreated      Kind = "created"      // 新节点注册
+	KindConnected    Kind = "connected"    // 节点上线
+	KindRepeated     Kind = "repeated"     // 节点重复上线
+	KindDisconnected Kind = "disconnected" // 节点下线
+	KindTakeover     Kind = "takeover"     // 原会话失效，由新会话接管
+	KindQuarantined  Kind = "quarantined"  // 节点身份校验不通过，已被隔离
+	KindCollided     Kind = "collided"     // 节点机器码与其它主机冲突
+)
+
+// Event 节点生命周期事件。
+type Event struct {
+	ID        string        `json:"id"`
+	Kind      Kind          `json:"kind"`
+	MinionID  int64         `json:"minion_id,string"`
+	Inet      string        `json:"inet"`
+	MachineID string        `json:"machine_id,omitempty"`
+	Semver    string        `json:"semver,omitempty"`
+	BrokerID  int64         `json:"broker_id,string"`
+	Duration  time.Duration `json:"duration,omitempty"` // 下线事件：本次会话时长
+	Reason    string        `json:"reason,omitempty"`   // 接管、隔离、冲突事件：原因
+	OccurAt   time.Time     `json:"occur_at"`
+}
+
+// Subscriber 事件订阅者。
+type Subscriber interface {
+	// Name 订阅者名字，用于日志。
+	Name() string
+
+	// Notify 处理事件，同一订阅者的事件按照发布顺序串行调用。
+	// 总线关闭超时后 ctx 会被取消，订阅者应当尽快返回（如：停止重试）。
+	Notify(ctx context.Context, evt *Event) error
+}
+
+// Bus 节点生命周期事件总线，将事件分发给所有订阅者。
+//
+// 每个订阅者有独立的队列与协程，慢订阅者（如：webhook 重试）不会阻塞节点上下线流程，
+// 也不会影响其它订阅者，队列满时丢弃该订阅者的事件并记录日志。
+type Bus interface {
+	// Subscribe 添加订阅者。
+	Subscribe(sub Subscriber)
+
+	// Publish 发布事件，不会阻塞。
+	Publish(evt *Event)
+
+	// Close 停止分发，等待各订阅者处理完队列中的事件。
+	// ctx 结束时取消正在处理的事件并丢弃队列中剩余的事件，返回 ctx 的错误。
+	// 实现了 io.Closer 的订阅者在队列处理完毕后会被关闭。
+	Close(ctx context.Context) error
+}
+
+// queueSize 每个订阅者的队列长度。
+const queueSize = 4096
+
+func New(log *slog.Logger) Bus {
+	ctx, cancel := context.WithCancel(context.Background())
+	return &eventBus{log: log, ctx: ctx, cancel: cancel}
+}
+
+type eventBus struct {
+	log    *slog.Logger
+	ctx    context.Context // 传递给订阅者，关闭超时后取消
+	cancel context.CancelFunc
+	mutex  sync.RWMutex
+	subs   []*subscription
+	closed bool
+	wg     sync.WaitGroup
+}
+
+func (eb *eventBus) Subscribe(sub Subscriber) {
+	eb.mutex.Lock()
+	defer eb.mutex.Unlock()
+	if eb.closed {
+		return
+	}
+
+	s := &subscription{sub: sub, queue: make(chan *Event, queueSize)}
+	eb.subs = append(eb.subs, s)
+	eb.wg.Add(1)
+	go eb.serve(s)
+}
+
+func (eb *eventBus) Publish(evt *Event) {
+	if evt.ID == "" {
+		buf := make([]byte, 16)
+		_, _ = rand.Read(buf)
+		evt.ID = hex.EncodeToString(buf)
+	}
+	if evt.OccurAt.IsZero() {
+		evt.OccurAt = time.Now()
+	}
+
+	eb.mutex.RLock()
+	defer eb.mutex.RUnlock()
+	if eb.closed {
+		return
+	}
+
+	for _, s := range eb.subs {
+		select {
+		case s.queue <- evt:
+		default:
+			eb.log.Warn("生命周期事件订阅者队列已满，丢弃事件",
+				slog.String("subscriber", s.sub.Name()), slog.String("kind", string(evt.Kind)),
+				slog.Int64("minion_id", evt.MinionID))
+		}
+	}
+}
+
+func (eb *eventBus) Close(ctx context.Context) error {
+	eb.mutex.Lock()
+	if !eb.closed {
+		eb.closed = true
+		for _, s := range eb.subs {
+			close(s.queue)
+		}
+	}
+	eb.mutex.Unlock()
+
+	done := make(chan struct{})
+	go func() {
+		eb.wg.Wait()
+		close(done)
+	}()
+
+	select {
+	case <-done:
+		eb.cancel()
+		return nil
+	case <-ctx.Done():
+	}
+
+	// 超时：取消正在处理的事件，剩余事件由 serve 丢弃。
+	eb.cancel()
+	<-done
+
+	return ctx.Err()
+}
+
+func (eb *eventBus) serve(s *subscription) {
+	defer eb.wg.Done()
+
+	var dropped int
+	for evt := range s.queue {
+		if eb.ctx.Err() != nil {
+			dropped++
+			continue
+		}
+		if err := s.sub.Notify(eb.ctx, evt); err != nil {
+			eb.log.Warn("生命周期事件订阅者处理出错",
+				slog.String("subscriber", s.sub.Name()), slog.String("kind", string(evt.Kind)),
+				slog.Int64("minion_id", evt.MinionID), slog.Any("error", err))
+		}
+	}
+	if dropped != 0 {
+		eb.log.Warn("生命周期事件总线关闭超时，丢弃未处理的事件",
+			slog.String("subscriber", s.sub.Name()), slog.Int("dropped", dropped))
+	}
+
+	if c, ok := s.sub.(io.Closer); ok {
+		if err := c.Close(); err != nil {
+			eb.log.Warn("关闭生命周期事件订阅者出错", slog.String("subscriber", s.sub.Name()), slog.Any("error", err))
+		}
+	}
+}
+
+type subscription struct {
+	sub   Subscriber
+	queue chan *Event
+}
+
+// SubscriberFunc 将函数包装为订阅者。
+func SubscriberFunc(name string, fn func(ctx context.Context, evt *Event) error) Subscriber {
+	return &funcSubscriber{name: name, fn: fn}
+}
+
+type funcSubscriber struct {
+	name string
+	fn   func(context.Context, *Event) error
+}
+
+func (fs *funcSubscriber) Name() string { return fs.name }
+
+func (fs *funcSubscriber) Notify(ctx context.Context, evt *Event) error { return fs.fn(ctx, evt) }
@@ -0,0 +1,145 @@
+package hookbus
+
+import (
+	"context"
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"sync"
+	"time"
+)
+
+// JournalOption 本地事件日志参数，为零的字段使用默认值。
+type JournalOption struct {
+	Path     string        `json:"path"     yaml:"path"`     // 日志文件路径，默认 resources/hooks/journal.log
+	MaxSize  int64         `json:"max_size" yaml:"max_size"` // 文件超过该大小时轮转，默认 64MiB
+	Interval time.Duration `json:"interval" yaml:"interval"` // 落盘间隔，默认 1s
+}
+
+func (opt JournalOption) format() JournalOption {
+	if opt.Path == "" {
+		opt.Path = "resources/hooks/journal.log"
+	}
+	if opt.MaxSize <= 0 {
+		opt.MaxSize = 64 * 1024 * 1024
+	}
+	if opt.Interval <= 0 {
+		opt.Interval = time.Second
+	}
+
+	return opt
+}
+
+// NewJournal 本地事件日志，每个事件一行 JSON。
+//
+// 写入后不会立即落盘，而是每隔 Interval 合并落盘一次，关闭时落盘剩余的数据，
+// 进程崩溃时最多丢失一个间隔内的事件。
+// 文件超过 MaxSize 时将其重命名为 *.1 后重新创建（只保留一个历史文件）。
+func NewJournal(opt JournalOption) (Subscriber, error) {
+	opt = opt.format()
+	if err := os.MkdirAll(filepath.Dir(opt.Path), 0o755); err != nil {
+		return nil, err
+	}
+
+	jn := &journal{opt: opt}
+	if err := jn.open(); err != nil {
+		return nil, err
+	}
+
+	return jn, nil
+}
+
+type journal struct {
+	opt   JournalOption
+	mutex sync.Mutex
+	file  *os.File
+	size  int64
+	dirty bool        // 有写入但尚未落盘的数据
+	timer *time.Timer // 延迟落盘定时器，为空说明没有待执行的落盘
+}
+
+func (*journal) Name() string { return "journal" }
+
+func (jn *journal) Notify(_ context.Context, evt *Event) error {
+	line, err := json.Marshal(evt)
+	if err != nil {
+		return err
+	}
+	line = append(line, '\n')
+
+	jn.mutex.Lock()
+	defer jn.mutex.Unlock()
+
+	if jn.size+int64(len(line)) > jn.opt.MaxSize {
+		if err = jn.rotate(); err != nil {
+			return err
+		}
+	}
+	n, err := jn.file.Write(line)
+	jn.size += int64(n)
+	jn.dirty = true
+	if jn.timer == nil {
+		jn.timer = time.AfterFunc(jn.opt.Interval, jn.flush)
+	}
+
+	return err
+}
+
+// Close 落盘剩余的数据并关闭文件。
+func (jn *journal) Close() error {
+	jn.mutex.Lock()
+	defer jn.mutex.Unlock()
+
+	if jn.timer != nil {
+		jn.timer.Stop()
+		jn.timer = nil
+	}
+	err := jn.sync()
+	if exx := jn.file.Close(); err == nil {
+		err = exx
+	}
+
+	return err
+}
+
+func (jn *journal) flush() {
+	jn.mutex.Lock()
+	defer jn.mutex.Unlock()
+
+	jn.timer = nil
+	_ = jn.sync()
+}
+
+func (jn *journal) sync() error {
+	if !jn.dirty {
+		return nil
+	}
+	jn.dirty = false
+
+	return jn.file.Sync()
+}
+
+func (jn *journal) open() error {
+	f, err := os.OpenFile(jn.opt.Path, os.O_CREATE|os.O_WRONLY|os.O_APPEND, 0o644)
+	if err != nil {
+		return err
+	}
+	stat, err := f.Stat()
+	if err != nil {
+		_ = f.Close()
+		return err
+	}
+	jn.file, jn.size, jn.dirty = f, stat.Size(), false
+
+	return nil
+}
+
+func (jn *journal) rotate() error {
+	_ = jn.sync()
+	_ = jn.file.Close()
+	if err := os.Rename(jn.opt.Path, jn.opt.Path+".1"); err != nil && !os.IsNotExist(err) {
+		return err
+	}
+
+	return jn.open()
+}
@@ -0,0 +1,34 @@
+package hookbus
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+
+	"github.com/vela-ssoc/ssoc-common-mb/dal/query"
+)
+
+// BucketWebhook webhook 订阅配置存放的 kv_data 存储桶，key 为 webhook 名字，value 为 WebhookConfig。
+const BucketWebhook = "broker-webhook"
+
+// LoadWebhooks 从数据库加载 webhook 订阅者，格式错误或 URL 为空的配置会被忽略。
+func LoadWebhooks(ctx context.Context, qry *query.Query, cli *http.Client) ([]Subscriber, error) {
+	tbl := qry.KVData
+	dats, err := tbl.WithContext(ctx).
+		Where(tbl.Bucket.Eq(BucketWebhook)).
+		Find()
+	if err != nil {
+		return nil, err
+	}
+
+	subs := make([]Subscriber, 0, len(dats))
+	for _, dat := range dats {
+		cfg := WebhookConfig{Name: dat.Key}
+		if exx := json.Unmarshal(dat.Value, &cfg); exx != nil || cfg.URL == "" {
+			continue
+		}
+		subs = append(subs, NewWebhook(cfg, cli))
+	}
+
+	return subs, nil
+}
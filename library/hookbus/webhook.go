@@ -0,0 +1,119 @@
+package hookbus
+
+import (
+	"bytes"
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"slices"
+	"strconv"
+	"time"
+)
+
+// HeaderSignature webhook 报文签名：hex(HMAC-SHA256(secret, timestamp + "." + body))。
+const (
+	HeaderSignature = "X-Hook-Signature"
+	HeaderTimestamp = "X-Hook-Timestamp"
+	HeaderEventID   = "X-Hook-Event"
+)
+
+// WebhookConfig webhook 订阅参数。
+type WebhookConfig struct {
+	Name     string        `json:"name"`
+	URL      string        `json:"url"`
+	Secret   string        `json:"secret"`    // 签名密钥，为空则不签名
+	Kinds    []Kind        `json:"kinds"`     // 订阅的事件类型，为空代表全部
+	MaxRetry int           `json:"max_retry"` // 最大重试次数，默认 5 次
+	Timeout  time.Duration `json:"timeout"`   // 单次请求超时时间，默认 10s
+}
+
+// NewWebhook HTTP webhook 订阅者，推送失败按照指数退避重试。
+func NewWebhook(cfg WebhookConfig, cli *http.Client) Subscriber {
+	if cfg.MaxRetry <= 0 {
+		cfg.MaxRetry = 5
+	}
+	if cfg.Timeout <= 0 {
+		cfg.Timeout = 10 * time.Second
+	}
+	if cfg.Name == "" {
+		cfg.Name = cfg.URL
+	}
+	if cli == nil {
+		cli = http.DefaultClient
+	}
+
+	return &webhook{cfg: cfg, cli: cli}
+}
+
+type webhook struct {
+	cfg WebhookConfig
+	cli *http.Client
+}
+
+func (wh *webhook) Name() string { return "webhook:" + wh.cfg.Name }
+
+func (wh *webhook) Notify(ctx context.Context, evt *Event) error {
+	if len(wh.cfg.Kinds) != 0 && !slices.Contains(wh.cfg.Kinds, evt.Kind) {
+		return nil
+	}
+
+	body, err := json.Marshal(evt)
+	if err != nil {
+		return err
+	}
+
+	backoff := time.Second
+	for i := 0; ; i++ {
+		if err = wh.send(ctx, evt.ID, body); err == nil || i >= wh.cfg.MaxRetry {
+			return err
+		}
+
+		timer := time.NewTimer(backoff)
+		select {
+		case <-ctx.Done():
+			timer.Stop()
+			return ctx.Err()
+		case <-timer.C:
+		}
+		backoff = min(2*backoff, time.Minute)
+	}
+}
+
+func (wh *webhook) send(parent context.Context, id string, body []byte) error {
+	ctx, cancel := context.WithTimeout(parent, wh.cfg.Timeout)
+	defer cancel()
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, wh.cfg.URL, bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	ts := strconv.FormatInt(time.Now().Unix(), 10)
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set(HeaderEventID, id)
+	req.Header.Set(HeaderTimestamp, ts)
+	if secret := wh.cfg.Secret; secret != "" {
+		mac := hmac.New(sha256.New, []byte(secret))
+		mac.Write([]byte(ts + "."))
+		mac.Write(body)
+		req.Header.Set(HeaderSignature, hex.EncodeToString(mac.Sum(nil)))
+	}
+
+	res, err := wh.cli.Do(req)
+	if err != nil {
+		return err
+	}
+	//goland:noinspection GoUnhandledErrorResult
+	defer res.Body.Close()
+	_, _ = io.Copy(io.Discard, io.LimitReader(res.Body, 4096))
+
+	if code := res.StatusCode; code < 200 || code >= 300 {
+		return fmt.Errorf("webhook 响应状态码 %d", code)
+	}
+
+	return nil
+}
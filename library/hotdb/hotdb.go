@@ -0,0 +1,214 @@
+package hotdb
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"log/slog"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"gorm.io/gorm"
+)
+
+// retireAfter DSN 变更后旧连接池延迟关闭的时长，让正在执行的 SQL 自然结束。
+const retireAfter = time.Minute
+
+// Config 数据库连接配置。
+type Config struct {
+	DSN         string        `json:"-"`
+	MaxOpenConn int           `json:"max_open_conn"`
+	MaxIdleConn int           `json:"max_idle_conn"`
+	MaxLifeTime time.Duration `json:"max_life_time"`
+	MaxIdleTime time.Duration `json:"max_idle_time"`
+}
+
+// LogValue 实现 slog.LogValuer，日志中不输出 DSN（包含数据库密码）。
+func (c Config) LogValue() slog.Value {
+	return slog.GroupValue(
+		slog.Int("max_open_conn", c.MaxOpenConn),
+		slog.Int("max_idle_conn", c.MaxIdleConn),
+		slog.Duration("max_life_time", c.MaxLifeTime),
+		slog.Duration("max_idle_time", c.MaxIdleTime),
+	)
+}
+
+// Opener 根据 DSN 连接数据库。
+type Opener func(dsn string) (*gorm.DB, error)
+
+// DB 可以在运行时调整连接池参数、更换 DSN 的数据库连接。
+//
+// 更换 DSN 时只替换 *gorm.DB 底层的 *sql.DB，已经通过 Gorm 派生出的查询对象无需重建。
+type DB interface {
+	// Gorm 返回数据库连接，在整个生命周期内保持不变。
+	Gorm() *gorm.DB
+
+	// Config 当前生效的配置。
+	Config() Config
+
+	// Ping 检查当前连接池是否可用。
+	Ping(ctx context.Context) error
+
+	// Apply 应用新的配置：连接池参数直接生效，DSN 变化时会建立新连接池并原子替换。
+	Apply(ctx context.Context, cfg Config) error
+
+	// Close 关闭数据库连接。
+	Close() error
+}
+
+func Open(cfg Config, open Opener, log *slog.Logger) (DB, error) {
+	gdb, err := open(cfg.DSN)
+	if err != nil {
+		return nil, err
+	}
+	sdb, err := gdb.DB()
+	if err != nil {
+		return nil, err
+	}
+	limit(sdb, cfg)
+
+	pool := new(swapPool)
+	pool.ptr.Store(sdb)
+	gdb.ConnPool = pool
+	gdb.Statement.ConnPool = pool
+
+	return &hotDB{gdb: gdb, pool: pool, open: open, cfg: cfg, log: log}, nil
+}
+
+type hotDB struct {
+	gdb   *gorm.DB
+	pool  *swapPool
+	open  Opener
+	log   *slog.Logger
+	mutex sync.Mutex
+	cfg   Config
+}
+
+func (hd *hotDB) Gorm() *gorm.DB {
+	return hd.gdb
+}
+
+func (hd *hotDB) Config() Config {
+	hd.mutex.Lock()
+	defer hd.mutex.Unlock()
+
+	return hd.cfg
+}
+
+func (hd *hotDB) Ping(ctx context.Context) error {
+	return hd.pool.load().PingContext(ctx)
+}
+
+func (hd *hotDB) Apply(ctx context.Context, cfg Config) error {
+	hd.mutex.Lock()
+	defer hd.mutex.Unlock()
+
+	if cfg.DSN == "" {
+		cfg.DSN = hd.cfg.DSN
+	}
+	if cfg.DSN == hd.cfg.DSN {
+		if cfg != hd.cfg {
+			limit(hd.pool.load(), cfg)
+			hd.log.Info("数据库连接池参数已更新", slog.Any("old", hd.cfg), slog.Any("new", cfg))
+			hd.cfg = cfg
+		}
+		return nil
+	}
+
+	sdb, err := hd.connect(ctx, cfg.DSN)
+	if err != nil {
+		hd.log.Error("数据库 DSN 变更，新连接建立失败，继续使用原连接", slog.Any("error", err))
+		return err
+	}
+	limit(sdb, cfg)
+	old := hd.pool.swap(sdb)
+	hd.cfg = cfg
+	hd.log.Warn("数据库 DSN 已变更，已切换到新的连接池", slog.Duration("retire_after", retireAfter))
+	time.AfterFunc(retireAfter, func() { _ = old.Close() })
+
+	return nil
+}
+
+func (hd *hotDB) Close() error {
+	return hd.pool.load().Close()
+}
+
+// connect 建立新的连接池，数据库类型必须与当前的一致（SQL 方言在启动时已确定）。
+func (hd *hotDB) connect(ctx context.Context, dsn string) (*sql.DB, error) {
+	gdb, err := hd.open(dsn)
+	if err != nil {
+		return nil, err
+	}
+	sdb, err := gdb.DB()
+	if err != nil {
+		return nil, err
+	}
+	if want, got := hd.gdb.Dialector.Name(), gdb.Dialector.Name(); want != got {
+		_ = sdb.Close()
+		return nil, fmt.Errorf("数据库类型由 %s 变更为 %s，需要重启程序", want, got)
+	}
+	if err = sdb.PingContext(ctx); err != nil {
+		_ = sdb.Close()
+		return nil, err
+	}
+
+	return sdb, nil
+}
+
+func limit(sdb *sql.DB, cfg Config) {
+	sdb.SetMaxOpenConns(cfg.MaxOpenConn)
+	sdb.SetMaxIdleConns(cfg.MaxIdleConn)
+	sdb.SetConnMaxLifetime(cfg.MaxLifeTime)
+	sdb.SetConnMaxIdleTime(cfg.MaxIdleTime)
+}
+
+var errClosedPool = errors.New("数据库连接池未初始化")
+
+// swapPool 实现 gorm.ConnPool，底层 *sql.DB 可被原子替换。
+type swapPool struct {
+	ptr atomic.Pointer[sql.DB]
+}
+
+func (sp *swapPool) PrepareContext(ctx context.Context, query string) (*sql.Stmt, error) {
+	return sp.load().PrepareContext(ctx, query)
+}
+
+func (sp *swapPool) ExecContext(ctx context.Context, query string, args ...any) (sql.Result, error) {
+	return sp.load().ExecContext(ctx, query, args...)
+}
+
+func (sp *swapPool) QueryContext(ctx context.Context, query string, args ...any) (*sql.Rows, error) {
+	return sp.load().QueryContext(ctx, query, args...)
+}
+
+func (sp *swapPool) QueryRowContext(ctx context.Context, query string, args ...any) *sql.Row {
+	return sp.load().QueryRowContext(ctx, query, args...)
+}
+
+// BeginTx 实现 gorm.TxBeginner。
+func (sp *swapPool) BeginTx(ctx context.Context, opts *sql.TxOptions) (*sql.Tx, error) {
+	return sp.load().BeginTx(ctx, opts)
+}
+
+// GetDBConn 实现 gorm.GetDBConnector，使 (*gorm.DB).DB() 返回当前生效的连接池。
+func (sp *swapPool) GetDBConn() (*sql.DB, error) {
+	if sdb := sp.load(); sdb != nil {
+		return sdb, nil
+	}
+
+	return nil, errClosedPool
+}
+
+func (sp *swapPool) Ping() error {
+	return sp.load().Ping()
+}
+
+func (sp *swapPool) load() *sql.DB {
+	return sp.ptr.Load()
+}
+
+func (sp *swapPool) swap(sdb *sql.DB) *sql.DB {
+	return sp.ptr.Swap(sdb)
+}
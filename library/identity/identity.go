@@ -0,0 +1,418 @@
+package identity
+
+import (
+	"container/list"
+	"context"
+	"crypto/ed25519"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log/slog"
+	"strconv"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/vela-ssoc/ssoc-common-mb/dal/model"
+	"github.com/vela-ssoc/ssoc-common-mb/dal/query"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+const (
+	// BucketKey 节点公钥存放的 kv_data 存储桶，key 为节点 ID。
+	//
+	// minion 表结构由 ssoc-common-mb 统一维护，broker 不能擅自增加字段，所以公钥没有放在
+	// minion 表上，而是以节点 ID 为 key 登记在 kv_data 中，中心端删除节点时需要一并清理该记录。
+	BucketKey = "agent-identity"
+
+	// BucketQuarantine 节点隔离记录存放的 kv_data 存储桶，key 为节点 ID。
+	BucketQuarantine = "agent-quarantine"
+
+	// nonceTTL 随机数的有效期。
+	nonceTTL = time.Minute
+
+	// maxNonces 同时有效的随机数个数上限，超过后淘汰最早下发的。
+	maxNonces = 8192
+)
+
+var (
+	ErrChallenge    = errors.New("请使用私钥签名 nonce 后重新认证")
+	ErrBadSignature = errors.New("节点身份签名校验失败")
+	ErrBadPublicKey = errors.New("节点公钥格式错误")
+	ErrQuarantined  = errors.New("节点公钥与登记的不一致，已被隔离")
+	ErrKeyRequired  = errors.New("节点上线必须携带公钥")
+)
+
+// Verifier 节点身份校验。
+//
+// agent 首次上线时携带自己的 ed25519 公钥，broker 将其与节点 ID 绑定登记。
+// 之后每次上线，agent 都要用私钥对 broker 下发的一次性随机数（nonce）签名，
+// 公钥与登记的不一致时，该次上线会被隔离，而不是合并到已存在的节点上。
+//
+// 没有公钥的节点无法校验身份，只有未协商 capability.IdentityKey 的老版本 agent
+// 在非严格模式下才会放行，见 SetStrict。
+type Verifier interface {
+	// Challenge 生成一次性随机数，与机器码绑定，一分钟内有效。
+	// 同一个机器码只保留最后一次下发的随机数。
+	Challenge(machineID string) string
+
+	// SetStrict 严格模式下拒绝所有没有携带公钥的节点，包括老版本 agent。
+	SetStrict(strict bool)
+
+	// Verify 校验节点身份，首次携带公钥上线时自动登记。
+	//
+	// 返回 ErrChallenge 说明需要下发 nonce 让 agent 签名后重试。
+	Verify(ctx context.Context, claim Claim) error
+
+	// Prove 只校验节点是否持有所声明公钥的私钥，不与节点绑定，也不登记公钥，
+	// 用于还没有对应节点的上线请求，如：因机器码冲突被拒绝的克隆主机。
+	//
+	// 没有公钥时的处理与 Verify 未登记公钥时一致，返回 ErrChallenge 说明需要下发 nonce 让 agent 签名后重试。
+	Prove(claim Claim) error
+
+	// Quarantines 查询所有被隔离的上线请求。
+	Quarantines(ctx context.Context) ([]*Quarantine, error)
+
+	// Release 解除隔离。rebind 为 true 时将隔离请求中的公钥重新绑定到该节点（如：主机重装），
+	// 否则仅删除隔离记录。
+	Release(ctx context.Context, minionID int64, rebind bool) error
+}
+
+// Claim 节点上线时声明的身份。
+type Claim struct {
+	MinionID  int64
+	MachineID string
+	Inet      string
+	PublicKey []byte
+	Nonce     string
+	Signature []byte
+
+	// KeyRequired agent 协商了 capability.IdentityKey 特性，必须携带公钥。
+	KeyRequired bool
+}
+
+// SignMessage agent 需要签名的报文。
+func SignMessage(nonce, machineID string) []byte {
+	return []byte(nonce + "\n" + machineID)
+}
+
+// Fingerprint 公钥指纹。
+func Fingerprint(pub []byte) string {
+	sum := sha256.Sum256(pub)
+	return hex.EncodeToString(sum[:])
+}
+
+// Quarantine 隔离记录。
+type Quarantine struct {
+	MinionID    int64     `json:"minion_id,string"`
+	MachineID   string    `json:"machine_id"`
+	Inet        string    `json:"inet"`
+	PublicKey   []byte    `json:"public_key"`
+	Fingerprint string    `json:"fingerprint"` // 本次上线携带的公钥指纹
+	Enrolled    string    `json:"enrolled"`    // 已登记的公钥指纹
+	Attempts    int       `json:"attempts"`    // 被隔离的次数
+	CreatedAt   time.Time `json:"created_at"`
+	UpdatedAt   time.Time `json:"updated_at"`
+}
+
+// enrollment 登记的公钥。
+type enrollment struct {
+	PublicKey  []byte    `json:"public_key"`
+	EnrolledAt time.Time `json:"enrolled_at"`
+}
+
+func New(qry *query.Query, log *slog.Logger) Verifier {
+	return &keyVerifier{
+		qry:    qry,
+		log:    log,
+		nonces: make(map[string]*list.Element, 64),
+		order:  list.New(),
+	}
+}
+
+type nonceEntry struct {
+	machineID string
+	nonce     string
+	expiredAt time.Time
+}
+
+type keyVerifier struct {
+	qry    *query.Query
+	log    *slog.Logger
+	strict atomic.Bool
+	mutex  sync.Mutex
+	nonces map[string]*list.Element // machineID -> *nonceEntry
+	order  *list.List               // 按照下发时间排序，最早的在前面
+}
+
+func (kv *keyVerifier) SetStrict(strict bool) {
+	kv.strict.Store(strict)
+}
+
+func (kv *keyVerifier) Challenge(machineID string) string {
+	buf := make([]byte, 32)
+	_, _ = rand.Read(buf)
+	nonce := hex.EncodeToString(buf)
+
+	now := time.Now()
+	kv.mutex.Lock()
+	defer kv.mutex.Unlock()
+
+	// 有效期相同，过期的一定在队头，淘汰时也从队头开始。
+	for front := kv.order.Front(); front != nil; front = kv.order.Front() {
+		ent := front.Value.(*nonceEntry)
+		if now.Before(ent.expiredAt) && kv.order.Len() < maxNonces {
+			break
+		}
+		kv.remove(front)
+	}
+	if elem, ok := kv.nonces[machineID]; ok {
+		kv.remove(elem)
+	}
+	ent := &nonceEntry{machineID: machineID, nonce: nonce, expiredAt: now.Add(nonceTTL)}
+	kv.nonces[machineID] = kv.order.PushBack(ent)
+
+	return nonce
+}
+
+func (kv *keyVerifier) Verify(ctx context.Context, claim Claim) error {
+	key := strconv.FormatInt(claim.MinionID, 10)
+	enrolled, err := kv.enrolled(ctx, key)
+	if err != nil {
+		return err
+	}
+
+	if len(claim.PublicKey) == 0 && enrolled != nil {
+		return kv.quarantine(ctx, claim, enrolled)
+	}
+	if err = kv.Prove(claim); err != nil || len(claim.PublicKey) == 0 {
+		return err
+	}
+
+	if enrolled == nil {
+		return kv.enroll(ctx, key, claim.PublicKey)
+	}
+	if !ed25519.PublicKey(enrolled.PublicKey).Equal(ed25519.PublicKey(claim.PublicKey)) {
+		return kv.quarantine(ctx, claim, enrolled)
+	}
+
+	return nil
+}
+
+func (kv *keyVerifier) Prove(claim Claim) error {
+	if len(claim.PublicKey) == 0 {
+		if claim.KeyRequired || kv.strict.Load() {
+			return ErrKeyRequired
+		}
+		// 老版本 agent 没有公钥，非严格模式下只要未登记过公钥就放行。
+		return nil
+	}
+	if len(claim.PublicKey) != ed25519.PublicKeySize {
+		return ErrBadPublicKey
+	}
+	if claim.Nonce == "" || len(claim.Signature) == 0 {
+		return ErrChallenge
+	}
+	if !kv.consume(claim.Nonce, claim.MachineID) ||
+		!ed25519.Verify(claim.PublicKey, SignMessage(claim.Nonce, claim.MachineID), claim.Signature) {
+		return ErrBadSignature
+	}
+
+	return nil
+}
+
+func (kv *keyVerifier) Quarantines(ctx context.Context) ([]*Quarantine, error) {
+	tbl := kv.qry.KVData
+	dats, err := tbl.WithContext(ctx).
+		Where(tbl.Bucket.Eq(BucketQuarantine)).
+		Order(tbl.UpdatedAt.Desc()).
+		Find()
+	if err != nil {
+		return nil, err
+	}
+
+	ret := make([]*Quarantine, 0, len(dats))
+	for _, dat := range dats {
+		qt := new(Quarantine)
+		if exx := json.Unmarshal(dat.Value, qt); exx == nil {
+			ret = append(ret, qt)
+		}
+	}
+
+	return ret, nil
+}
+
+func (kv *keyVerifier) Release(ctx context.Context, minionID int64, rebind bool) error {
+	key := strconv.FormatInt(minionID, 10)
+	tbl := kv.qry.KVData
+	dat, err := tbl.WithContext(ctx).
+		Where(tbl.Bucket.Eq(BucketQuarantine), tbl.Key.Eq(key)).
+		First()
+	if err != nil {
+		return err
+	}
+	qt := new(Quarantine)
+	if err = json.Unmarshal(dat.Value, qt); err != nil {
+		return err
+	}
+
+	return kv.qry.Transaction(func(tx *query.Query) error {
+		ttbl := tx.KVData
+		if rebind {
+			if _, exx := ttbl.WithContext(ctx).
+				Where(ttbl.Bucket.Eq(BucketKey), ttbl.Key.Eq(key)).
+				Delete(); exx != nil {
+				return exx
+			}
+			if len(qt.PublicKey) != 0 {
+				if exx := kv.save(ctx, tx, key, qt.PublicKey); exx != nil {
+					return exx
+				}
+			}
+		}
+		_, exx := ttbl.WithContext(ctx).
+			Where(ttbl.Bucket.Eq(BucketQuarantine), ttbl.Key.Eq(key)).
+			Delete()
+
+		return exx
+	})
+}
+
+// consume 消费随机数，随机数只能使用一次。
+func (kv *keyVerifier) consume(nonce, machineID string) bool {
+	kv.mutex.Lock()
+	defer kv.mutex.Unlock()
+
+	elem, ok := kv.nonces[machineID]
+	if !ok {
+		return false
+	}
+	ent := elem.Value.(*nonceEntry)
+	if ent.nonce != nonce {
+		return false
+	}
+	kv.remove(elem)
+
+	return time.Now().Before(ent.expiredAt)
+}
+
+// remove 删除随机数，调用方需持有锁。
+func (kv *keyVerifier) remove(elem *list.Element) {
+	ent := kv.order.Remove(elem).(*nonceEntry)
+	delete(kv.nonces, ent.machineID)
+}
+
+func (kv *keyVerifier) enrolled(ctx context.Context, key string) (*enrollment, error) {
+	tbl := kv.qry.KVData
+	dat, err := tbl.WithContext(ctx).
+		Where(tbl.Bucket.Eq(BucketKey), tbl.Key.Eq(key)).
+		First()
+	if err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, nil
+		}
+		return nil, err
+	}
+
+	ent := new(enrollment)
+	if err = json.Unmarshal(dat.Value, ent); err != nil {
+		return nil, err
+	}
+
+	return ent, nil
+}
+
+func (kv *keyVerifier) enroll(ctx context.Context, key string, pub []byte) error {
+	kv.log.Info("登记节点公钥", slog.String("minion_id", key), slog.String("fingerprint", Fingerprint(pub)))
+	return kv.save(ctx, kv.qry, key, pub)
+}
+
+func (*keyVerifier) save(ctx context.Context, qry *query.Query, key string, pub []byte) error {
+	now := time.Now()
+	val, _ := json.Marshal(&enrollment{PublicKey: pub, EnrolledAt: now})
+	dat := &model.KVData{
+		Bucket:    BucketKey,
+		Key:       key,
+		Value:     val,
+		Version:   1,
+		CreatedAt: now,
+		UpdatedAt: now,
+	}
+
+	// 并发登记时以先登记的为准
+	return qry.KVData.WithContext(ctx).
+		Clauses(clause.OnConflict{DoNothing: true}).
+		Create(dat)
+}
+
+// quarantine 记录隔离信息并保存事件，返回 ErrQuarantined。
+func (kv *keyVerifier) quarantine(ctx context.Context, claim Claim, enrolled *enrollment) error {
+	now := time.Now()
+	key := strconv.FormatInt(claim.MinionID, 10)
+	qt := &Quarantine{
+		MinionID:    claim.MinionID,
+		MachineID:   claim.MachineID,
+		Inet:        claim.Inet,
+		PublicKey:   claim.PublicKey,
+		Fingerprint: Fingerprint(claim.PublicKey),
+		Enrolled:    Fingerprint(enrolled.PublicKey),
+		Attempts:    1,
+		CreatedAt:   now,
+		UpdatedAt:   now,
+	}
+	if len(claim.PublicKey) == 0 {
+		qt.Fingerprint = ""
+	}
+
+	tbl := kv.qry.KVData
+	if old, err := tbl.WithContext(ctx).
+		Where(tbl.Bucket.Eq(BucketQuarantine), tbl.Key.Eq(key)).
+		First(); err == nil {
+		last := new(Quarantine)
+		if exx := json.Unmarshal(old.Value, last); exx == nil {
+			qt.Attempts = last.Attempts + 1
+			qt.CreatedAt = last.CreatedAt
+		}
+	}
+	val, _ := json.Marshal(qt)
+	dat := &model.KVData{
+		Bucket:    BucketQuarantine,
+		Key:       key,
+		Value:     val,
+		Version:   1,
+		CreatedAt: qt.CreatedAt,
+		UpdatedAt: now,
+	}
+	if err := tbl.WithContext(ctx).
+		Clauses(clause.OnConflict{DoUpdates: clause.AssignmentColumns([]string{"value", "updated_at"})}).
+		Create(dat); err != nil {
+		return err
+	}
+
+	attrs := []any{
+		slog.Int64("minion_id", claim.MinionID), slog.String("inet", claim.Inet),
+		slog.String("fingerprint", qt.Fingerprint), slog.String("enrolled", qt.Enrolled),
+	}
+	kv.log.Warn("节点公钥与登记的不一致，已隔离", attrs...)
+
+	// 只在首次隔离时产生事件，防止 agent 不断重连刷屏。
+	if qt.Attempts == 1 {
+		evt := &model.Event{
+			MinionID:  claim.MinionID,
+			Inet:      claim.Inet,
+			Subject:   "节点身份校验不通过",
+			FromCode:  "minion.quarantine",
+			Msg:       fmt.Sprintf("机器码 %s 上线时携带的公钥（%s）与登记的公钥（%s）不一致，已隔离", claim.MachineID, qt.Fingerprint, qt.Enrolled),
+			Level:     model.ELvlMajor,
+			OccurAt:   now,
+			CreatedAt: now,
+		}
+		_ = kv.qry.Event.WithContext(ctx).Create(evt)
+	}
+
+	return ErrQuarantined
+}
@@ -0,0 +1,147 @@
+package inetx
+
+import (
+	"net"
+	"net/netip"
+	"strings"
+)
+
+// Pair 节点的双栈地址，IPv4-only、IPv6-only 和双栈节点都至少有一个地址有效。
+//
+// 数据库中 inet 列存放 IPv4，inet6 列存放 IPv6，IPv6-only 节点的 inet 列为空，
+// 日志、标签、会话等需要单个地址的地方使用 Primary。
+type Pair struct {
+	V4 netip.Addr
+	V6 netip.Addr
+}
+
+// FromIP 从节点上报的地址中提取双栈地址，环回、未指定以及 IPv6 链路本地地址会被忽略。
+func FromIP(ips ...net.IP) Pair {
+	var p Pair
+	for _, ip := range ips {
+		if addr, ok := netip.AddrFromSlice(ip); ok {
+			p.add(addr)
+		}
+	}
+
+	return p
+}
+
+// FromString 同 FromIP，无法解析的地址会被忽略。
+func FromString(ips ...string) Pair {
+	var p Pair
+	for _, ip := range ips {
+		if addr, err := netip.ParseAddr(ip); err == nil {
+			p.add(addr)
+		}
+	}
+
+	return p
+}
+
+// Valid 是否至少有一个有效地址。
+func (p Pair) Valid() bool {
+	return p.V4.IsValid() || p.V6.IsValid()
+}
+
+// Inet 数据库 inet 列的值（IPv4），IPv6-only 节点返回空。
+func (p Pair) Inet() string {
+	return toString(p.V4)
+}
+
+// Inet6 数据库 inet6 列的值（IPv6），IPv4-only 节点返回空。
+func (p Pair) Inet6() string {
+	return toString(p.V6)
+}
+
+// Primary 节点的主地址，优先使用 IPv4。
+func (p Pair) Primary() string {
+	if p.V4.IsValid() {
+		return p.V4.String()
+	}
+
+	return toString(p.V6)
+}
+
+// Tags 需要打上生命周期标签的地址。
+func (p Pair) Tags() []string {
+	tags := make([]string, 0, 2)
+	if p.V4.IsValid() {
+		tags = append(tags, p.V4.String())
+	}
+	if p.V6.IsValid() {
+		tags = append(tags, p.V6.String())
+	}
+
+	return tags
+}
+
+func (p *Pair) add(addr netip.Addr) {
+	addr = addr.Unmap().WithZone("")
+	if addr.IsLoopback() || addr.IsUnspecified() {
+		return
+	}
+	if addr.Is4() {
+		if !p.V4.IsValid() {
+			p.V4 = addr
+		}
+	} else if !p.V6.IsValid() && !addr.IsLinkLocalUnicast() {
+		p.V6 = addr
+	}
+}
+
+// Display 展示用的节点地址：inet 为空（IPv6-only 节点）时使用 inet6。
+func Display(inet, inet6 string) string {
+	if inet != "" {
+		return inet
+	}
+
+	return inet6
+}
+
+// Canonical 将 IP 转为标准的文本形式（IPv4-mapped 地址转为 IPv4，IPv6 小写并压缩），
+// 保证同一地址的不同写法能够匹配，无法解析的原样返回。
+func Canonical(ip string) string {
+	addr, err := netip.ParseAddr(strings.TrimSpace(ip))
+	if err != nil {
+		return ip
+	}
+
+	return addr.Unmap().String()
+}
+
+// Unbracket 去掉未携带端口号的 IPv6 地址两侧的方括号，如：[::1] 转为 ::1，
+// 以便后续使用 net.JoinHostPort 补全端口号。
+func Unbracket(addr string) string {
+	if strings.HasPrefix(addr, "[") && strings.HasSuffix(addr, "]") {
+		return addr[1 : len(addr)-1]
+	}
+
+	return addr
+}
+
+// JoinDefaultPort 地址未携带端口号时补全默认端口号，兼容 IPv6 地址。
+func JoinDefaultPort(addr, port string) string {
+	if _, _, err := net.SplitHostPort(addr); err == nil {
+		return addr
+	}
+
+	return net.JoinHostPort(Unbracket(addr), port)
+}
+
+// HostHeader 将主机名或 IP 转为 HTTP Host 头的格式，IPv6 地址需要加上方括号。
+func HostHeader(host string) string {
+	if strings.Contains(host, ":") && !strings.HasPrefix(host, "[") {
+		return "[" + host + "]"
+	}
+
+	return host
+}
+
+func toString(addr netip.Addr) string {
+	if addr.IsValid() {
+		return addr.String()
+	}
+
+	return ""
+}
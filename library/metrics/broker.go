@@ -0,0 +1,168 @@
+package metrics
+
+import (
+	"context"
+	"io"
+	"net"
+	"net/http"
+	"runtime"
+	"strconv"
+	"time"
+)
+
+// broker 内部指标。
+var (
+	AgentsConnected = Default.Gauge("ssoc_broker_agents_connected",
+		"当前在线的 agent 个数", "transport")
+	AgentsCapacity = Default.Gauge("ssoc_broker_agents_capacity",
+		"agent 容量，为 0 代表不限制")
+	AgentAuth = Default.Counter("ssoc_broker_agent_auth_total",
+		"agent 上线认证结果", "transport", "reason")
+	RouteDuration = Default.Histogram("ssoc_broker_request_duration_seconds",
+		"接口处理耗时", DefBuckets, "router", "route", "code")
+	DBErrors = Default.Counter("ssoc_broker_db_errors_total",
+		"数据库操作出错次数（不含记录不存在）", "op")
+	PoolWorkers = Default.Gauge("ssoc_broker_pool_workers",
+		"协程池容量", "pool")
+	PoolBusy = Default.Gauge("ssoc_broker_pool_busy",
+		"协程池正在执行的任务个数", "pool")
+	PoolWaiting = Default.Gauge("ssoc_broker_pool_waiting",
+		"协程池已满时等待调度的任务个数", "pool")
+	PoolDropped = Default.Counter("ssoc_broker_pool_dropped_total",
+		"协程池已满时丢弃的任务个数", "pool")
+	ProxiedBytes = Default.Counter("ssoc_broker_proxied_bytes_total",
+		"代理转发的字节数，rx 为读入，tx 为写出", "kind", "direction")
+	HandshakeRejects = Default.Counter("ssoc_broker_handshake_rejects_total",
+		"握手报文被拒绝的次数", "transport", "reason")
+)
+
+func init() {
+	Default.Gauge("ssoc_broker_goroutines", "当前协程个数").With().Func(func() float64 {
+		return float64(runtime.NumGoroutine())
+	})
+	startAt := float64(time.Now().Unix())
+	Default.Gauge("ssoc_broker_start_time_seconds", "进程启动时间（unix 秒）").With().Func(func() float64 {
+		return startAt
+	})
+}
+
+// AuthReason 根据认证响应状态码归类认证结果。
+func AuthReason(code int) string {
+	switch code {
+	case http.StatusOK, http.StatusAccepted:
+		return "ok"
+	case http.StatusBadRequest:
+		return "bad_request"
+	case http.StatusUnauthorized:
+		return "unauthorized"
+	case http.StatusForbidden:
+		return "forbidden"
+	case http.StatusConflict:
+		return "duplicate"
+	case http.StatusTooManyRequests:
+		return "throttled"
+	case http.StatusServiceUnavailable:
+		return "draining"
+	case http.StatusInternalServerError:
+		return "internal_error"
+	}
+
+	return "status_" + strconv.Itoa(code)
+}
+
+// CountConn 统计代理连接的读写字节数。
+func CountConn(conn net.Conn, kind string) net.Conn {
+	return &countConn{
+		Conn: conn,
+		rx:   ProxiedBytes.With(kind, "rx"),
+		tx:   ProxiedBytes.With(kind, "tx"),
+	}
+}
+
+type countConn struct {
+	net.Conn
+	rx *Counter
+	tx *Counter
+}
+
+func (c *countConn) Read(b []byte) (int, error) {
+	n, err := c.Conn.Read(b)
+	c.rx.Add(float64(n))
+	return n, err
+}
+
+func (c *countConn) Write(b []byte) (int, error) {
+	n, err := c.Conn.Write(b)
+	c.tx.Add(float64(n))
+	return n, err
+}
+
+// CountReader 统计读取的字节数，如：下发给 agent 的升级文件。
+func CountReader(r io.Reader, kind, direction string) io.Reader {
+	return &countReader{Reader: r, cnt: ProxiedBytes.With(kind, direction)}
+}
+
+type countReader struct {
+	io.Reader
+	cnt *Counter
+}
+
+func (c *countReader) Read(b []byte) (int, error) {
+	n, err := c.Reader.Read(b)
+	c.cnt.Add(float64(n))
+	return n, err
+}
+
+// Pool 协程池，与 gopool.Pool 的方法一致。
+type Pool interface {
+	Go(fn func()) (done <-chan struct{})
+	Gos(parent context.Context, fns ...func(parent context.Context)) (done <-chan struct{})
+}
+
+// WrapPool 统计协程池的饱和度：正在执行与等待调度的任务个数。
+func WrapPool(name string, workers int, p Pool) Pool {
+	PoolWorkers.With(name).Set(float64(workers))
+	return &countPool{
+		next:    p,
+		busy:    PoolBusy.With(name),
+		waiting: PoolWaiting.With(name),
+	}
+}
+
+type countPool struct {
+	next    Pool
+	busy    *Gauge
+	waiting *Gauge
+}
+
+func (cp *countPool) Go(fn func()) <-chan struct{} {
+	if fn == nil {
+		return cp.next.Go(fn)
+	}
+	cp.waiting.Inc()
+	return cp.next.Go(cp.wrap(fn))
+}
+
+func (cp *countPool) Gos(parent context.Context, fns ...func(context.Context)) <-chan struct{} {
+	wraps := make([]func(context.Context), 0, len(fns))
+	for _, fn := range fns {
+		if fn == nil {
+			continue
+		}
+		cp.waiting.Inc()
+		wraps = append(wraps, func(ctx context.Context) {
+			cp.wrap(func() { fn(ctx) })()
+		})
+	}
+
+	return cp.next.Gos(parent, wraps...)
+}
+
+func (cp *countPool) wrap(fn func()) func() {
+	return func() {
+		cp.waiting.Dec()
+		cp.busy.Inc()
+		defer cp.busy.Dec()
+		fn()
+	}
+}
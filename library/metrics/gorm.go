@@ -0,0 +1,42 @@
+package metrics
+
+import (
+	"errors"
+
+	"gorm.io/gorm"
+)
+
+// GormPlugin 统计数据库操作出错次数的 gorm 插件，使用方法：db.Use(metrics.GormPlugin{})。
+type GormPlugin struct{}
+
+func (GormPlugin) Name() string {
+	return "ssoc:metrics"
+}
+
+func (GormPlugin) Initialize(db *gorm.DB) error {
+	cb := db.Callback()
+	hooks := []struct {
+		op       string
+		register func(string, func(*gorm.DB)) error
+	}{
+		{"create", cb.Create().After("gorm:create").Register},
+		{"query", cb.Query().After("gorm:query").Register},
+		{"update", cb.Update().After("gorm:update").Register},
+		{"delete", cb.Delete().After("gorm:delete").Register},
+		{"row", cb.Row().After("gorm:row").Register},
+		{"raw", cb.Raw().After("gorm:raw").Register},
+	}
+	for _, h := range hooks {
+		counter := DBErrors.With(h.op)
+		err := h.register("ssoc:metrics_"+h.op, func(tx *gorm.DB) {
+			if err := tx.Error; err != nil && !errors.Is(err, gorm.ErrRecordNotFound) {
+				counter.Inc()
+			}
+		})
+		if err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
@@ -0,0 +1,351 @@
+package metrics
+
+import (
+	"bufio"
+	"io"
+	"math"
+	"net/http"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// Default 默认的指标注册中心，broker 内部的指标都注册在这里。
+var Default = NewRegistry()
+
+// Registry 指标注册中心，按照 Prometheus 文本格式（或 OpenMetrics 格式）输出所有指标。
+type Registry struct {
+	mutex    sync.RWMutex
+	families []family
+}
+
+func NewRegistry() *Registry {
+	return new(Registry)
+}
+
+// family 同名指标的集合。
+type family interface {
+	meta() (name, help, typ string)
+	write(w *bufio.Writer, om bool)
+}
+
+func (r *Registry) register(f family) {
+	r.mutex.Lock()
+	r.families = append(r.families, f)
+	r.mutex.Unlock()
+}
+
+// Counter 注册计数器。
+func (r *Registry) Counter(name, help string, labels ...string) *CounterVec {
+	cv := new(CounterVec)
+	cv.init(name, help, labels)
+	r.register(cv)
+	return cv
+}
+
+// Gauge 注册仪表盘。
+func (r *Registry) Gauge(name, help string, labels ...string) *GaugeVec {
+	gv := new(GaugeVec)
+	gv.init(name, help, labels)
+	r.register(gv)
+	return gv
+}
+
+// Histogram 注册直方图，buckets 为各个桶的上界（升序）。
+func (r *Registry) Histogram(name, help string, buckets []float64, labels ...string) *HistogramVec {
+	hv := &HistogramVec{buckets: buckets}
+	hv.init(name, help, labels)
+	r.register(hv)
+	return hv
+}
+
+// WriteTo 输出所有指标，om 为 true 时按照 OpenMetrics 格式输出。
+func (r *Registry) WriteTo(w io.Writer, om bool) error {
+	r.mutex.RLock()
+	families := make([]family, len(r.families))
+	copy(families, r.families)
+	r.mutex.RUnlock()
+
+	bw := bufio.NewWriter(w)
+	for _, f := range families {
+		name, help, typ := f.meta()
+		if om && typ == "counter" {
+			name = strings.TrimSuffix(name, "_total")
+		}
+		bw.WriteString("# HELP " + name + " " + escapeHelp(help) + "\n")
+		bw.WriteString("# TYPE " + name + " " + typ + "\n")
+		f.write(bw, om)
+	}
+	if om {
+		bw.WriteString("# EOF\n")
+	}
+
+	return bw.Flush()
+}
+
+// ServeHTTP 输出指标，请求头 Accept 包含 application/openmetrics-text 时按照 OpenMetrics 格式输出。
+func (r *Registry) ServeHTTP(w http.ResponseWriter, req *http.Request) {
+	om := strings.Contains(req.Header.Get("Accept"), "application/openmetrics-text")
+	if om {
+		w.Header().Set("Content-Type", "application/openmetrics-text; version=1.0.0; charset=utf-8")
+	} else {
+		w.Header().Set("Content-Type", "text/plain; version=0.0.4; charset=utf-8")
+	}
+	_ = r.WriteTo(w, om)
+}
+
+// NewServer 单独监听的指标服务，只暴露 /metrics。
+func NewServer(addr string, r *Registry) *http.Server {
+	mux := http.NewServeMux()
+	mux.Handle("/metrics", r)
+
+	return &http.Server{Addr: addr, Handler: mux, ReadHeaderTimeout: 10 * time.Second}
+}
+
+// vec 带标签的指标集合，series 的 key 为标签值拼接而成。
+type vec[T any] struct {
+	name   string
+	help   string
+	labels []string
+	series sync.Map // map[string]*entry[T]
+}
+
+type entry[T any] struct {
+	values []string
+	metric *T
+}
+
+func (v *vec[T]) init(name, help string, labels []string) {
+	v.name, v.help, v.labels = name, help, labels
+}
+
+func (v *vec[T]) with(values []string) *T {
+	key := strings.Join(values, "\xff")
+	if e, ok := v.series.Load(key); ok {
+		return e.(*entry[T]).metric
+	}
+	vals := make([]string, len(v.labels))
+	copy(vals, values)
+	e, _ := v.series.LoadOrStore(key, &entry[T]{values: vals, metric: new(T)})
+
+	return e.(*entry[T]).metric
+}
+
+// sorted 按照标签值排序，保证每次输出的顺序一致。
+func (v *vec[T]) sorted() []*entry[T] {
+	var ret []*entry[T]
+	v.series.Range(func(_, e any) bool {
+		ret = append(ret, e.(*entry[T]))
+		return true
+	})
+	sort.Slice(ret, func(i, j int) bool {
+		return strings.Join(ret[i].values, "\xff") < strings.Join(ret[j].values, "\xff")
+	})
+
+	return ret
+}
+
+func (v *vec[T]) labelString(values []string, extra ...string) string {
+	if len(v.labels) == 0 && len(extra) == 0 {
+		return ""
+	}
+	var sb strings.Builder
+	sb.WriteByte('{')
+	for i, name := range v.labels {
+		if i > 0 {
+			sb.WriteByte(',')
+		}
+		sb.WriteString(name + `="` + escapeLabel(values[i]) + `"`)
+	}
+	for i := 0; i+1 < len(extra); i += 2 {
+		if sb.Len() > 1 {
+			sb.WriteByte(',')
+		}
+		sb.WriteString(extra[i] + `="` + escapeLabel(extra[i+1]) + `"`)
+	}
+	sb.WriteByte('}')
+
+	return sb.String()
+}
+
+// CounterVec 单调递增的计数器。
+type CounterVec struct {
+	vec[Counter]
+}
+
+// With 按照标签值（与注册时的标签顺序一致）获取计数器。
+func (cv *CounterVec) With(values ...string) *Counter {
+	return cv.with(values)
+}
+
+func (cv *CounterVec) meta() (string, string, string) {
+	return cv.name, cv.help, "counter"
+}
+
+func (cv *CounterVec) write(w *bufio.Writer, _ bool) {
+	for _, e := range cv.sorted() {
+		w.WriteString(cv.name + cv.labelString(e.values) + " " + formatFloat(e.metric.Value()) + "\n")
+	}
+}
+
+type Counter struct {
+	bits atomic.Uint64
+}
+
+func (c *Counter) Inc() {
+	c.Add(1)
+}
+
+// Add 增加计数，负数会被忽略。
+func (c *Counter) Add(v float64) {
+	if v > 0 {
+		addFloat(&c.bits, v)
+	}
+}
+
+func (c *Counter) Value() float64 {
+	return math.Float64frombits(c.bits.Load())
+}
+
+// GaugeVec 可增可减的仪表盘。
+type GaugeVec struct {
+	vec[Gauge]
+}
+
+// With 按照标签值（与注册时的标签顺序一致）获取仪表盘。
+func (gv *GaugeVec) With(values ...string) *Gauge {
+	return gv.with(values)
+}
+
+func (gv *GaugeVec) meta() (string, string, string) {
+	return gv.name, gv.help, "gauge"
+}
+
+func (gv *GaugeVec) write(w *bufio.Writer, _ bool) {
+	for _, e := range gv.sorted() {
+		w.WriteString(gv.name + gv.labelString(e.values) + " " + formatFloat(e.metric.Value()) + "\n")
+	}
+}
+
+type Gauge struct {
+	bits atomic.Uint64
+	fn   atomic.Pointer[func() float64]
+}
+
+func (g *Gauge) Set(v float64) {
+	g.bits.Store(math.Float64bits(v))
+}
+
+func (g *Gauge) Add(v float64) {
+	addFloat(&g.bits, v)
+}
+
+func (g *Gauge) Inc() { g.Add(1) }
+func (g *Gauge) Dec() { g.Add(-1) }
+
+// Func 采集时调用 fn 获取当前值，适用于在线个数等本身已有统计的场景。
+func (g *Gauge) Func(fn func() float64) {
+	g.fn.Store(&fn)
+}
+
+func (g *Gauge) Value() float64 {
+	if fn := g.fn.Load(); fn != nil {
+		return (*fn)()
+	}
+
+	return math.Float64frombits(g.bits.Load())
+}
+
+// DefBuckets 默认的耗时直方图桶（秒）。
+var DefBuckets = []float64{.005, .01, .025, .05, .1, .25, .5, 1, 2.5, 5, 10, 30}
+
+// HistogramVec 直方图。
+type HistogramVec struct {
+	vec[Histogram]
+	buckets []float64
+}
+
+// With 按照标签值（与注册时的标签顺序一致）获取直方图。
+func (hv *HistogramVec) With(values ...string) *Histogram {
+	h := hv.with(values)
+	h.init(hv.buckets)
+	return h
+}
+
+func (hv *HistogramVec) meta() (string, string, string) {
+	return hv.name, hv.help, "histogram"
+}
+
+func (hv *HistogramVec) write(w *bufio.Writer, _ bool) {
+	for _, e := range hv.sorted() {
+		h := e.metric
+		h.init(hv.buckets)
+		var cumulative uint64
+		for i, le := range hv.buckets {
+			cumulative += h.counts[i].Load()
+			w.WriteString(hv.name + "_bucket" + hv.labelString(e.values, "le", formatFloat(le)) +
+				" " + strconv.FormatUint(cumulative, 10) + "\n")
+		}
+		count := h.count.Load()
+		w.WriteString(hv.name + "_bucket" + hv.labelString(e.values, "le", "+Inf") + " " + strconv.FormatUint(count, 10) + "\n")
+		w.WriteString(hv.name + "_sum" + hv.labelString(e.values) + " " + formatFloat(math.Float64frombits(h.sum.Load())) + "\n")
+		w.WriteString(hv.name + "_count" + hv.labelString(e.values) + " " + strconv.FormatUint(count, 10) + "\n")
+	}
+}
+
+type Histogram struct {
+	once    sync.Once
+	buckets []float64
+	counts  []atomic.Uint64 // 每个桶单独计数，输出时累加
+	count   atomic.Uint64
+	sum     atomic.Uint64
+}
+
+func (h *Histogram) init(buckets []float64) {
+	h.once.Do(func() {
+		h.buckets = buckets
+		h.counts = make([]atomic.Uint64, len(buckets))
+	})
+}
+
+func (h *Histogram) Observe(v float64) {
+	if i := sort.SearchFloat64s(h.buckets, v); i < len(h.buckets) {
+		h.counts[i].Add(1)
+	}
+	addFloat(&h.sum, v)
+	h.count.Add(1)
+}
+
+func addFloat(bits *atomic.Uint64, v float64) {
+	for {
+		old := bits.Load()
+		val := math.Float64bits(math.Float64frombits(old) + v)
+		if bits.CompareAndSwap(old, val) {
+			return
+		}
+	}
+}
+
+func formatFloat(v float64) string {
+	switch {
+	case math.IsInf(v, 1):
+		return "+Inf"
+	case math.IsInf(v, -1):
+		return "-Inf"
+	case math.IsNaN(v):
+		return "NaN"
+	}
+
+	return strconv.FormatFloat(v, 'g', -1, 64)
+}
+
+var (
+	helpEscaper  = strings.NewReplacer(`\`, `\\`, "\n", `\n`)
+	labelEscaper = strings.NewReplacer(`\`, `\\`, "\n", `\n`, `"`, `\"`)
+)
+
+func escapeHelp(s string) string  { return helpEscaper.Replace(s) }
+func escapeLabel(s string) string { return labelEscaper.Replace(s) }
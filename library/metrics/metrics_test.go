@@ -0,0 +1,119 @@
+package metrics
+
+import (
+	"bytes"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func TestWriteTo(t *testing.T) {
+	r := NewRegistry()
+	cv := r.Counter("demo_requests_total", "请求次数", "method", "code")
+	cv.With("GET", "200").Add(3)
+	cv.With("POST", "500").Inc()
+	cv.With("GET", "200").Add(-1) // 负数忽略
+	gv := r.Gauge("demo_online", "在线个数")
+	gv.With().Set(7)
+	hv := r.Histogram("demo_seconds", "耗时", []float64{0.1, 1}, "route")
+	h := hv.With("ping")
+	h.Observe(0.05)
+	h.Observe(0.1) // 恰好等于上界，落在该桶内
+	h.Observe(0.5)
+	h.Observe(2)
+
+	var buf bytes.Buffer
+	if err := r.WriteTo(&buf, false); err != nil {
+		t.Fatal(err)
+	}
+	want := `# HELP demo_requests_total 请求次数
+# TYPE demo_requests_total counter
+demo_requests_total{method="GET",code="200"} 3
+demo_requests_total{method="POST",code="500"} 1
+# HELP demo_online 在线个数
+# TYPE demo_online gauge
+demo_online 7
+# HELP demo_seconds 耗时
+# TYPE demo_seconds histogram
+demo_seconds_bucket{route="ping",le="0.1"} 2
+demo_seconds_bucket{route="ping",le="1"} 3
+demo_seconds_bucket{route="ping",le="+Inf"} 4
+demo_seconds_sum{route="ping"} 2.65
+demo_seconds_count{route="ping"} 4
+`
+	if got := buf.String(); got != want {
+		t.Errorf("输出不一致\ngot:\n%s\nwant:\n%s", got, want)
+	}
+}
+
+func TestWriteToOpenMetrics(t *testing.T) {
+	r := NewRegistry()
+	r.Counter("demo_total", "计数").With().Inc()
+
+	var buf bytes.Buffer
+	if err := r.WriteTo(&buf, true); err != nil {
+		t.Fatal(err)
+	}
+	want := `# HELP demo 计数
+# TYPE demo counter
+demo_total 1
+# EOF
+`
+	if got := buf.String(); got != want {
+		t.Errorf("输出不一致\ngot:\n%s\nwant:\n%s", got, want)
+	}
+}
+
+func TestEscape(t *testing.T) {
+	r := NewRegistry()
+	r.Gauge("demo_info", "多行\n说明 \\ 反斜杠", "path").With("C:\\tmp\n\"x\"").Set(1)
+
+	var buf bytes.Buffer
+	if err := r.WriteTo(&buf, false); err != nil {
+		t.Fatal(err)
+	}
+	want := `# HELP demo_info 多行\n说明 \\ 反斜杠
+# TYPE demo_info gauge
+demo_info{path="C:\\tmp\n\"x\""} 1
+`
+	if got := buf.String(); got != want {
+		t.Errorf("输出不一致\ngot:\n%s\nwant:\n%s", got, want)
+	}
+}
+
+func TestGaugeFunc(t *testing.T) {
+	var g Gauge
+	g.Set(1)
+	g.Func(func() float64 { return 42 })
+	if v := g.Value(); v != 42 {
+		t.Errorf("Value() = %v，期望 42", v)
+	}
+}
+
+func TestServeHTTP(t *testing.T) {
+	r := NewRegistry()
+	r.Counter("demo_total", "计数").With().Inc()
+
+	tests := []struct {
+		accept string
+		ctype  string
+		eof    bool
+	}{
+		{accept: "", ctype: "text/plain; version=0.0.4; charset=utf-8"},
+		{accept: "application/openmetrics-text; version=1.0.0", ctype: "application/openmetrics-text; version=1.0.0; charset=utf-8", eof: true},
+	}
+	for _, tt := range tests {
+		req := httptest.NewRequest(http.MethodGet, "/metrics", nil)
+		req.Header.Set("Accept", tt.accept)
+		rec := httptest.NewRecorder()
+		r.ServeHTTP(rec, req)
+
+		if got := rec.Header().Get("Content-Type"); got != tt.ctype {
+			t.Errorf("Accept %q: Content-Type = %q，期望 %q", tt.accept, got, tt.ctype)
+		}
+		if got := strings.HasSuffix(rec.Body.String(), "# EOF\n"); got != tt.eof {
+			t.Errorf("Accept %q: 是否以 # EOF 结尾 = %v，期望 %v", tt.accept, got, tt.eof)
+		}
+	}
+}
@@ -0,0 +1,35 @@
+package metrics
+
+import (
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/xgfone/ship/v5"
+)
+
+// Middleware 统计接口耗时，namer 返回路由名，为空时使用路由路径。
+func Middleware(router string, namer func(*ship.Context) string) ship.Middleware {
+	return func(h ship.Handler) ship.Handler {
+		return func(c *ship.Context) error {
+			start := time.Now()
+			err := h(c)
+			du := time.Since(start)
+
+			var name string
+			if namer != nil {
+				name = namer(c)
+			}
+			if name == "" {
+				name = c.Route.Path
+			}
+			code := c.StatusCode()
+			if err != nil && code < http.StatusBadRequest {
+				code = http.StatusInternalServerError
+			}
+			RouteDuration.With(router, name, strconv.Itoa(code)).Observe(du.Seconds())
+
+			return err
+		}
+	}
+}
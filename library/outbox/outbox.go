@@ -0,0 +1,477 @@
+package outbox
+
+import (
+	"bufio"
+	"context"
+	"encoding/binary"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"hash/crc32"
+	"io"
+	"net/http"
+	"os"
+	"path/filepath"
+	"slices"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// ErrFull 队列已满，且无法再丢弃更旧的消息腾出空间。
+var ErrFull = errors.New("outbox 队列已满")
+
+const (
+	segmentExt = ".seg"
+	cursorName = "cursor"
+	headerSize = 8 // 4 字节长度 + 4 字节 CRC32
+)
+
+// Message 一条发往中心端的单向消息，重放时不关心响应内容。
+type Message struct {
+	Method    string      `json:"method"`
+	URL       string      `json:"url"`
+	Header    http.Header `json:"header,omitempty"`
+	Body      []byte      `json:"body,omitempty"`
+	CreatedAt time.Time   `json:"created_at"`
+}
+
+// Option 队列参数。
+type Option struct {
+	SegmentSize int64 // 单个段文件大小上限，默认 4MiB
+	MaxSize     int64 // 队列总大小上限，超过后丢弃最旧的段，默认 256MiB
+}
+
+func (opt Option) format() Option {
+	if opt.SegmentSize <= 0 {
+		opt.SegmentSize = 4 * 1024 * 1024
+	}
+	if opt.MaxSize <= 0 {
+		opt.MaxSize = 256 * 1024 * 1024
+	}
+	if opt.MaxSize < opt.SegmentSize {
+		opt.MaxSize = opt.SegmentSize
+	}
+
+	return opt
+}
+
+// Stats 队列统计信息。
+type Stats struct {
+	Depth    uint64 `json:"depth"`    // 等待重放的消息数
+	Bytes    int64  `json:"bytes"`    // 段文件占用的磁盘大小
+	Segments int    `json:"segments"` // 段文件个数
+	Enqueued uint64 `json:"enqueued"` // 累计入队的消息数
+	Replayed uint64 `json:"replayed"` // 累计重放成功的消息数
+	Dropped  uint64 `json:"dropped"`  // 累计因队列已满或数据损坏而丢弃的消息数
+}
+
+// Outbox 基于磁盘的先存后发队列：只追加写的段文件日志，按写入顺序重放。
+type Outbox interface {
+	// Enqueue 消息写入队列（落盘后返回）。
+	Enqueue(msg *Message) error
+
+	// Replay 按照写入顺序重放消息，send 返回错误时停止重放，该消息下次重放时会再次发送。
+	Replay(ctx context.Context, send func(context.Context, *Message) error) (int, error)
+
+	// Stats 队列统计信息。
+	Stats() Stats
+
+	// Close 关闭队列。
+	Close() error
+}
+
+// Open 打开 dir 目录下的队列，上次未重放完的消息会被保留。
+func Open(dir string, opt Option) (Outbox, error) {
+	if err := os.MkdirAll(dir, 0o755); err != nil {
+		return nil, err
+	}
+	ob := &outbox{dir: dir, opt: opt.format()}
+	if err := ob.load(); err != nil {
+		return nil, err
+	}
+
+	return ob, nil
+}
+
+type segment struct {
+	base  uint64 // 段内第一条消息的序号
+	count uint64 // 段内消息条数
+	size  int64  // 段文件大小
+}
+
+func (s *segment) end() uint64 {
+	return s.base + s.count
+}
+
+type outbox struct {
+	dir string
+	opt Option
+
+	mutex    sync.Mutex
+	segs     []*segment // 按序号升序，最后一个为正在写入的段
+	active   *os.File
+	next     uint64 // 下一条写入消息的序号
+	cursor   uint64 // 下一条待重放消息的序号
+	enqueued uint64
+	replayed uint64
+	dropped  uint64
+
+	replaying sync.Mutex
+}
+
+func (ob *outbox) Enqueue(msg *Message) error {
+	data, err := json.Marshal(msg)
+	if err != nil {
+		return err
+	}
+	size := int64(headerSize + len(data))
+	record := make([]byte, size)
+	binary.BigEndian.PutUint32(record, uint32(len(data)))
+	binary.BigEndian.PutUint32(record[4:], crc32.ChecksumIEEE(data))
+	copy(record[headerSize:], data)
+
+	ob.mutex.Lock()
+	defer ob.mutex.Unlock()
+
+	if size > ob.opt.SegmentSize {
+		ob.dropped++
+		return fmt.Errorf("%w：消息大小 %d 超过段文件上限", ErrFull, size)
+	}
+	if last := ob.segs[len(ob.segs)-1]; last.count != 0 && last.size+size > ob.opt.SegmentSize {
+		if err = ob.rotate(); err != nil {
+			return err
+		}
+	}
+	for ob.total()+size > ob.opt.MaxSize && len(ob.segs) > 1 {
+		ob.evict()
+	}
+	if ob.total()+size > ob.opt.MaxSize {
+		ob.dropped++
+		return ErrFull
+	}
+
+	if _, err = ob.active.Write(record); err != nil {
+		return err
+	}
+	if err = ob.active.Sync(); err != nil {
+		return err
+	}
+	last := ob.segs[len(ob.segs)-1]
+	last.count++
+	last.size += size
+	ob.next++
+	ob.enqueued++
+
+	return nil
+}
+
+func (ob *outbox) Replay(ctx context.Context, send func(context.Context, *Message) error) (int, error) {
+	ob.replaying.Lock()
+	defer ob.replaying.Unlock()
+
+	var sent int
+	for {
+		seg, from := ob.pending()
+		if seg == nil {
+			return sent, nil
+		}
+		n, err := ob.replaySegment(ctx, seg, from, send)
+		sent += n
+		if err != nil {
+			return sent, err
+		}
+		ob.retire()
+	}
+}
+
+func (ob *outbox) Stats() Stats {
+	ob.mutex.Lock()
+	defer ob.mutex.Unlock()
+
+	return Stats{
+		Depth:    ob.next - ob.cursor,
+		Bytes:    ob.total(),
+		Segments: len(ob.segs),
+		Enqueued: ob.enqueued,
+		Replayed: ob.replayed,
+		Dropped:  ob.dropped,
+	}
+}
+
+func (ob *outbox) Close() error {
+	ob.mutex.Lock()
+	defer ob.mutex.Unlock()
+
+	return ob.active.Close()
+}
+
+// replaySegment 从序号 from 开始重放段内的消息，正在写入的段会一直读到当前最新的消息。
+func (ob *outbox) replaySegment(ctx context.Context, seg *segment, from uint64, send func(context.Context, *Message) error) (int, error) {
+	f, err := os.Open(ob.segmentPath(seg.base))
+	if err != nil {
+		return 0, err
+	}
+	//goland:noinspection GoUnhandledErrorResult
+	defer f.Close()
+
+	rd := bufio.NewReader(f)
+	var sent int
+	for seq := seg.base; ; seq++ {
+		if err = ctx.Err(); err != nil {
+			return sent, err
+		}
+		ob.mutex.Lock()
+		end := seg.end()
+		ob.mutex.Unlock()
+		if seq >= end {
+			return sent, nil
+		}
+
+		data, exx := readRecord(rd, ob.opt.SegmentSize)
+		if exx != nil {
+			// 段文件已被淘汰或者数据损坏，剩余消息无法读取。
+			ob.skip(end)
+			return sent, nil
+		}
+		if seq < from {
+			continue
+		}
+
+		msg := new(Message)
+		if exx = json.Unmarshal(data, msg); exx != nil {
+			ob.ack(seq, false)
+			continue
+		}
+		if err = send(ctx, msg); err != nil {
+			return sent, err
+		}
+		ob.ack(seq, true)
+		sent++
+	}
+}
+
+// pending 找到下一条待重放消息所在的段。
+func (ob *outbox) pending() (*segment, uint64) {
+	ob.mutex.Lock()
+	defer ob.mutex.Unlock()
+
+	for _, seg := range ob.segs {
+		if ob.cursor < seg.end() {
+			return seg, max(ob.cursor, seg.base)
+		}
+	}
+
+	return nil, 0
+}
+
+func (ob *outbox) ack(seq uint64, delivered bool) {
+	ob.mutex.Lock()
+	defer ob.mutex.Unlock()
+
+	if seq < ob.cursor {
+		return
+	}
+	ob.cursor = seq + 1
+	if delivered {
+		ob.replayed++
+	} else {
+		ob.dropped++
+	}
+	_ = ob.saveCursor()
+}
+
+// skip 跳过无法读取的消息。
+func (ob *outbox) skip(end uint64) {
+	ob.mutex.Lock()
+	defer ob.mutex.Unlock()
+
+	if end > ob.cursor {
+		ob.dropped += end - ob.cursor
+		ob.cursor = end
+		_ = ob.saveCursor()
+	}
+}
+
+// retire 删除已经全部重放完毕的段文件，正在写入的段保留。
+func (ob *outbox) retire() {
+	ob.mutex.Lock()
+	defer ob.mutex.Unlock()
+
+	ob.retireLocked()
+}
+
+// retireLocked 删除开头所有已经全部重放完毕的段文件，调用方需持有锁。
+//
+// 段在写入期间就可能被重放完毕，此时不能删除，等到轮转后才会变成可删除的旧段，
+// 所以轮转时也要检查一次，否则该段不会再被 pending 返回，永远留在磁盘上。
+func (ob *outbox) retireLocked() {
+	for len(ob.segs) > 1 {
+		seg := ob.segs[0]
+		if ob.cursor < seg.end() {
+			return
+		}
+		ob.segs = ob.segs[1:]
+		_ = os.Remove(ob.segmentPath(seg.base))
+	}
+}
+
+// evict 队列超过大小上限，丢弃最旧的段。
+func (ob *outbox) evict() {
+	seg := ob.segs[0]
+	ob.segs = ob.segs[1:]
+	_ = os.Remove(ob.segmentPath(seg.base))
+	if end := seg.end(); end > ob.cursor {
+		ob.dropped += end - max(ob.cursor, seg.base)
+		ob.cursor = end
+		_ = ob.saveCursor()
+	}
+}
+
+func (ob *outbox) rotate() error {
+	f, err := os.OpenFile(ob.segmentPath(ob.next), os.O_CREATE|os.O_WRONLY|os.O_APPEND, 0o644)
+	if err != nil {
+		return err
+	}
+	_ = ob.active.Close()
+	ob.active = f
+	ob.segs = append(ob.segs, &segment{base: ob.next})
+	ob.retireLocked()
+
+	return nil
+}
+
+func (ob *outbox) total() int64 {
+	var n int64
+	for _, seg := range ob.segs {
+		n += seg.size
+	}
+
+	return n
+}
+
+// load 加载已有的段文件，最后一个段末尾不完整的记录（如写入时断电）会被截断。
+func (ob *outbox) load() error {
+	entries, err := os.ReadDir(ob.dir)
+	if err != nil {
+		return err
+	}
+	var bases []uint64
+	for _, ent := range entries {
+		name := ent.Name()
+		if ent.IsDir() || !strings.HasSuffix(name, segmentExt) {
+			continue
+		}
+		if base, exx := strconv.ParseUint(strings.TrimSuffix(name, segmentExt), 10, 64); exx == nil {
+			bases = append(bases, base)
+		}
+	}
+	slices.Sort(bases)
+
+	for i, base := range bases {
+		seg, exx := ob.scan(base, i == len(bases)-1)
+		if exx != nil {
+			return exx
+		}
+		ob.segs = append(ob.segs, seg)
+	}
+	ob.cursor = ob.loadCursor()
+	if n := len(ob.segs); n != 0 {
+		ob.next = ob.segs[n-1].end()
+		ob.cursor = max(ob.cursor, ob.segs[0].base)
+	} else {
+		ob.next = ob.cursor
+	}
+	ob.cursor = min(ob.cursor, ob.next)
+
+	if n := len(ob.segs); n != 0 && ob.segs[n-1].size < ob.opt.SegmentSize {
+		ob.active, err = os.OpenFile(ob.segmentPath(ob.segs[n-1].base), os.O_WRONLY|os.O_APPEND, 0o644)
+		return err
+	}
+	ob.active, err = os.OpenFile(ob.segmentPath(ob.next), os.O_CREATE|os.O_WRONLY|os.O_APPEND, 0o644)
+	if err == nil {
+		ob.segs = append(ob.segs, &segment{base: ob.next})
+		// 上次运行遗留的已重放完毕的段
+		ob.retireLocked()
+	}
+
+	return err
+}
+
+func (ob *outbox) scan(base uint64, last bool) (*segment, error) {
+	name := ob.segmentPath(base)
+	f, err := os.Open(name)
+	if err != nil {
+		return nil, err
+	}
+	//goland:noinspection GoUnhandledErrorResult
+	defer f.Close()
+
+	seg := &segment{base: base}
+	rd := bufio.NewReader(f)
+	for {
+		data, exx := readRecord(rd, ob.opt.SegmentSize)
+		if exx != nil {
+			break
+		}
+		seg.count++
+		seg.size += int64(headerSize + len(data))
+	}
+	if last {
+		if err = os.Truncate(name, seg.size); err != nil {
+			return nil, err
+		}
+	}
+
+	return seg, nil
+}
+
+func (ob *outbox) loadCursor() uint64 {
+	raw, err := os.ReadFile(filepath.Join(ob.dir, cursorName))
+	if err != nil {
+		return 0
+	}
+	n, _ := strconv.ParseUint(strings.TrimSpace(string(raw)), 10, 64)
+
+	return n
+}
+
+func (ob *outbox) saveCursor() error {
+	name := filepath.Join(ob.dir, cursorName)
+	tmp := name + ".tmp"
+	if err := os.WriteFile(tmp, []byte(strconv.FormatUint(ob.cursor, 10)), 0o644); err != nil {
+		return err
+	}
+
+	return os.Rename(tmp, name)
+}
+
+func (ob *outbox) segmentPath(base uint64) string {
+	return filepath.Join(ob.dir, fmt.Sprintf("%020d%s", base, segmentExt))
+}
+
+var errCorrupt = errors.New("outbox 记录损坏")
+
+// readRecord 读取一条记录，入队时已经保证单条记录不超过段文件大小上限 limit，
+// 超过说明长度字段已损坏，不能按照该长度分配内存。
+func readRecord(rd io.Reader, limit int64) ([]byte, error) {
+	var header [headerSize]byte
+	if _, err := io.ReadFull(rd, header[:]); err != nil {
+		return nil, err
+	}
+	size := binary.BigEndian.Uint32(header[:])
+	sum := binary.BigEndian.Uint32(header[4:])
+	if int64(size)+headerSize > limit {
+		return nil, errCorrupt
+	}
+	data := make([]byte, size)
+	if _, err := io.ReadFull(rd, data); err != nil {
+		return nil, err
+	}
+	if crc32.ChecksumIEEE(data) != sum {
+		return nil, errCorrupt
+	}
+
+	return data, nil
+}
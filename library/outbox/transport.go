@@ -0,0 +1,219 @@
+package outbox
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"io"
+	"log/slog"
+	"net"
+	"net/http"
+	"slices"
+	"time"
+)
+
+// maxBodySize 允许写入队列的请求体大小上限，超过的请求不会入队。
+const maxBodySize = 1024 * 1024
+
+// Dialer 连接中心端的拨号函数。
+type Dialer func(ctx context.Context, network, addr string) (net.Conn, error)
+
+// persistHeaders 入队时保留的请求头，其余请求头（如：Authorization、Cookie 等凭证）不会落盘。
+var persistHeaders = []string{"Content-Type", "Content-Encoding", "Accept", "User-Agent"}
+
+// NewTransport 创建发往中心端的 http.RoundTripper：
+// 写类请求（POST、PUT、PATCH、DELETE）在无法连接中心端时写入 outbox 并立即响应 202，
+// 由 Replay 在重连成功后或 Run 定期按顺序重放。
+//
+// 调用方拿到的 202 是伪造的响应，所以只能给发送单向消息、不关心响应内容的客户端使用，
+// 需要真实响应的请求（如：代理）不能使用该 Transport。
+func NewTransport(box Outbox, dial Dialer, log *slog.Logger) *Transport {
+	next := &http.Transport{
+		DialContext: func(ctx context.Context, network, addr string) (net.Conn, error) {
+			conn, err := dial(ctx, network, addr)
+			if err != nil {
+				return nil, &dialError{err: err}
+			}
+			return conn, nil
+		},
+		MaxIdleConnsPerHost: 4,
+		IdleConnTimeout:     time.Minute,
+	}
+
+	return &Transport{box: box, next: next, log: log}
+}
+
+type Transport struct {
+	box  Outbox
+	next *http.Transport
+	log  *slog.Logger
+}
+
+func (t *Transport) RoundTrip(r *http.Request) (*http.Response, error) {
+	if !queueable(r) {
+		return t.next.RoundTrip(r)
+	}
+
+	body, whole, err := readBody(r)
+	if err != nil {
+		return nil, err
+	}
+	if !whole { // 请求体过大，不入队
+		return t.next.RoundTrip(r)
+	}
+	// 队列中有积压时先尝试重放，重放完毕再直接发送，否则入队以保证顺序。
+	if t.box.Stats().Depth != 0 {
+		t.Replay(r.Context())
+		if t.box.Stats().Depth != 0 {
+			return t.enqueue(r, body)
+		}
+	}
+
+	req := r.Clone(r.Context())
+	req.Body = io.NopCloser(bytes.NewReader(body))
+	res, err := t.next.RoundTrip(req)
+	if de := new(dialError); errors.As(err, &de) {
+		return t.enqueue(r, body)
+	}
+
+	return res, err
+}
+
+// Replay 重放队列中积压的消息。
+func (t *Transport) Replay(ctx context.Context) {
+	n, err := t.box.Replay(ctx, t.send)
+	if n == 0 && err == nil {
+		return
+	}
+	attrs := []any{slog.Int("replayed", n), slog.Any("stats", t.box.Stats())}
+	if err != nil {
+		attrs = append(attrs, slog.Any("error", err))
+		t.log.Warn("outbox 重放中断，稍后重试", attrs...)
+	} else {
+		t.log.Info("outbox 重放完毕", attrs...)
+	}
+}
+
+// Run 每隔 interval 检查一次队列，有积压时重放，直到 ctx 结束。
+func (t *Transport) Run(ctx context.Context, interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		if t.box.Stats().Depth != 0 {
+			t.Replay(ctx)
+		}
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+// Stats 队列统计信息。
+func (t *Transport) Stats() Stats {
+	return t.box.Stats()
+}
+
+func (t *Transport) enqueue(r *http.Request, body []byte) (*http.Response, error) {
+	msg := &Message{
+		Method:    r.Method,
+		URL:       r.URL.String(),
+		Header:    persistHeader(r.Header),
+		Body:      body,
+		CreatedAt: time.Now(),
+	}
+	if err := t.box.Enqueue(msg); err != nil {
+		t.log.Error("消息写入 outbox 失败", slog.String("url", msg.URL), slog.Any("error", err))
+		return nil, err
+	}
+	t.log.Warn("中心端连接不可用，消息已写入 outbox", slog.String("url", msg.URL))
+
+	return &http.Response{
+		Status:     "202 Accepted",
+		StatusCode: http.StatusAccepted,
+		Proto:      "HTTP/1.1",
+		ProtoMajor: 1,
+		ProtoMinor: 1,
+		Header:     http.Header{"X-Outbox": []string{"queued"}},
+		Body:       http.NoBody,
+		Request:    r,
+	}, nil
+}
+
+// send 重放一条消息，网关类错误视为中心端暂不可用，其余响应均视为已送达（避免单条消息阻塞整个队列）。
+func (t *Transport) send(ctx context.Context, msg *Message) error {
+	req, err := http.NewRequestWithContext(ctx, msg.Method, msg.URL, bytes.NewReader(msg.Body))
+	if err != nil {
+		return nil
+	}
+	req.Header = msg.Header
+	res, err := t.next.RoundTrip(req)
+	if err != nil {
+		return err
+	}
+	_, _ = io.Copy(io.Discard, io.LimitReader(res.Body, 64*1024))
+	_ = res.Body.Close()
+
+	switch res.StatusCode {
+	case http.StatusBadGateway, http.StatusServiceUnavailable, http.StatusGatewayTimeout:
+		return errors.New(res.Status)
+	}
+
+	return nil
+}
+
+func persistHeader(h http.Header) http.Header {
+	ret := make(http.Header, len(persistHeaders))
+	for _, k := range persistHeaders {
+		if vs := h.Values(k); len(vs) != 0 {
+			ret[k] = slices.Clone(vs)
+		}
+	}
+
+	return ret
+}
+
+func queueable(r *http.Request) bool {
+	switch r.Method {
+	case http.MethodPost, http.MethodPut, http.MethodPatch, http.MethodDelete:
+		return r.Header.Get("Upgrade") == ""
+	}
+
+	return false
+}
+
+// readBody 读取请求体，超过大小上限时恢复请求体并返回 whole = false。
+func readBody(r *http.Request) ([]byte, bool, error) {
+	if r.Body == nil || r.Body == http.NoBody {
+		return nil, true, nil
+	}
+	data, err := io.ReadAll(io.LimitReader(r.Body, maxBodySize+1))
+	if err != nil {
+		_ = r.Body.Close()
+		return nil, false, err
+	}
+	if len(data) > maxBodySize {
+		r.Body = struct {
+			io.Reader
+			io.Closer
+		}{io.MultiReader(bytes.NewReader(data), r.Body), r.Body}
+		return nil, false, nil
+	}
+	_ = r.Body.Close()
+
+	return data, true, nil
+}
+
+type dialError struct {
+	err error
+}
+
+func (e *dialError) Error() string {
+	return e.err.Error()
+}
+
+func (e *dialError) Unwrap() error {
+	return e.err
+}
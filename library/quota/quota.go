@@ -0,0 +1,344 @@
+package quota
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"math"
+	"net"
+	"net/http"
+	"strconv"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"golang.org/x/time/rate"
+)
+
+// 违规类型
+const (
+	BreachStreams   = "streams"    // 并发虚拟流个数超限
+	BreachReadRate  = "read_rate"  // 读取速率超限（每被限速一秒记一次）
+	BreachWriteRate = "write_rate" // 写入速率超限（每被限速一秒记一次）
+	BreachBody      = "body"       // 请求报文超限
+)
+
+var ErrBodyTooLarge = errors.New("请求报文超过限制")
+
+// ThrottleError 读取速率超限，请求被直接拒绝。
+type ThrottleError struct {
+	RetryAfter time.Duration
+}
+
+func (e *ThrottleError) Error() string {
+	return fmt.Sprintf("读取速率超过限制，请 %s 后重试", e.RetryAfter)
+}
+
+// Seconds Retry-After 秒数，向上取整。
+func (e *ThrottleError) Seconds() int {
+	return int(math.Ceil(e.RetryAfter.Seconds()))
+}
+
+// Config 单个 agent 的资源配额，数值小于等于 0 代表不限制。
+type Config struct {
+	MaxStreams  int              `json:"max_streams"`  // 最大并发虚拟流个数
+	ReadRate    int              `json:"read_rate"`    // 每秒读取的字节数（agent -> broker）
+	WriteRate   int              `json:"write_rate"`   // 每秒写入的字节数（broker -> agent）
+	BodyLimits  map[string]int64 `json:"body_limits"`  // 路由族对应的最大请求报文字节数，* 代表默认
+	MaxBreaches int              `json:"max_breaches"` // Window 内违规次数超过该值则断开连接
+	Window      time.Duration    `json:"window"`       // 违规计数的时间窗口
+}
+
+// DefaultConfig 默认配额。
+func DefaultConfig() Config {
+	return Config{
+		MaxStreams: 256,
+		ReadRate:   16 * 1024 * 1024,
+		WriteRate:  16 * 1024 * 1024,
+		BodyLimits: map[string]int64{
+			"*":              8 * 1024 * 1024,
+			"broker/collect": 32 * 1024 * 1024,
+			"broker/proxy":   64 * 1024 * 1024,
+		},
+		MaxBreaches: 50,
+		Window:      time.Minute,
+	}
+}
+
+// Family 计算路由族：/api/v1 之后的第一段，broker 开头的取前两段。
+//
+//	/api/v1/broker/collect/agent/cpu -> broker/collect
+//	/api/v1/shared/strings/get       -> shared
+func Family(path string) string {
+	path = strings.TrimPrefix(path, "/api/v1")
+	path = strings.TrimPrefix(path, "/")
+	sn := strings.SplitN(path, "/", 3)
+	if sn[0] == "broker" && len(sn) > 1 {
+		return sn[0] + "/" + sn[1]
+	}
+
+	return sn[0]
+}
+
+// NewGuard 创建单个 agent 的配额守卫，knockout 在违规次数超限时调用（只会调用一次）。
+func NewGuard(cfg Config, knockout func(reason string)) *Guard {
+	g := &Guard{
+		cfg:      cfg,
+		knockout: knockout,
+		counts:   make(map[string]uint64, 4),
+		owed:     make(map[string]time.Duration, 2),
+	}
+	if n := cfg.ReadRate; n > 0 {
+		g.reader = rate.NewLimiter(rate.Limit(n), n)
+	}
+	if n := cfg.WriteRate; n > 0 {
+		g.writer = rate.NewLimiter(rate.Limit(n), n)
+	}
+
+	return g
+}
+
+// Guard 单个 agent 的配额守卫：超限时先限流，一段时间内违规次数过多再断开连接。
+type Guard struct {
+	cfg      Config
+	knockout func(string)
+	reader   *rate.Limiter
+	writer   *rate.Limiter
+	kicked   atomic.Bool
+
+	mutex  sync.Mutex
+	counts map[string]uint64        // 累计违规次数
+	window []time.Time              // 时间窗口内的违规时间
+	owed   map[string]time.Duration // 尚未满一秒的欠账时长
+}
+
+// AllowStream 当前打开的虚拟流个数是否在配额内，超限时记录违规。
+func (g *Guard) AllowStream(open int) bool {
+	if g == nil || g.cfg.MaxStreams <= 0 || open <= g.cfg.MaxStreams {
+		return true
+	}
+	g.Breach(BreachStreams)
+
+	return false
+}
+
+// BodyLimit 路由对应的最大请求报文字节数，小于等于 0 代表不限制。
+func (g *Guard) BodyLimit(path string) int64 {
+	if g == nil || len(g.cfg.BodyLimits) == 0 {
+		return 0
+	}
+	if n, ok := g.cfg.BodyLimits[Family(path)]; ok {
+		return n
+	}
+
+	return g.cfg.BodyLimits["*"]
+}
+
+// LimitBody 限制请求报文大小，Content-Length 超限直接返回 ErrBodyTooLarge，
+// 否则读取超限时返回错误，两种情况都会记录违规。
+func (g *Guard) LimitBody(w http.ResponseWriter, r *http.Request) error {
+	limit := g.BodyLimit(r.URL.Path)
+	if limit <= 0 {
+		return nil
+	}
+	if r.ContentLength > limit {
+		g.Breach(BreachBody)
+		return ErrBodyTooLarge
+	}
+	r.Body = &limitedBody{ReadCloser: http.MaxBytesReader(w, r.Body, limit), guard: g}
+
+	return nil
+}
+
+// Throttle 读取速率超限时返回 *ThrottleError 并记录违规，调用方应直接拒绝请求。
+//
+// 读取方向不能通过等待来限速：smux 会话内所有虚拟流共用接收缓冲区，
+// 某个虚拟流读得慢会阻塞整个会话（包括心跳），所以超限时只记欠账，由 Throttle 拒绝后续请求。
+func (g *Guard) Throttle() error {
+	if g == nil || g.reader == nil {
+		return nil
+	}
+	tokens := g.reader.Tokens()
+	if tokens >= 0 {
+		return nil
+	}
+	g.Breach(BreachReadRate)
+	after := time.Duration(-tokens / float64(g.reader.Limit()) * float64(time.Second))
+
+	return &ThrottleError{RetryAfter: after}
+}
+
+// WrapConn 对单个虚拟流的读写限速，不能用在 smux 会话的底层连接上。
+//
+// 写入方向等待令牌只会阻塞当前虚拟流；读取方向只记账不等待，见 Throttle。
+func (g *Guard) WrapConn(conn net.Conn) net.Conn {
+	if g == nil || (g.reader == nil && g.writer == nil) {
+		return conn
+	}
+
+	return &throttleConn{Conn: conn, guard: g}
+}
+
+// Breach 记录一次违规，时间窗口内违规次数超限则断开连接。
+func (g *Guard) Breach(kind string) {
+	if g == nil {
+		return
+	}
+
+	now := time.Now()
+	g.mutex.Lock()
+	g.counts[kind]++
+	var exceeded bool
+	if g.cfg.MaxBreaches > 0 {
+		start := now.Add(-g.cfg.Window)
+		idx := 0
+		for idx < len(g.window) && g.window[idx].Before(start) {
+			idx++
+		}
+		g.window = append(g.window[idx:], now)
+		exceeded = len(g.window) > g.cfg.MaxBreaches
+	}
+	g.mutex.Unlock()
+
+	if exceeded && g.knockout != nil && g.kicked.CompareAndSwap(false, true) {
+		g.knockout(kind)
+	}
+}
+
+// Breaches 累计违规次数。
+func (g *Guard) Breaches() map[string]uint64 {
+	if g == nil {
+		return nil
+	}
+
+	g.mutex.Lock()
+	defer g.mutex.Unlock()
+
+	ret := make(map[string]uint64, len(g.counts))
+	for k, v := range g.counts {
+		ret[k] = v
+	}
+
+	return ret
+}
+
+// reserve 消耗 n 个令牌，返回需要等待的时长，不足的令牌记为欠账。
+func (g *Guard) reserve(lim *rate.Limiter, n int) time.Duration {
+	var delay time.Duration
+	now := time.Now()
+	burst := lim.Burst()
+	for n > 0 {
+		m := min(n, burst)
+		n -= m
+		if d := lim.ReserveN(now, m).DelayFrom(now); d > delay {
+			delay = d
+		}
+	}
+
+	return delay
+}
+
+// wait 按照限速器等待，被限速的时长每满一秒记一次违规。
+func (g *Guard) wait(lim *rate.Limiter, n int, kind string) {
+	if lim == nil || n <= 0 {
+		return
+	}
+	if delay := g.reserve(lim, n); delay > 0 {
+		time.Sleep(delay)
+		g.owe(kind, delay)
+	}
+}
+
+// account 只记账不等待，欠账时长每满一秒记一次违规。
+func (g *Guard) account(lim *rate.Limiter, n int, kind string) {
+	if lim == nil || n <= 0 {
+		return
+	}
+	if delay := g.reserve(lim, n); delay > 0 {
+		g.owe(kind, min(delay, time.Duration(float64(n)/float64(lim.Limit())*float64(time.Second))))
+	}
+}
+
+func (g *Guard) owe(kind string, delay time.Duration) {
+	g.mutex.Lock()
+	delay += g.owed[kind]
+	g.owed[kind] = delay % time.Second
+	g.mutex.Unlock()
+
+	for ; delay >= time.Second; delay -= time.Second {
+		g.Breach(kind)
+	}
+}
+
+type throttleConn struct {
+	net.Conn
+	guard *Guard
+}
+
+func (tc *throttleConn) Read(p []byte) (int, error) {
+	n, err := tc.Conn.Read(p)
+	tc.guard.account(tc.guard.reader, n, BreachReadRate)
+	return n, err
+}
+
+func (tc *throttleConn) Write(p []byte) (int, error) {
+	tc.guard.wait(tc.guard.writer, len(p), BreachWriteRate)
+	return tc.Conn.Write(p)
+}
+
+type limitedBody struct {
+	io.ReadCloser
+	guard    *Guard
+	breached bool
+}
+
+func (lb *limitedBody) Read(p []byte) (int, error) {
+	n, err := lb.ReadCloser.Read(p)
+	var me *http.MaxBytesError
+	if !lb.breached && errors.As(err, &me) {
+		lb.breached = true
+		lb.guard.Breach(BreachBody)
+	}
+	return n, err
+}
+
+// Handler 按照 context 中的配额守卫限制读取速率与请求报文大小。
+func Handler(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		guard := FromContext(r.Context())
+		if err := guard.Throttle(); err != nil {
+			var te *ThrottleError
+			if errors.As(err, &te) {
+				w.Header().Set("Retry-After", strconv.Itoa(te.Seconds()))
+			}
+			http.Error(w, err.Error(), http.StatusTooManyRequests)
+			return
+		}
+		if err := guard.LimitBody(w, r); err != nil {
+			http.Error(w, err.Error(), http.StatusRequestEntityTooLarge)
+			return
+		}
+		next.ServeHTTP(w, r)
+	})
+}
+
+type contextKey struct{ name string }
+
+var guardCtxKey = &contextKey{name: "quota-guard"}
+
+// WithContext 将配额守卫放入 context。
+func WithContext(parent context.Context, g *Guard) context.Context {
+	return context.WithValue(parent, guardCtxKey, g)
+}
+
+// FromContext 从 context 中取出配额守卫，不存在时返回 nil（nil 守卫不做任何限制）。
+func FromContext(ctx context.Context) *Guard {
+	if ctx == nil {
+		return nil
+	}
+	g, _ := ctx.Value(guardCtxKey).(*Guard)
+
+	return g
+}
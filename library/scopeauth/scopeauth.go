@@ -0,0 +1,242 @@
+package scopeauth
+
+import (
+	"container/list"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"slices"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Header 中心端调用 broker 时携带令牌的请求头。
+const Header = "X-Broker-Token"
+
+// Scope 令牌的授权范围。
+type Scope string
+
+const (
+	// ScopeDiagRead 只读诊断：pprof、会话、任务进度等查询类接口。
+	ScopeDiagRead Scope = "diag:read"
+
+	// ScopeAgentControl 节点控制：通知节点执行命令、同步配置、隔离处理等。
+	ScopeAgentControl Scope = "agent:control"
+
+	// ScopeAgentRaw 节点原始访问：透传 HTTP/websocket 到节点。
+	ScopeAgentRaw Scope = "agent:raw"
+
+	// ScopeBrokerLifecycle broker 生命周期：退出、升级、排空、配置热加载等。
+	ScopeBrokerLifecycle Scope = "broker:lifecycle"
+)
+
+var (
+	ErrMissingToken = errors.New("缺少授权令牌")
+	ErrMalformed    = errors.New("授权令牌格式错误")
+	ErrBadSignature = errors.New("授权令牌签名错误")
+	ErrExpired      = errors.New("授权令牌已过期")
+	ErrNotYetValid  = errors.New("授权令牌尚未生效")
+	ErrTTLTooLong   = errors.New("授权令牌有效期过长")
+	ErrScopeDenied  = errors.New("授权令牌没有访问该接口的权限")
+	ErrReplayed     = errors.New("授权令牌已经使用过")
+)
+
+// Mode 令牌的校验模式，用于灰度上线：中心端全部升级为签发令牌之前先使用 audit 观察拒绝情况。
+type Mode string
+
+const (
+	ModeOff     Mode = "off"     // 不校验令牌
+	ModeAudit   Mode = "audit"   // 校验令牌，未通过时只记录审计日志，请求照常处理（默认）
+	ModeEnforce Mode = "enforce" // 校验令牌，未通过时拒绝请求
+)
+
+// Format 空字符串与无法识别的模式都按默认的 audit 处理。
+func (m Mode) Format() Mode {
+	switch m {
+	case ModeOff, ModeAudit, ModeEnforce:
+		return m
+	default:
+		return ModeAudit
+	}
+}
+
+// maxSeen 记录已使用令牌 ID 的上限，超过后淘汰最早的记录。
+const maxSeen = 65536
+
+// Claims 令牌携带的声明。
+type Claims struct {
+	ID        string  `json:"jti"`           // 令牌 ID，用于审计与防重放，每个令牌只能使用一次
+	Subject   string  `json:"sub"`           // 调用方，如：中心端操作人
+	Scopes    []Scope `json:"scp"`           // 授权范围
+	IssuedAt  int64   `json:"iat"`           // 签发时间（unix 秒）
+	ExpiresAt int64   `json:"exp"`           // 过期时间（unix 秒）
+	Broker    int64   `json:"bid,omitempty"` // 限定的 broker ID，为 0 代表不限
+}
+
+// Has 是否拥有指定的授权范围。
+func (c Claims) Has(scope Scope) bool {
+	return slices.Contains(c.Scopes, scope)
+}
+
+type Option struct {
+	// MaxTTL 允许的最长有效期（exp - iat），默认 5 分钟。
+	MaxTTL time.Duration
+
+	// Skew 允许的时钟偏差，默认 30 秒。
+	Skew time.Duration
+}
+
+// Authority 中心端调用令牌的签发与校验。
+//
+// 令牌格式为 v1.<base64url(claims)>.<base64url(HMAC-SHA256)>，
+// 签名密钥由 broker 密钥派生，中心端与 broker 各自持有，无需额外分发。
+type Authority interface {
+	// Sign 签发令牌，一般由中心端调用，broker 侧用于运维工具调试。
+	Sign(claims Claims) (string, error)
+
+	// Verify 校验令牌的签名与有效期，同一个令牌 ID 在过期之前只能校验通过一次。
+	//
+	// 令牌是一次性的：中心端每次调用都要签发新的令牌（新的 jti），重试同一个请求也不能复用，
+	// 否则会得到 ErrReplayed。
+	Verify(token string) (Claims, error)
+}
+
+func New(secret string, brokerID int64, opt Option) Authority {
+	if opt.MaxTTL <= 0 {
+		opt.MaxTTL = 5 * time.Minute
+	}
+	if opt.Skew <= 0 {
+		opt.Skew = 30 * time.Second
+	}
+	mac := hmac.New(sha256.New, []byte(secret))
+	mac.Write([]byte("ssoc-broker/scopeauth/v1"))
+
+	return &authority{
+		key:      mac.Sum(nil),
+		brokerID: brokerID,
+		opt:      opt,
+		seen:     make(map[string]*list.Element, 1024),
+		order:    list.New(),
+	}
+}
+
+const version = "v1"
+
+type authority struct {
+	key      []byte
+	brokerID int64
+	opt      Option
+	mutex    sync.Mutex
+	seen     map[string]*list.Element // 令牌 ID -> order 中的元素
+	order    *list.List               // 按照使用顺序排列的 seenToken
+}
+
+// seenToken 已经使用过的令牌，过期后即可遗忘（过期令牌本身就无法通过校验）。
+type seenToken struct {
+	id       string
+	expireAt time.Time
+}
+
+func (au *authority) Sign(claims Claims) (string, error) {
+	raw, err := json.Marshal(claims)
+	if err != nil {
+		return "", err
+	}
+	payload := version + "." + base64.RawURLEncoding.EncodeToString(raw)
+	sig := base64.RawURLEncoding.EncodeToString(au.sign(payload))
+
+	return payload + "." + sig, nil
+}
+
+func (au *authority) Verify(token string) (Claims, error) {
+	var claims Claims
+	if token == "" {
+		return claims, ErrMissingToken
+	}
+	idx := strings.LastIndexByte(token, '.')
+	if idx < 0 || !strings.HasPrefix(token, version+".") {
+		return claims, ErrMalformed
+	}
+	payload, enc := token[:idx], token[idx+1:]
+	sig, err := base64.RawURLEncoding.DecodeString(enc)
+	if err != nil {
+		return claims, ErrMalformed
+	}
+	if !hmac.Equal(sig, au.sign(payload)) {
+		return claims, ErrBadSignature
+	}
+	raw, err := base64.RawURLEncoding.DecodeString(payload[len(version)+1:])
+	if err != nil {
+		return claims, ErrMalformed
+	}
+	if err = json.Unmarshal(raw, &claims); err != nil {
+		return claims, ErrMalformed
+	}
+
+	now := time.Now()
+	if err = au.validate(claims, now); err != nil {
+		return claims, err
+	}
+
+	return claims, au.consume(claims, now)
+}
+
+// consume 记录令牌 ID，已经使用过的令牌返回 ErrReplayed。
+func (au *authority) consume(c Claims, now time.Time) error {
+	if c.ID == "" {
+		return fmt.Errorf("%w：缺少令牌 ID", ErrMalformed)
+	}
+	expireAt := time.Unix(c.ExpiresAt, 0).Add(au.opt.Skew)
+
+	au.mutex.Lock()
+	defer au.mutex.Unlock()
+
+	for elem := au.order.Front(); elem != nil; elem = au.order.Front() {
+		st := elem.Value.(*seenToken)
+		if au.order.Len() < maxSeen && now.Before(st.expireAt) {
+			break
+		}
+		au.order.Remove(elem)
+		delete(au.seen, st.id)
+	}
+	if elem, ok := au.seen[c.ID]; ok {
+		if now.Before(elem.Value.(*seenToken).expireAt) {
+			return ErrReplayed
+		}
+		au.order.Remove(elem)
+	}
+	au.seen[c.ID] = au.order.PushBack(&seenToken{id: c.ID, expireAt: expireAt})
+
+	return nil
+}
+
+func (au *authority) validate(c Claims, now time.Time) error {
+	iat, exp := time.Unix(c.IssuedAt, 0), time.Unix(c.ExpiresAt, 0)
+	if c.ExpiresAt <= c.IssuedAt {
+		return ErrMalformed
+	}
+	if ttl := exp.Sub(iat); ttl > au.opt.MaxTTL {
+		return fmt.Errorf("%w：%s", ErrTTLTooLong, ttl)
+	}
+	if now.Add(au.opt.Skew).Before(iat) {
+		return ErrNotYetValid
+	}
+	if now.Add(-au.opt.Skew).After(exp) {
+		return ErrExpired
+	}
+	if c.Broker != 0 && au.brokerID != 0 && c.Broker != au.brokerID {
+		return fmt.Errorf("%w：令牌签发给 broker %d", ErrScopeDenied, c.Broker)
+	}
+
+	return nil
+}
+
+func (au *authority) sign(payload string) []byte {
+	mac := hmac.New(sha256.New, au.key)
+	mac.Write([]byte(payload))
+	return mac.Sum(nil)
+}
@@ -0,0 +1,102 @@
+package takeover
+
+import (
+	"context"
+	"errors"
+	"log/slog"
+	"time"
+
+	"github.com/vela-ssoc/ssoc-common-mb/dal/model"
+	"github.com/vela-ssoc/ssoc-common-mb/dal/query"
+	"gorm.io/gorm"
+)
+
+const (
+	// StaleLease 节点心跳与上线时间都超过该时长，即认为原会话已失效。
+	StaleLease = 5 * time.Minute
+
+	// ProbeTimeout 探测原会话是否存活的超时时间。
+	ProbeTimeout = 5 * time.Second
+
+	// LivenessPath 询问中心端节点会话是否存活的接口，新旧通道共用。
+	LivenessPath = "/api/v1/broker/agent/liveness"
+)
+
+// LivenessRequest 询问中心端节点在指定 broker 上的会话是否存活。
+type LivenessRequest struct {
+	BrokerID int64 `json:"broker_id,string"`
+	AgentID  int64 `json:"agent_id,string"`
+}
+
+type LivenessResponse struct {
+	Alive bool `json:"alive"`
+}
+
+// Checker 询问节点在其它 broker 上的会话是否存活，一般通过中心端询问。
+type Checker interface {
+	AgentLiveness(ctx context.Context, brokerID, agentID int64) (bool, error)
+}
+
+// Remote 节点会话在其它 broker 上，先通过中心端询问，询问失败则根据原 broker 状态与租约判断。
+//
+// 查询原 broker 出错时无法判断，返回错误，调用方应当拒绝新会话而不是接管。
+func Remote(ctx context.Context, qry *query.Query, chk Checker, mon *model.Minion, log *slog.Logger) (bool, string, error) {
+	if chk != nil {
+		// 询问中心端使用单独的超时，不能占用后续查询数据库的时间。
+		cctx, cancel := context.WithTimeout(ctx, ProbeTimeout)
+		alive, err := chk.AgentLiveness(cctx, mon.BrokerID, mon.ID)
+		cancel()
+		if err == nil {
+			if alive {
+				return true, "", nil
+			}
+			return false, "中心端确认原 broker 上的会话已失效", nil
+		}
+		log.Debug("通过中心端询问节点存活状态失败，使用租约判断", slog.Int64("minion_id", mon.ID), slog.Any("error", err))
+	}
+
+	tbl := qry.Broker
+	brk, err := tbl.WithContext(ctx).Where(tbl.ID.Eq(mon.BrokerID)).First()
+	if err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return false, "原 broker 不存在", nil
+		}
+		return false, "", err
+	}
+	if !brk.Status {
+		return false, "原 broker 已离线", nil
+	}
+
+	leaseAt := mon.HeartbeatAt
+	if uptime := mon.Uptime; uptime.Valid && uptime.Time.After(leaseAt) {
+		leaseAt = uptime.Time
+	}
+	if time.Since(leaseAt) > StaleLease {
+		return false, "原会话租约已过期", nil
+	}
+
+	return true, "", nil
+}
+
+// Evict 原会话已失效，将数据库中的节点状态修改为离线，返回新会话是否可以接管。
+func Evict(ctx context.Context, qry *query.Query, mon *model.Minion) (bool, error) {
+	online, offline := uint8(model.MSOnline), uint8(model.MSOffline)
+	tbl := qry.Minion
+	ret, err := tbl.WithContext(ctx).
+		Where(tbl.ID.Eq(mon.ID), tbl.Status.Eq(online), tbl.BrokerID.Eq(mon.BrokerID)).
+		UpdateSimple(tbl.Status.Value(offline))
+	if err != nil {
+		return false, err
+	}
+	if ret.RowsAffected != 0 {
+		return true, nil
+	}
+
+	// 本地旧会话下线时已经修改为离线，或者并发情况下已经被其它会话抢先上线。
+	cur, err := tbl.WithContext(ctx).Select(tbl.Status).Where(tbl.ID.Eq(mon.ID)).First()
+	if err != nil {
+		return false, err
+	}
+
+	return cur.Status == model.MSOffline, nil
+}
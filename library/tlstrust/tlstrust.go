@@ -0,0 +1,214 @@
+package tlstrust
+
+import (
+	"crypto/sha256"
+	"crypto/tls"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"os"
+	"strings"
+	"sync"
+)
+
+var (
+	ErrNoCertificate = errors.New("服务端没有提供证书")
+	ErrPinMismatch   = errors.New("服务端证书公钥指纹与预置的不一致")
+)
+
+// Config 连接中心端时的 TLS 校验配置。
+//
+//   - 只配置 CA：使用该 CA 校验证书链和域名。
+//   - 只配置 Pins：服务端证书的公钥指纹与预置的一致；或者服务端证书能够通过证书链
+//     校验到某个公钥指纹与预置一致的证书（此时该证书视为根证书，同时校验域名）。
+//   - 都配置：两者都要校验通过。
+//   - 都未配置：使用操作系统的根证书校验。
+//   - Insecure：跳过所有校验，仅限实验环境使用。
+type Config struct {
+	CA       string   `json:"ca"       yaml:"ca"`       // PEM 格式的 CA 证书，可以包含多个
+	CAFile   string   `json:"ca_file"  yaml:"ca_file"`  // PEM 格式的 CA 证书文件
+	Pins     []string `json:"pins"     yaml:"pins"`     // 证书公钥（SPKI）的 SHA-256 指纹，base64 或 hex 编码
+	Insecure bool     `json:"insecure" yaml:"insecure"` // 跳过证书校验，仅限实验环境使用
+}
+
+// Trust 中心端证书校验器，预置指纹可以在运行时轮换。
+type Trust interface {
+	// TLSConfig 生成连接中心端使用的 TLS 配置。
+	TLSConfig(serverName string) *tls.Config
+
+	// SetPins 轮换预置的公钥指纹，对之后建立的连接生效，为空时清除指纹校验。
+	SetPins(pins []string) error
+
+	// Pins 当前预置的公钥指纹（hex 编码）。
+	Pins() []string
+
+	// Insecure 是否跳过了证书校验。
+	Insecure() bool
+}
+
+func New(cfg Config) (Trust, error) {
+	tr := &trust{insecure: cfg.Insecure}
+	if err := tr.loadCA(cfg); err != nil {
+		return nil, err
+	}
+	if err := tr.SetPins(cfg.Pins); err != nil {
+		return nil, err
+	}
+
+	return tr, nil
+}
+
+// Fingerprint 计算证书公钥（SPKI）的 SHA-256 指纹（hex 编码）。
+func Fingerprint(cert *x509.Certificate) string {
+	sum := sha256.Sum256(cert.RawSubjectPublicKeyInfo)
+	return hex.EncodeToString(sum[:])
+}
+
+type trust struct {
+	insecure bool
+	roots    *x509.CertPool // 为空代表使用系统根证书
+	mutex    sync.RWMutex
+	pins     map[string]struct{}
+}
+
+func (tr *trust) TLSConfig(serverName string) *tls.Config {
+	cfg := &tls.Config{ServerName: serverName, MinVersion: tls.VersionTLS12}
+	if tr.insecure {
+		cfg.InsecureSkipVerify = true
+		return cfg
+	}
+
+	cfg.RootCAs = tr.roots
+	if pins := tr.loadPins(); len(pins) != 0 {
+		// 没有配置 CA 时只校验公钥指纹，允许中心端使用自签名证书。
+		cfg.InsecureSkipVerify = tr.roots == nil
+		cfg.VerifyConnection = func(cs tls.ConnectionState) error {
+			if len(cs.VerifiedChains) != 0 {
+				return verifyChains(cs.VerifiedChains, pins)
+			}
+			return verifyPins(cs.PeerCertificates, cs.ServerName, pins)
+		}
+	}
+
+	return cfg
+}
+
+func (tr *trust) SetPins(pins []string) error {
+	set := make(map[string]struct{}, len(pins))
+	for _, pin := range pins {
+		fp, err := parsePin(pin)
+		if err != nil {
+			return err
+		}
+		set[fp] = struct{}{}
+	}
+
+	tr.mutex.Lock()
+	tr.pins = set
+	tr.mutex.Unlock()
+
+	return nil
+}
+
+func (tr *trust) Pins() []string {
+	pins := tr.loadPins()
+	ret := make([]string, 0, len(pins))
+	for pin := range pins {
+		ret = append(ret, pin)
+	}
+
+	return ret
+}
+
+func (tr *trust) Insecure() bool {
+	return tr.insecure
+}
+
+func (tr *trust) loadPins() map[string]struct{} {
+	tr.mutex.RLock()
+	defer tr.mutex.RUnlock()
+
+	return tr.pins
+}
+
+func (tr *trust) loadCA(cfg Config) error {
+	pem := []byte(cfg.CA)
+	if name := cfg.CAFile; name != "" {
+		raw, err := os.ReadFile(name)
+		if err != nil {
+			return err
+		}
+		pem = append(pem, '\n')
+		pem = append(pem, raw...)
+	}
+	if len(strings.TrimSpace(string(pem))) == 0 {
+		return nil
+	}
+
+	pool := x509.NewCertPool()
+	if !pool.AppendCertsFromPEM(pem) {
+		return errors.New("CA 证书格式错误")
+	}
+	tr.roots = pool
+
+	return nil
+}
+
+// verifyChains 证书链已经通过 CA 校验，链上任意一个证书的公钥指纹与预置的一致即可。
+func verifyChains(chains [][]*x509.Certificate, pins map[string]struct{}) error {
+	for _, chain := range chains {
+		for _, cert := range chain {
+			if _, ok := pins[Fingerprint(cert)]; ok {
+				return nil
+			}
+		}
+	}
+
+	return fmt.Errorf("%w：%s", ErrPinMismatch, Fingerprint(chains[0][0]))
+}
+
+// verifyPins 没有配置 CA 时，服务端证书（第一个证书）的公钥指纹与预置的一致即可；
+// 否则服务端提供的某个中间证书的指纹与预置的一致时，将其作为根证书校验服务端证书链和域名。
+// 不能只检查服务端提供的证书列表中有没有指纹一致的证书，否则任何人都可以把公开的中心端证书附在自己的证书后面。
+func verifyPins(certs []*x509.Certificate, serverName string, pins map[string]struct{}) error {
+	if len(certs) == 0 {
+		return ErrNoCertificate
+	}
+	leaf := certs[0]
+	if _, ok := pins[Fingerprint(leaf)]; ok {
+		return nil
+	}
+
+	for i := 1; i < len(certs); i++ {
+		if _, ok := pins[Fingerprint(certs[i])]; !ok {
+			continue
+		}
+
+		roots, inters := x509.NewCertPool(), x509.NewCertPool()
+		roots.AddCert(certs[i])
+		for _, cert := range certs[1:i] {
+			inters.AddCert(cert)
+		}
+		opts := x509.VerifyOptions{DNSName: serverName, Roots: roots, Intermediates: inters}
+		if _, err := leaf.Verify(opts); err == nil {
+			return nil
+		}
+	}
+
+	return fmt.Errorf("%w：%s", ErrPinMismatch, Fingerprint(leaf))
+}
+
+// parsePin 解析指纹，支持 hex 与 base64（可带 sha256/ 前缀）编码，统一转为 hex。
+func parsePin(pin string) (string, error) {
+	pin = strings.TrimPrefix(strings.TrimSpace(pin), "sha256/")
+	if raw, err := hex.DecodeString(pin); err == nil && len(raw) == sha256.Size {
+		return hex.EncodeToString(raw), nil
+	}
+	if raw, err := base64.StdEncoding.DecodeString(pin); err == nil && len(raw) == sha256.Size {
+		return hex.EncodeToString(raw), nil
+	}
+
+	return "", fmt.Errorf("公钥指纹格式错误：%s", pin)
+}